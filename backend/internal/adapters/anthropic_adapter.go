@@ -16,6 +16,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// defaultAnthropicVersion is sent when the client does not specify an anthropic-version header.
+const defaultAnthropicVersion = "2023-06-01"
+
 // AnthropicAdapter is an adapter for the Anthropic API.
 type AnthropicAdapter struct {
 	*BaseLLMAdapter
@@ -64,57 +67,40 @@ func (a *AnthropicAdapter) ProcessRequest() (*services.TargetRequest, error) {
 	// 3. Build the target request.
 	headers := utils.FilterRequestHeaders(a.c.Request.Header, []string{"x-api-key", "Authorization", "x-anthropic-api-key", "accept-encoding"})
 
-	// 优先使用数据库中为该proxyConfig保存的APIKeyName, 否则回退到默认值
-	keyName := "x-api-key"
-	if a.proxyConfig.APIKeyName != nil && *a.proxyConfig.APIKeyName != "" {
-		keyName = *a.proxyConfig.APIKeyName
-	}
-
-	// 优先使用数据库中为该proxyConfig保存的APIKeyLocation, 否则回退到默认值
-	keyLocation := "header"
-	if a.proxyConfig.APIKeyLocation != nil && *a.proxyConfig.APIKeyLocation != "" {
-		keyLocation = *a.proxyConfig.APIKeyLocation
-	}
-
-	// 根据keyLocation将key添加到headers或params
-	if keyLocation == "header" {
-		// Inject the real upstream key and the fixed anthropic-version.
-		headers[keyName] = upstreamKey
-	} else if keyLocation == "query" {
-		// 将key添加到查询参数
-		if a.c.Request.URL.RawQuery == "" {
-			a.c.Request.URL.RawQuery = fmt.Sprintf("%s=%s", keyName, upstreamKey)
-		} else {
-			a.c.Request.URL.RawQuery += fmt.Sprintf("&%s=%s", keyName, upstreamKey)
-		}
-	}
-	headers["anthropic-version"] = "2023-06-01"
-
 	baseURL := ""
 	if a.proxyConfig.TargetBaseURL != nil {
 		baseURL = strings.TrimSuffix(*a.proxyConfig.TargetBaseURL, "/")
 	}
 	finalURL := fmt.Sprintf("%s/%s", baseURL, a.action)
 
-	params := make(map[string]string)
-	for key, values := range a.c.Request.URL.Query() {
-		if len(values) > 0 {
-			params[key] = values[0]
-		}
-	}
-
 	body, err := io.ReadAll(a.c.Request.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read request body: %w", err)
 	}
 
-	logger.Infof("%s: Rotated to upstream key (masked): %s", a.logPrefix, utils.MaskAPIKeyDefault(upstreamKey))
-
-	return &services.TargetRequest{
+	target := &services.TargetRequest{
 		Method:  a.c.Request.Method,
 		URL:     finalURL,
 		Headers: headers,
-		Params:  params,
+		Params:  a.collectQueryParams(),
 		Body:    body,
-	}, nil
+	}
+
+	// Default anthropic-version unless the client (e.g. for newer features or anthropic-beta flags) sent its own.
+	if headers.Get("anthropic-version") == "" {
+		headers.Set("anthropic-version", defaultAnthropicVersion)
+	}
+
+	// Inject the real upstream key (defaults to the 'x-api-key' header) and apply the config's rewrite rules.
+	if err := a.injectUpstreamKey(target, upstreamKey, services.CredentialInjection{
+		Location: services.KeyLocationHeader,
+		Name:     "x-api-key",
+		Template: services.KeyPlaceholder,
+	}); err != nil {
+		return nil, err
+	}
+
+	logger.Infof("%s: Rotated to upstream key (masked): %s", a.logPrefix, utils.MaskAPIKeyDefault(upstreamKey.KeyValue))
+
+	return target, nil
 }
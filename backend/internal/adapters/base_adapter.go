@@ -2,9 +2,10 @@ package adapters
 
 import (
 	"fmt"
+	"net/url"
 
-	"api-key-rotator/backend/internal/infrastructure/cache"
 	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/infrastructure/cache"
 	"api-key-rotator/backend/internal/models"
 	"api-key-rotator/backend/internal/services"
 
@@ -47,8 +48,36 @@ func NewBaseLLMAdapter(cfg *config.Config, db *gorm.DB, cacheClient cache.CacheI
 }
 
 // RotateUpstreamKey 从密钥池中轮询一个真实的上游API Key
-func (a *BaseLLMAdapter) RotateUpstreamKey() (string, error) {
+func (a *BaseLLMAdapter) RotateUpstreamKey() (*models.APIKey, error) {
 	// 直接使用预加载好的ProxyConfig
-	handler := services.NewBaseProxyHandler(a.cfg, a.db, a.cacheClient, a.c, a.proxyConfig.Slug, a.action)
-	return handler.RotateAPIKey(a.proxyConfig)
-}
\ No newline at end of file
+	return a.proxyHandler().RotateAPIKeyRecord(a.proxyConfig)
+}
+
+// injectUpstreamKey 按密钥类型和配置的注入规则为目标请求配置上游认证，未配置的部分使用适配器默认值，
+// 随后执行配置的请求头、查询参数和响应头改写规则，并按选中的密钥确定连接参数（超时、出口代理）
+func (a *BaseLLMAdapter) injectUpstreamKey(target *services.TargetRequest, upstreamKey *models.APIKey, defaults services.CredentialInjection) error {
+	upstream, err := services.ResolveUpstreamOptions(a.cfg, a.proxyConfig, upstreamKey)
+	if err != nil {
+		return err
+	}
+	target.Upstream = upstream
+
+	injection := services.ResolveCredentialInjection(a.proxyConfig, defaults)
+	if err := a.proxyHandler().ApplyUpstreamAuth(target, a.proxyConfig, upstreamKey, injection); err != nil {
+		return err
+	}
+
+	rewriteCtx := services.NewRewriteContext(a.c, upstreamKey)
+	services.ApplyRequestRewrites(target, a.proxyConfig.RewriteRules, rewriteCtx)
+	target.RewriteResponseHeaders = services.ResponseHeaderRewriter(a.proxyConfig.RewriteRules, rewriteCtx)
+	return nil
+}
+
+func (a *BaseLLMAdapter) proxyHandler() *services.BaseProxyHandler {
+	return services.NewBaseProxyHandler(a.cfg, a.db, a.cacheClient, a.c, a.proxyConfig.Slug, a.action)
+}
+
+// collectQueryParams 收集客户端请求的查询参数，保留重复参数的全部值
+func (a *BaseLLMAdapter) collectQueryParams() url.Values {
+	return a.c.Request.URL.Query()
+}
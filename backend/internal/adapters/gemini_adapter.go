@@ -55,31 +55,6 @@ func (a *GeminiAdapter) ProcessRequest() (*services.TargetRequest, error) {
 	// 3. 构建目标请求 (偷梁换柱)
 	headers := utils.FilterRequestHeaders(a.c.Request.Header, []string{"x-goog-api-key", "accept-encoding"})
 
-	// 优先使用数据库中为该proxyConfig保存的APIKeyName, 否则回退到默认值
-	keyName := "x-goog-api-key"
-	if a.proxyConfig.APIKeyName != nil && *a.proxyConfig.APIKeyName != "" {
-		keyName = *a.proxyConfig.APIKeyName
-	}
-
-	// 优先使用数据库中为该proxyConfig保存的APIKeyLocation, 否则回退到默认值
-	keyLocation := "header"
-	if a.proxyConfig.APIKeyLocation != nil && *a.proxyConfig.APIKeyLocation != "" {
-		keyLocation = *a.proxyConfig.APIKeyLocation
-	}
-
-	// 根据keyLocation将key添加到headers或params
-	if keyLocation == "header" {
-		// 注入真实的Gemini Key
-		headers[keyName] = upstreamKey
-	} else if keyLocation == "query" {
-		// 将key添加到查询参数
-		if a.c.Request.URL.RawQuery == "" {
-			a.c.Request.URL.RawQuery = fmt.Sprintf("%s=%s", keyName, upstreamKey)
-		} else {
-			a.c.Request.URL.RawQuery += fmt.Sprintf("&%s=%s", keyName, upstreamKey)
-		}
-	}
-
 	// URL拼接方式也不同
 	baseURL := ""
 	if a.proxyConfig.TargetBaseURL != nil {
@@ -87,27 +62,30 @@ func (a *GeminiAdapter) ProcessRequest() (*services.TargetRequest, error) {
 	}
 	finalURL := fmt.Sprintf("%s/%s", baseURL, a.action)
 
-	// 处理查询参数
-	params := make(map[string]string)
-	for key, values := range a.c.Request.URL.Query() {
-		if len(values) > 0 {
-			params[key] = values[0]
-		}
-	}
-
 	// 读取请求体
 	body, err := io.ReadAll(a.c.Request.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read request body: %w", err)
 	}
 
-	logger.Infof("%s: Rotated to upstream key (masked): %s", a.logPrefix, utils.MaskAPIKeyDefault(upstreamKey))
-
-	return &services.TargetRequest{
+	target := &services.TargetRequest{
 		Method:  a.c.Request.Method,
 		URL:     finalURL,
 		Headers: headers,
-		Params:  params,
+		Params:  a.collectQueryParams(),
 		Body:    body,
-	}, nil
+	}
+
+	// 注入真实的Gemini Key，未配置时默认写入 'x-goog-api-key' 请求头
+	if err := a.injectUpstreamKey(target, upstreamKey, services.CredentialInjection{
+		Location: services.KeyLocationHeader,
+		Name:     "x-goog-api-key",
+		Template: services.KeyPlaceholder,
+	}); err != nil {
+		return nil, err
+	}
+
+	logger.Infof("%s: Rotated to upstream key (masked): %s", a.logPrefix, utils.MaskAPIKeyDefault(upstreamKey.KeyValue))
+
+	return target, nil
 }
@@ -89,31 +89,6 @@ func (a *OpenAIAdapter) ProcessRequest() (*services.TargetRequest, error) {
 	// 3. 构建目标请求 (偷梁换柱)
 	headers := utils.FilterRequestHeaders(a.c.Request.Header, []string{"authorization", "accept-encoding"})
 
-	// 优先使用数据库中为该proxyConfig保存的APIKeyName, 否则回退到默认值
-	keyName := "Authorization"
-	if a.proxyConfig.APIKeyName != nil && *a.proxyConfig.APIKeyName != "" {
-		keyName = *a.proxyConfig.APIKeyName
-	}
-
-	// 优先使用数据库中为该proxyConfig保存的APIKeyLocation, 否则回退到默认值
-	keyLocation := "header"
-	if a.proxyConfig.APIKeyLocation != nil && *a.proxyConfig.APIKeyLocation != "" {
-		keyLocation = *a.proxyConfig.APIKeyLocation
-	}
-
-	// 根据keyLocation将key添加到headers或params
-	if keyLocation == "header" {
-		// 添加真实的Authorization头
-		headers[keyName] = fmt.Sprintf("Bearer %s", upstreamKey)
-	} else if keyLocation == "query" {
-		// 将key添加到查询参数
-		if a.c.Request.URL.RawQuery == "" {
-			a.c.Request.URL.RawQuery = fmt.Sprintf("%s=%s", keyName, upstreamKey)
-		} else {
-			a.c.Request.URL.RawQuery += fmt.Sprintf("&%s=%s", keyName, upstreamKey)
-		}
-	}
-
 	// 从数据库获取配置好的基础URL，并移除末尾可能存在的斜杠
 	baseURL := ""
 	if a.proxyConfig.TargetBaseURL != nil {
@@ -130,21 +105,24 @@ func (a *OpenAIAdapter) ProcessRequest() (*services.TargetRequest, error) {
 
 	finalURL := fmt.Sprintf("%s/%s", baseURL, actionPath)
 
-	// 处理查询参数
-	params := make(map[string]string)
-	for key, values := range a.c.Request.URL.Query() {
-		if len(values) > 0 {
-			params[key] = values[0]
-		}
-	}
-
-	logger.Infof("%s: Rotated to upstream key (masked): %s", a.logPrefix, utils.MaskAPIKeyDefault(upstreamKey))
-
-	return &services.TargetRequest{
+	target := &services.TargetRequest{
 		Method:  a.c.Request.Method,
 		URL:     finalURL,
 		Headers: headers,
-		Params:  params,
+		Params:  a.collectQueryParams(),
 		Body:    bodyBytes,
-	}, nil
+	}
+
+	// 注入真实的上游密钥，未配置时默认使用 'Authorization: Bearer <key>'
+	if err := a.injectUpstreamKey(target, upstreamKey, services.CredentialInjection{
+		Location: services.KeyLocationHeader,
+		Name:     "Authorization",
+		Template: "Bearer " + services.KeyPlaceholder,
+	}); err != nil {
+		return nil, err
+	}
+
+	logger.Infof("%s: Rotated to upstream key (masked): %s", a.logPrefix, utils.MaskAPIKeyDefault(upstreamKey.KeyValue))
+
+	return target, nil
 }
@@ -27,6 +27,9 @@ type Config struct {
 	// JWT配置
 	JWTSecret string
 
+	// 加密存储敏感配置（如TLS客户端私钥）使用的密钥，为空时使用 JWTSecret
+	EncryptionKey string
+
 	// 管理员配置
 	AdminUsername string
 	AdminPassword string
@@ -34,9 +37,26 @@ type Config struct {
 
 	// 代理配置
 	ProxyTimeout       int
+	ProxyMaxBodySizeMB int // 转发请求体的默认大小上限（MB），0 表示不限制
 	GlobalProxyKeys    string // 逗号分隔的多个密钥，也支持单个密钥
 	ProxyPublicBaseURL string
 
+	// 访问控制配置
+	IPAllowlist      string // 逗号分隔的全局IP/CIDR白名单，为空表示不限制
+	IPDenylist       string // 逗号分隔的全局IP/CIDR黑名单，优先于白名单
+	TrustedProxies   string // 逗号分隔的可信反向代理IP/CIDR，为空表示不信任任何转发头
+	TrustedIPHeaders string // 逗号分隔的可信客户端IP转发头，按顺序解析
+
+	// 出站请求配置（SSRF防护）
+	SSRFProtection         bool   // 是否拒绝访问回环、私有、链路本地等内部地址
+	OutboundAllowedCIDRs   string // 逗号分隔的允许访问的内部IP/CIDR
+	OutboundAllowedHosts   string // 逗号分隔的允许访问的主机名，支持 "*.example.com"，不校验解析结果
+	OutboundAllowedSchemes string // 逗号分隔的允许的上游协议
+	OutboundAllowedPorts   string // 逗号分隔的允许的上游端口，为空表示不限制
+
+	// 格式转换时下载内联的远程媒体（图片、文档、音频）大小上限（MB）
+	MediaInlineMaxSizeMB int
+
 	// 日志配置
 	LogLevel string
 }
@@ -44,11 +64,63 @@ type Config struct {
 // GetGlobalProxyKeys 获取所有有效的代理密钥列表
 // 支持单个密钥或逗号分隔的多个密钥
 func (c *Config) GetGlobalProxyKeys() []string {
-	// 分割逗号分隔的密钥，并去除空白字符
-	keys := strings.Split(c.GlobalProxyKeys, ",")
+	return splitCommaList(c.GlobalProxyKeys)
+}
+
+// GetEncryptionKey 获取加密存储敏感配置使用的密钥
+func (c *Config) GetEncryptionKey() string {
+	if c.EncryptionKey != "" {
+		return c.EncryptionKey
+	}
+	return c.JWTSecret
+}
+
+// GetIPAllowlist 获取全局IP白名单
+func (c *Config) GetIPAllowlist() []string {
+	return splitCommaList(c.IPAllowlist)
+}
+
+// GetIPDenylist 获取全局IP黑名单
+func (c *Config) GetIPDenylist() []string {
+	return splitCommaList(c.IPDenylist)
+}
+
+// GetTrustedProxies 获取可信反向代理列表
+func (c *Config) GetTrustedProxies() []string {
+	return splitCommaList(c.TrustedProxies)
+}
+
+// GetTrustedIPHeaders 获取可信的客户端IP转发头列表
+func (c *Config) GetTrustedIPHeaders() []string {
+	return splitCommaList(c.TrustedIPHeaders)
+}
+
+// GetOutboundAllowedCIDRs 获取出站请求允许访问的内部地址段
+func (c *Config) GetOutboundAllowedCIDRs() []string {
+	return splitCommaList(c.OutboundAllowedCIDRs)
+}
+
+// GetOutboundAllowedHosts 获取出站请求允许访问的主机名
+func (c *Config) GetOutboundAllowedHosts() []string {
+	return splitCommaList(c.OutboundAllowedHosts)
+}
+
+// GetOutboundAllowedSchemes 获取出站请求允许的协议
+func (c *Config) GetOutboundAllowedSchemes() []string {
+	return splitCommaList(c.OutboundAllowedSchemes)
+}
+
+// GetOutboundAllowedPorts 获取出站请求允许的端口
+func (c *Config) GetOutboundAllowedPorts() []string {
+	return splitCommaList(c.OutboundAllowedPorts)
+}
+
+// splitCommaList 分割逗号分隔的字符串，并去除空白字符和空项
+func splitCommaList(value string) []string {
+	items := strings.Split(value, ",")
 	var result []string
-	for _, key := range keys {
-		trimmed := strings.TrimSpace(key)
+	for _, item := range items {
+		trimmed := strings.TrimSpace(item)
 		if trimmed != "" {
 			result = append(result, trimmed)
 		}
@@ -77,13 +149,29 @@ func Load() *Config {
 		RedisPassword:      getEnv("REDIS_PASSWORD", ""),
 		Port:               getEnv("BACKEND_PORT", "8000"),
 		JWTSecret:          getEnv("JWT_SECRET", "your-secret-key"),
+		EncryptionKey:      getEnv("ENCRYPTION_KEY", ""),
 		AdminUsername:      adminUsername,
 		AdminPassword:      getEnv("ADMIN_PASSWORD", "admin123"),
 		AdminUser:          adminUsername, // 别名，兼容性
 		ProxyTimeout:       getEnvAsInt("PROXY_TIMEOUT", 30),
+		ProxyMaxBodySizeMB: getEnvAsInt("PROXY_MAX_BODY_SIZE_MB", 100),
 		GlobalProxyKeys:    getEnv("GLOBAL_PROXY_KEYS", "your-global-proxy-key"),
 		ProxyPublicBaseURL: getEnv("PROXY_PUBLIC_BASE_URL", "http://localhost:8000"),
+		IPAllowlist:        getEnv("IP_ALLOWLIST", ""),
+		IPDenylist:         getEnv("IP_DENYLIST", ""),
+		TrustedProxies:     getEnv("TRUSTED_PROXIES", ""),
+		TrustedIPHeaders:   getEnv("TRUSTED_IP_HEADERS", "X-Forwarded-For,X-Real-IP"),
 		LogLevel:           getEnv("LOG_LEVEL", "info"),
+
+		// 出站请求（SSRF防护）
+		SSRFProtection:         getEnv("SSRF_PROTECTION", "true") != "false",
+		OutboundAllowedCIDRs:   getEnv("OUTBOUND_ALLOWED_CIDRS", ""),
+		OutboundAllowedHosts:   getEnv("OUTBOUND_ALLOWED_HOSTS", ""),
+		OutboundAllowedSchemes: getEnv("OUTBOUND_ALLOWED_SCHEMES", "http,https"),
+		OutboundAllowedPorts:   getEnv("OUTBOUND_ALLOWED_PORTS", ""),
+
+		// 格式转换时内联远程媒体
+		MediaInlineMaxSizeMB: getEnvAsInt("MEDIA_INLINE_MAX_SIZE_MB", 20),
 	}
 
 	return config
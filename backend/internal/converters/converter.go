@@ -1,7 +1,9 @@
 package converters
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"api-key-rotator/backend/internal/converters/formats"
 	// Import format packages to trigger their init() registration
@@ -11,12 +13,44 @@ import (
 	_ "api-key-rotator/backend/internal/converters/formats/openai_responses"
 )
 
-// Converter handles format conversion between different LLM API formats
+// Converter handles format conversion between different LLM API formats.
+// A converter keeps per-stream state, so use a new one for each stream.
 type Converter struct {
 	from       formats.FormatHandler
 	to         formats.FormatHandler
 	fromStream formats.StreamHandler
 	toStream   formats.StreamHandler
+
+	// toolIndexes maps source tool call positions (e.g. Anthropic content block
+	// indices) to sequential tool call indices in the converted stream
+	toolIndexes map[int]int
+	toolCount   int
+	// pendingToolCalls buffers tool call deltas for targets that need whole calls
+	pendingToolCalls []formats.UniversalToolCallDelta
+
+	// structuredIndexes holds source positions of structured output tool calls,
+	// which are streamed as text
+	structuredIndexes map[int]bool
+	structuredOutput  bool
+	otherToolCalls    bool
+
+	// fetchMedia inlines remote media the target format cannot reference, optional
+	fetchMedia MediaFetcher
+
+	// forceStream marks requests whose client format streams by path rather than
+	// by body (Gemini's streamGenerateContent); stream records the converted request's mode
+	forceStream bool
+	stream      bool
+
+	// upstreamStream overrides the stream mode of the converted request when set;
+	// clientStream records what the client asked for
+	upstreamStream *bool
+	clientStream   bool
+	// aggregate collects a streamed response for clients that did not ask for a stream
+	aggregate formats.UniversalResponse
+
+	// usage holds the running token usage of a converted stream
+	usage *formats.UniversalUsage
 }
 
 // NewConverter creates a new converter between two formats
@@ -40,8 +74,11 @@ func NewConverter(fromFormat, toFormat string) (*Converter, error) {
 	return &Converter{
 		from:       fromInfo.Handler,
 		to:         toInfo.Handler,
-		fromStream: fromInfo.StreamHandler,
-		toStream:   toInfo.StreamHandler,
+		fromStream: fromInfo.NewStreamHandler(),
+		toStream:   toInfo.NewStreamHandler(),
+
+		toolIndexes:       make(map[int]int),
+		structuredIndexes: make(map[int]bool),
 	}, nil
 }
 
@@ -53,9 +90,30 @@ func (c *Converter) ConvertRequest(body []byte) ([]byte, error) {
 		return nil, fmt.Errorf("parse request error: %w", err)
 	}
 
+	if c.forceStream {
+		universal.Stream = true
+	}
+	c.clientStream = universal.Stream
+	if c.upstreamStream != nil {
+		universal.Stream = *c.upstreamStream
+	}
+	c.stream = universal.Stream
+
+	if c.from.Name() == c.to.Name() {
+		// Same format: only the stream mode changes
+		return setRequestStream(c.to.Name(), body, c.stream), nil
+	}
+
+	if err := c.resolveRemoteMedia(universal); err != nil {
+		return nil, err
+	}
+
 	// Build target format from universal
 	result, err := c.to.BuildRequest(universal)
 	if err != nil {
+		if errors.Is(err, formats.ErrUnsupportedContent) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("build request error: %w", err)
 	}
 
@@ -69,6 +127,7 @@ func (c *Converter) ConvertResponse(body []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse response error: %w", err)
 	}
+	unwrapStructuredOutput(universal)
 
 	// Build target format from universal
 	result, err := c.to.BuildResponse(universal)
@@ -79,16 +138,23 @@ func (c *Converter) ConvertResponse(body []byte) ([]byte, error) {
 	return result, nil
 }
 
-// ConvertStreamChunk converts a streaming chunk from source format to target format
-func (c *Converter) ConvertStreamChunk(chunk []byte) ([]byte, error) {
+// ConvertStreamChunk converts a streaming chunk from source format to target format.
+// One source chunk may produce zero or more target events.
+func (c *Converter) ConvertStreamChunk(chunk []byte) ([][]byte, error) {
 	// Parse source format to universal
 	universal, err := c.fromStream.ParseStreamChunk(chunk)
 	if err != nil {
 		return nil, fmt.Errorf("parse stream chunk error: %w", err)
 	}
 
+	c.unwrapStructuredOutputDeltas(universal)
+	universal.ToolCalls = c.trackToolCalls(universal)
+	c.trackUsage(universal)
+
 	// Skip empty chunks
-	if universal.Delta == "" && universal.StopReason == nil && !universal.IsFirst && !universal.IsLast {
+	if universal.Delta == "" && universal.ReasoningDelta == "" && universal.ReasoningSignature == "" &&
+		len(universal.ToolCalls) == 0 && universal.StopReason == nil && universal.Usage == nil &&
+		!universal.IsFirst && !universal.IsLast {
 		return nil, nil
 	}
 
@@ -101,21 +167,124 @@ func (c *Converter) ConvertStreamChunk(chunk []byte) ([]byte, error) {
 	return result, nil
 }
 
-// GetTargetPath converts a client action path to the target API path
+// trackToolCalls renumbers tool call deltas sequentially and, for targets that
+// cannot stream partial arguments, holds them back until the final chunk
+func (c *Converter) trackToolCalls(chunk *formats.UniversalStreamChunk) []formats.UniversalToolCallDelta {
+	calls := make([]formats.UniversalToolCallDelta, 0, len(chunk.ToolCalls))
+	for _, call := range chunk.ToolCalls {
+		index, ok := c.toolIndexes[call.Index]
+		if !ok || call.ID != "" {
+			// A new ID starts a new call even if the source reuses the position (Gemini chunks)
+			index = c.toolCount
+			c.toolCount++
+			c.toolIndexes[call.Index] = index
+		}
+		call.Index = index
+		calls = append(calls, call)
+	}
+
+	streamer, ok := c.toStream.(formats.WholeToolCallStreamer)
+	if !ok || !streamer.RequiresWholeToolCalls() {
+		return calls
+	}
+
+	for _, call := range calls {
+		if call.Index < len(c.pendingToolCalls) {
+			c.pendingToolCalls[call.Index].Arguments += call.Arguments
+			continue
+		}
+		c.pendingToolCalls = append(c.pendingToolCalls, call)
+	}
+	if chunk.StopReason == nil && !chunk.IsLast {
+		return nil
+	}
+	complete := c.pendingToolCalls
+	c.pendingToolCalls = nil
+	return complete
+}
+
+// SetStream marks the request as streaming when the client asked for a stream
+// through the path instead of the body; call it before ConvertRequest
+func (c *Converter) SetStream(stream bool) {
+	c.forceStream = stream
+}
+
+// IsStreamAction reports whether a client action streams by path, as Gemini's
+// streamGenerateContent does
+func IsStreamAction(action string) bool {
+	return strings.Contains(action, ":streamGenerateContent")
+}
+
+// trackUsage folds the chunk's usage into the stream's running usage and hands
+// the running totals to the target builder
+func (c *Converter) trackUsage(chunk *formats.UniversalStreamChunk) {
+	if chunk.Usage == nil {
+		return
+	}
+	if c.usage == nil {
+		c.usage = &formats.UniversalUsage{}
+	}
+	c.usage.Merge(chunk.Usage)
+	usage := *c.usage
+	chunk.Usage = &usage
+}
+
+// StreamUsage returns the token usage reported by the converted stream so far,
+// or nil if the source has not reported any
+func (c *Converter) StreamUsage() *formats.UniversalUsage {
+	return c.usage
+}
+
+// TrackStreamUsage folds the usage of a source stream chunk into StreamUsage
+// without converting the chunk, for streams passed to the client unchanged
+func (c *Converter) TrackStreamUsage(chunk []byte) {
+	universal, err := c.fromStream.ParseStreamChunk(chunk)
+	if err != nil {
+		return
+	}
+	c.trackUsage(universal)
+}
+
+// ResponseUsage returns the token usage of a whole source-format response, or
+// nil if the response reports none or cannot be parsed
+func (c *Converter) ResponseUsage(body []byte) *formats.UniversalUsage {
+	resp, err := c.from.ParseResponse(body)
+	if err != nil {
+		return nil
+	}
+	return resp.Usage
+}
+
+// GetTargetPath converts a client action path to the target API path.
+// Call it after ConvertRequest so the path matches the converted request's stream mode.
 func (c *Converter) GetTargetPath(action string) string {
 	// First convert from client format's perspective
 	// Then convert to target format's API path
-	return c.to.GetAPIPath(action)
+	return c.to.GetAPIPath(action, c.stream)
 }
 
-// GetStreamStartEvents returns the start events needed for the target format
-func (c *Converter) GetStreamStartEvents(model, id string) [][]byte {
-	return c.toStream.BuildStartEvent(model, id)
+// StreamEventName returns the SSE event name of a converted stream event, or ""
+// when the target format uses unnamed events
+func (c *Converter) StreamEventName(data []byte) string {
+	if namer, ok := c.toStream.(formats.NamedEventStreamer); ok {
+		return namer.EventName(data)
+	}
+	return ""
 }
 
-// GetStreamEndEvents returns the end events needed for the target format
+// GetStreamEndEvents closes the converted stream: it sends tool calls still held
+// back for the target and the end events of the target format. Call it when the
+// source stream ends; later calls return nothing.
 func (c *Converter) GetStreamEndEvents() [][]byte {
-	return c.toStream.BuildEndEvent()
+	var events [][]byte
+	if len(c.pendingToolCalls) > 0 {
+		pending := &formats.UniversalStreamChunk{ToolCalls: c.pendingToolCalls}
+		c.pendingToolCalls = nil
+		if result, err := c.toStream.BuildStreamChunk(pending); err == nil {
+			events = append(events, result...)
+		}
+	}
+	return append(events, c.toStream.BuildEndEvent()...)
 }
 
 // NormalizeFormat converts api_format values to standard format names
@@ -132,6 +301,25 @@ func NormalizeFormat(format string) string {
 	}
 }
 
+// DetectClientFormat infers the client's request format from the action path
+// of a unified endpoint request, e.g. "v1/chat/completions" -> "openai".
+// It returns an empty string when the path is not a known chat endpoint.
+func DetectClientFormat(action string) string {
+	action = strings.TrimSuffix(action, "/")
+	switch {
+	case strings.HasSuffix(action, "chat/completions"):
+		return "openai"
+	case strings.HasSuffix(action, "messages"):
+		return "anthropic"
+	case strings.HasSuffix(action, "responses"):
+		return "openai_responses"
+	case strings.Contains(action, ":generateContent"), strings.Contains(action, ":streamGenerateContent"):
+		return "gemini"
+	default:
+		return ""
+	}
+}
+
 // NeedsConversion checks if conversion is needed between two formats
 func NeedsConversion(clientFormat, apiFormat string) bool {
 	if clientFormat == "none" || clientFormat == "" {
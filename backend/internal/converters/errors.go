@@ -0,0 +1,88 @@
+package converters
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// BuildErrorResponse builds an error body in the given client format so that
+// proxy-generated errors (timeouts, upstream failures) look like native API errors.
+// Unknown formats fall back to the proxy's own {"detail": ...} shape.
+func BuildErrorResponse(format string, status int, message string) []byte {
+	var payload interface{}
+
+	switch NormalizeFormat(format) {
+	case "openai", "openai_responses":
+		payload = map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": message,
+				"type":    openAIErrorType(status),
+				"code":    nil,
+			},
+		}
+	case "anthropic":
+		payload = map[string]interface{}{
+			"type": "error",
+			"error": map[string]interface{}{
+				"type":    anthropicErrorType(status),
+				"message": message,
+			},
+		}
+	case "gemini":
+		payload = map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    status,
+				"message": message,
+				"status":  geminiErrorStatus(status),
+			},
+		}
+	default:
+		payload = map[string]interface{}{"detail": message}
+	}
+
+	body, _ := json.Marshal(payload)
+	return body
+}
+
+func openAIErrorType(status int) string {
+	switch status {
+	case http.StatusGatewayTimeout:
+		return "timeout_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return "invalid_request_error"
+	default:
+		return "api_error"
+	}
+}
+
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusGatewayTimeout:
+		return "timeout_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return "invalid_request_error"
+	case http.StatusServiceUnavailable:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+func geminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return "INVALID_ARGUMENT"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	default:
+		return "INTERNAL"
+	}
+}
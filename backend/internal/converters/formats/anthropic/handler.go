@@ -1,14 +1,16 @@
 package anthropic
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"api-key-rotator/backend/internal/converters/formats"
 )
 
 func init() {
-	formats.RegisterFormat("anthropic", &Handler{}, &StreamHandler{})
+	formats.RegisterFormat("anthropic", &Handler{}, func() formats.StreamHandler { return &StreamHandler{} })
 }
 
 // Handler implements FormatHandler for Anthropic format
@@ -28,6 +30,36 @@ type Request struct {
 	TopP          *float64         `json:"top_p,omitempty"`
 	Stream        bool             `json:"stream,omitempty"`
 	StopSequences []string         `json:"stop_sequences,omitempty"`
+	Tools         []Tool           `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice      `json:"tool_choice,omitempty"`
+	Thinking      *Thinking        `json:"thinking,omitempty"`
+	OutputFormat  *OutputFormat    `json:"output_format,omitempty"`
+}
+
+// OutputFormat is the structured outputs beta; it is only read from clients,
+// requests to Anthropic use a forced tool call instead
+type OutputFormat struct {
+	Type   string          `json:"type"` // "json_schema"
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// Thinking configures extended thinking
+type Thinking struct {
+	Type         string `json:"type"` // "enabled", "disabled" or "adaptive"
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// Tool types
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type ToolChoice struct {
+	Type                   string `json:"type"` // "auto", "any", "tool", "none"
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
 }
 
 type RequestMessage struct {
@@ -47,9 +79,36 @@ type Response struct {
 	Usage        *Usage    `json:"usage,omitempty"`
 }
 
+// Content is a content block: "text", "thinking", "image", "document", "tool_use" or "tool_result"
 type Content struct {
 	Type string `json:"type"`
-	Text string `json:"text"`
+	Text string `json:"text,omitempty"`
+
+	// thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// image and document
+	Source *Source `json:"source,omitempty"`
+	Title  string  `json:"title,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	Content   interface{} `json:"content,omitempty"`
+	IsError   bool        `json:"is_error,omitempty"`
+}
+
+// Source is the data of an image or document block
+type Source struct {
+	Type      string `json:"type"` // "base64", "url" or "text"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
 type Usage struct {
@@ -76,12 +135,41 @@ func (h *Handler) ParseRequest(body []byte) (*formats.UniversalRequest, error) {
 
 	universal.System = extractSystemContent(req.System)
 
-	for _, msg := range req.Messages {
-		universal.Messages = append(universal.Messages, formats.UniversalMessage{
-			Role:    msg.Role,
-			Content: extractTextContent(msg.Content),
+	for _, tool := range req.Tools {
+		universal.Tools = append(universal.Tools, formats.UniversalTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.InputSchema,
 		})
 	}
+	if req.ToolChoice != nil {
+		universal.ToolChoice = parseToolChoice(req.ToolChoice)
+		if req.ToolChoice.DisableParallelToolUse {
+			parallel := false
+			universal.ParallelToolCalls = &parallel
+		}
+	}
+
+	if req.OutputFormat != nil && req.OutputFormat.Type == formats.ResponseFormatJSONSchema {
+		universal.ResponseFormat = &formats.UniversalResponseFormat{
+			Type:   formats.ResponseFormatJSONSchema,
+			Schema: req.OutputFormat.Schema,
+		}
+	}
+	if req.Thinking != nil {
+		switch req.Thinking.Type {
+		case "enabled":
+			universal.Reasoning = &formats.UniversalReasoning{BudgetTokens: req.Thinking.BudgetTokens}
+		case "disabled":
+			universal.Reasoning = &formats.UniversalReasoning{Effort: formats.ReasoningEffortNone}
+		default:
+			universal.Reasoning = &formats.UniversalReasoning{}
+		}
+	}
+
+	for _, msg := range req.Messages {
+		universal.Messages = append(universal.Messages, parseMessage(msg)...)
+	}
 
 	return universal, nil
 }
@@ -107,16 +195,63 @@ func (h *Handler) BuildRequest(req *formats.UniversalRequest) ([]byte, error) {
 		anthropicReq.System = req.System
 	}
 
+	if req.Reasoning != nil {
+		anthropicReq.Thinking = buildThinking(req.Reasoning)
+		if anthropicReq.Thinking.Type == "enabled" && fitThinkingBudget(&anthropicReq, req.MaxTokens > 0) {
+			// Thinking is incompatible with temperature and restricts top_p
+			anthropicReq.Temperature = nil
+			anthropicReq.TopP = nil
+		}
+	}
+
+	tools := req.Tools
+	if req.ResponseFormat.IsJSON() {
+		// Anthropic has no JSON output mode; emulate it with a tool call that the
+		// converter turns back into text content
+		tools = append(tools[:len(tools):len(tools)], req.ResponseFormat.StructuredOutputTool())
+	}
+	for _, tool := range tools {
+		schema := tool.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		anthropicReq.Tools = append(anthropicReq.Tools, Tool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: schema,
+		})
+	}
+	anthropicReq.ToolChoice = buildToolChoice(req.ToolChoice, req.ParallelToolCalls)
+	if req.ResponseFormat.IsJSON() {
+		thinking := anthropicReq.Thinking != nil && anthropicReq.Thinking.Type == "enabled"
+		anthropicReq.ToolChoice = structuredOutputToolChoice(anthropicReq.ToolChoice, len(req.Tools) > 0, thinking)
+	}
+
 	for _, msg := range req.Messages {
+		role, content, err := buildMessageContent(msg)
+		if err != nil {
+			return nil, err
+		}
+		// Tool results are sent as user messages; merge consecutive messages of the same role
+		if n := len(anthropicReq.Messages); n > 0 && anthropicReq.Messages[n-1].Role == role {
+			previous := &anthropicReq.Messages[n-1]
+			previous.Content = append(contentBlocks(previous.Content), contentBlocks(content)...)
+			continue
+		}
 		anthropicReq.Messages = append(anthropicReq.Messages, RequestMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+			Role:    role,
+			Content: content,
 		})
 	}
 
 	return json.Marshal(anthropicReq)
 }
 
+// AcceptsMediaURL implements formats.MediaURLAcceptor; images and PDF documents can be passed by URL
+func (h *Handler) AcceptsMediaURL(part formats.UniversalContentPart) bool {
+	return part.Type == formats.ContentPartImage || part.Type == formats.ContentPartDocument
+}
+
 // ParseResponse implements FormatHandler
 func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error) {
 	var resp Response
@@ -131,13 +266,23 @@ func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error)
 	}
 
 	for _, content := range resp.Content {
-		if content.Type == "text" {
+		switch content.Type {
+		case "text":
 			universal.Content += content.Text
+		case "thinking":
+			universal.Reasoning += content.Thinking
+			universal.ReasoningSignature = content.Signature
+		case "tool_use":
+			universal.ToolCalls = append(universal.ToolCalls, formats.UniversalToolCall{
+				ID:        content.ID,
+				Name:      content.Name,
+				Arguments: string(formats.ToolArgumentsObject(string(content.Input))),
+			})
 		}
 	}
 
 	if resp.StopReason != nil {
-		universal.StopReason = *resp.StopReason
+		universal.StopReason = mapAnthropicStopReason(*resp.StopReason)
 	}
 
 	if resp.Usage != nil {
@@ -153,17 +298,7 @@ func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error)
 
 // BuildResponse implements FormatHandler
 func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error) {
-	stopReason := resp.StopReason
-	if stopReason == "" {
-		stopReason = "end_turn"
-	} else {
-		switch stopReason {
-		case "stop":
-			stopReason = "end_turn"
-		case "length":
-			stopReason = "max_tokens"
-		}
-	}
+	stopReason := mapToAnthropicStopReason(resp.StopReason)
 
 	anthropicResp := Response{
 		ID:         resp.ID,
@@ -171,7 +306,21 @@ func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error)
 		Role:       "assistant",
 		Model:      resp.Model,
 		StopReason: &stopReason,
-		Content:    []Content{{Type: "text", Text: resp.Content}},
+		Content:    []Content{},
+	}
+
+	if resp.Reasoning != "" {
+		anthropicResp.Content = append(anthropicResp.Content, Content{
+			Type:      "thinking",
+			Thinking:  resp.Reasoning,
+			Signature: resp.ReasoningSignature,
+		})
+	}
+	if resp.Content != "" || len(resp.ToolCalls) == 0 {
+		anthropicResp.Content = append(anthropicResp.Content, Content{Type: "text", Text: resp.Content})
+	}
+	for _, call := range resp.ToolCalls {
+		anthropicResp.Content = append(anthropicResp.Content, toolUseBlock(call))
 	}
 
 	if resp.Usage != nil {
@@ -187,9 +336,11 @@ func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error)
 }
 
 // GetAPIPath implements FormatHandler
-func (h *Handler) GetAPIPath(action string) string {
-	switch action {
-	case "chat/completions", "v1/chat/completions":
+func (h *Handler) GetAPIPath(action string, stream bool) string {
+	switch {
+	case action == "chat/completions", action == "v1/chat/completions",
+		action == "responses", action == "v1/responses",
+		strings.Contains(action, ":generateContent"), strings.Contains(action, ":streamGenerateContent"):
 		return "v1/messages"
 	default:
 		return action
@@ -228,6 +379,315 @@ func extractSystemContent(system interface{}) string {
 	}
 }
 
+// parseMessage converts an Anthropic message into universal messages. Tool
+// results in a user message become a separate "tool" message placed before any
+// remaining user text, since they answer the preceding assistant tool calls.
+func parseMessage(msg RequestMessage) []formats.UniversalMessage {
+	blocks, ok := msg.Content.([]interface{})
+	if !ok {
+		return []formats.UniversalMessage{{Role: msg.Role, Content: extractTextContent(msg.Content)}}
+	}
+
+	message := formats.UniversalMessage{Role: msg.Role}
+	var parts []formats.UniversalContentPart
+	var results []formats.UniversalToolResult
+	for _, block := range blocks {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch blockMap["type"] {
+		case "text":
+			if t, ok := blockMap["text"].(string); ok {
+				parts = append(parts, formats.UniversalContentPart{Type: formats.ContentPartText, Text: t})
+			}
+		case "image", "document":
+			if part, ok := parseSourceBlock(blockMap); ok {
+				parts = append(parts, part)
+			}
+		case "tool_use":
+			id, _ := blockMap["id"].(string)
+			name, _ := blockMap["name"].(string)
+			arguments, _ := json.Marshal(blockMap["input"])
+			message.ToolCalls = append(message.ToolCalls, formats.UniversalToolCall{
+				ID:        id,
+				Name:      name,
+				Arguments: string(formats.ToolArgumentsObject(string(arguments))),
+			})
+		case "tool_result":
+			id, _ := blockMap["tool_use_id"].(string)
+			isError, _ := blockMap["is_error"].(bool)
+			results = append(results, formats.UniversalToolResult{
+				ToolCallID: id,
+				Content:    extractTextContent(blockMap["content"]),
+				IsError:    isError,
+			})
+		}
+	}
+
+	message.SetContentParts(parts)
+
+	var messages []formats.UniversalMessage
+	if len(results) > 0 {
+		messages = append(messages, formats.UniversalMessage{Role: "tool", ToolResults: results})
+		if message.Content == "" && !message.HasMedia() && len(message.ToolCalls) == 0 {
+			return messages
+		}
+	}
+	return append(messages, message)
+}
+
+// parseSourceBlock converts an image or document block to a universal content part
+func parseSourceBlock(block map[string]interface{}) (formats.UniversalContentPart, bool) {
+	source, ok := block["source"].(map[string]interface{})
+	if !ok {
+		return formats.UniversalContentPart{}, false
+	}
+	partType := formats.ContentPartImage
+	if block["type"] == "document" {
+		partType = formats.ContentPartDocument
+	}
+	mediaType, _ := source["media_type"].(string)
+	data, _ := source["data"].(string)
+	url, _ := source["url"].(string)
+
+	var part formats.UniversalContentPart
+	switch source["type"] {
+	case "base64":
+		part = formats.MediaPart(partType, mediaType, data, "")
+	case "url":
+		part = formats.MediaPart(partType, mediaType, "", url)
+	case "text":
+		part = formats.MediaPart(partType, "text/plain", formats.TextDocumentData(data), "")
+	default:
+		return formats.UniversalContentPart{}, false
+	}
+	part.Filename, _ = block["title"].(string)
+	return part, true
+}
+
+// buildSourceBlock converts an image or document part to an Anthropic content block
+func buildSourceBlock(part formats.UniversalContentPart) (Content, error) {
+	block := Content{Type: "image"}
+	if part.Type == formats.ContentPartDocument {
+		block.Type = "document"
+		block.Title = part.Filename
+	}
+
+	switch {
+	case part.Data == "":
+		block.Source = &Source{Type: "url", URL: part.URL}
+	case part.Type == formats.ContentPartDocument && part.MediaType == "text/plain":
+		text, err := base64.StdEncoding.DecodeString(part.Data)
+		if err != nil {
+			return Content{}, fmt.Errorf("invalid base64 document data: %w", err)
+		}
+		block.Source = &Source{Type: "text", MediaType: part.MediaType, Data: string(text)}
+	default:
+		block.Source = &Source{Type: "base64", MediaType: formats.GuessMediaType(part), Data: part.Data}
+	}
+	return block, nil
+}
+
+// buildMessageContent returns the Anthropic role and content for a universal message.
+// Plain text messages keep string content; media, tool calls and results use content blocks.
+func buildMessageContent(msg formats.UniversalMessage) (string, interface{}, error) {
+	if msg.Role == "tool" {
+		blocks := make([]Content, 0, len(msg.ToolResults))
+		for _, result := range msg.ToolResults {
+			blocks = append(blocks, Content{
+				Type:      "tool_result",
+				ToolUseID: result.ToolCallID,
+				Content:   result.Content,
+				IsError:   result.IsError,
+			})
+		}
+		return "user", blocks, nil
+	}
+
+	if len(msg.ToolCalls) == 0 && !msg.HasMedia() {
+		return msg.Role, msg.Content, nil
+	}
+	parts := msg.ContentParts()
+	blocks := make([]Content, 0, len(parts)+len(msg.ToolCalls))
+	for _, part := range parts {
+		switch part.Type {
+		case formats.ContentPartText:
+			if part.Text != "" {
+				blocks = append(blocks, Content{Type: "text", Text: part.Text})
+			}
+		case formats.ContentPartImage, formats.ContentPartDocument:
+			block, err := buildSourceBlock(part)
+			if err != nil {
+				return "", nil, err
+			}
+			blocks = append(blocks, block)
+		default:
+			return "", nil, fmt.Errorf("%w: Anthropic does not accept %s input", formats.ErrUnsupportedContent, part.Type)
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		blocks = append(blocks, toolUseBlock(call))
+	}
+	return msg.Role, blocks, nil
+}
+
+// contentBlocks converts message content to a block list so messages can be merged
+func contentBlocks(content interface{}) []Content {
+	switch c := content.(type) {
+	case []Content:
+		return c
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []Content{{Type: "text", Text: c}}
+	default:
+		return nil
+	}
+}
+
+func toolUseBlock(call formats.UniversalToolCall) Content {
+	return Content{
+		Type:  "tool_use",
+		ID:    call.ID,
+		Name:  call.Name,
+		Input: formats.ToolArgumentsObject(call.Arguments),
+	}
+}
+
+func parseToolChoice(choice *ToolChoice) *formats.UniversalToolChoice {
+	switch choice.Type {
+	case "any":
+		return &formats.UniversalToolChoice{Mode: formats.ToolChoiceRequired}
+	case "tool":
+		return &formats.UniversalToolChoice{Mode: formats.ToolChoiceTool, Name: choice.Name}
+	case "none":
+		return &formats.UniversalToolChoice{Mode: formats.ToolChoiceNone}
+	default:
+		return &formats.UniversalToolChoice{Mode: formats.ToolChoiceAuto}
+	}
+}
+
+func buildToolChoice(choice *formats.UniversalToolChoice, parallelToolCalls *bool) *ToolChoice {
+	disableParallel := parallelToolCalls != nil && !*parallelToolCalls
+	if choice == nil {
+		if !disableParallel {
+			return nil
+		}
+		return &ToolChoice{Type: "auto", DisableParallelToolUse: true}
+	}
+
+	var result *ToolChoice
+	switch choice.Mode {
+	case formats.ToolChoiceRequired:
+		result = &ToolChoice{Type: "any"}
+	case formats.ToolChoiceTool:
+		result = &ToolChoice{Type: "tool", Name: choice.Name}
+	case formats.ToolChoiceNone:
+		return &ToolChoice{Type: "none"}
+	default:
+		result = &ToolChoice{Type: "auto"}
+	}
+	result.DisableParallelToolUse = disableParallel
+	return result
+}
+
+// structuredOutputToolChoice makes the model answer through the structured output
+// tool. With other tools available it must call any tool, so it can still use
+// them before answering. Thinking does not allow forcing tool use, so the
+// choice stays "auto" and the tool description asks for the call instead.
+func structuredOutputToolChoice(choice *ToolChoice, hasTools, thinking bool) *ToolChoice {
+	result := &ToolChoice{Type: "tool", Name: formats.StructuredOutputToolName}
+	if choice != nil {
+		result.DisableParallelToolUse = choice.DisableParallelToolUse
+	}
+	switch {
+	case thinking:
+		result.Type, result.Name = "auto", ""
+	case hasTools && choice != nil && choice.Type != "auto" && choice.Type != "none":
+		// Keep a required or specific tool choice
+		return choice
+	case hasTools && (choice == nil || choice.Type == "auto"):
+		result.Type, result.Name = "any", ""
+	}
+	return result
+}
+
+// minThinkingBudget is the smallest thinking budget Anthropic accepts
+const minThinkingBudget = 1024
+
+// minAnswerTokens is the part of max_tokens kept for the answer when thinking is enabled
+const minAnswerTokens = 1024
+
+// defaultThinkingBudget is used when reasoning is enabled without a budget or effort
+const defaultThinkingBudget = 8192
+
+func buildThinking(reasoning *formats.UniversalReasoning) *Thinking {
+	if reasoning.Disabled() {
+		return &Thinking{Type: "disabled"}
+	}
+	budget := reasoning.Budget()
+	switch {
+	case budget == 0:
+		budget = defaultThinkingBudget
+	case budget < minThinkingBudget:
+		budget = minThinkingBudget
+	}
+	return &Thinking{Type: "enabled", BudgetTokens: budget}
+}
+
+// fitThinkingBudget keeps the thinking budget below max_tokens, which it counts
+// towards, with at least minAnswerTokens left for the answer. A max_tokens given
+// by the client is a hard cap: the budget shrinks to fit under it, and thinking is
+// disabled when not even the minimum budget fits. Without a client cap the default
+// max_tokens is raised to leave room for the answer on top of the budget.
+// It reports whether thinking is still enabled.
+func fitThinkingBudget(req *Request, clientCapped bool) bool {
+	if req.Thinking.BudgetTokens+minAnswerTokens <= req.MaxTokens {
+		return true
+	}
+	if !clientCapped {
+		req.MaxTokens += req.Thinking.BudgetTokens
+		return true
+	}
+	budget := req.MaxTokens - minAnswerTokens
+	if budget < minThinkingBudget {
+		req.Thinking = &Thinking{Type: "disabled"}
+		return false
+	}
+	req.Thinking.BudgetTokens = budget
+	return true
+}
+
+// mapAnthropicStopReason converts an Anthropic stop_reason to the universal (OpenAI style) value
+func mapAnthropicStopReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return reason
+	}
+}
+
+// mapToAnthropicStopReason converts a universal stop reason to an Anthropic stop_reason
+func mapToAnthropicStopReason(reason string) string {
+	switch reason {
+	case "", "stop":
+		return "end_turn"
+	case "length":
+		return "max_tokens"
+	case "tool_calls":
+		return "tool_use"
+	default:
+		return reason
+	}
+}
+
 func extractTextContent(content interface{}) string {
 	switch c := content.(type) {
 	case string:
@@ -5,10 +5,25 @@ import (
 	"fmt"
 
 	"api-key-rotator/backend/internal/converters/formats"
+
+	"github.com/google/uuid"
 )
 
 // StreamHandler implements formats.StreamHandler for Anthropic format
-type StreamHandler struct{}
+type StreamHandler struct {
+	started  bool
+	finished bool
+
+	// block is the index of the last started content block (-1 before the first),
+	// openType the type of the open block or "" when no block is open
+	block    int
+	openType string
+	// toolBlocks maps tool call indices to their content block indices
+	toolBlocks map[int]int
+	stopReason *string
+	// usage is reported in message_start (input tokens) and message_delta (totals)
+	usage *formats.UniversalUsage
+}
 
 func (h *StreamHandler) Name() string {
 	return "anthropic"
@@ -25,12 +40,25 @@ type StreamEvent struct {
 }
 
 type StreamDelta struct {
-	Type       string  `json:"type,omitempty"`
-	Text       string  `json:"text,omitempty"`
-	StopReason *string `json:"stop_reason,omitempty"`
+	Type        string  `json:"type,omitempty"`
+	Text        string  `json:"text,omitempty"`
+	PartialJSON string  `json:"partial_json,omitempty"` // input_json_delta fragment of tool_use input
+	Thinking    string  `json:"thinking,omitempty"`     // thinking_delta
+	Signature   string  `json:"signature,omitempty"`    // signature_delta
+	StopReason  *string `json:"stop_reason,omitempty"`
 }
 
+// contentBlockStartEvent starts a content block; blocks are built with their
+// empty initial fields, e.g. "text": ""
+type contentBlockStartEvent struct {
+	Type         string      `json:"type"`
+	Index        int         `json:"index"`
+	ContentBlock interface{} `json:"content_block"`
+}
+
+// StreamUsage is the cumulative usage in message_delta
 type StreamUsage struct {
+	InputTokens  int `json:"input_tokens,omitempty"`
 	OutputTokens int `json:"output_tokens"`
 }
 
@@ -50,16 +78,51 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 			universal.Model = event.Message.Model
 			universal.Role = event.Message.Role
 			universal.IsFirst = true
+			if event.Message.Usage != nil {
+				universal.Usage = &formats.UniversalUsage{
+					InputTokens:  event.Message.Usage.InputTokens,
+					OutputTokens: event.Message.Usage.OutputTokens,
+				}
+			}
+		}
+	case "content_block_start":
+		if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+			universal.ToolCalls = []formats.UniversalToolCallDelta{{
+				Index: eventIndex(event),
+				ID:    event.ContentBlock.ID,
+				Name:  event.ContentBlock.Name,
+			}}
 		}
 	case "content_block_delta":
 		if event.Delta != nil {
-			universal.Delta = event.Delta.Text
+			switch event.Delta.Type {
+			case "thinking_delta":
+				universal.ReasoningDelta = event.Delta.Thinking
+			case "signature_delta":
+				universal.ReasoningSignature = event.Delta.Signature
+			case "input_json_delta":
+				if event.Delta.PartialJSON != "" {
+					universal.ToolCalls = []formats.UniversalToolCallDelta{{
+						Index:     eventIndex(event),
+						Arguments: event.Delta.PartialJSON,
+					}}
+				}
+			default:
+				universal.Delta = event.Delta.Text
+			}
 		}
 	case "message_delta":
 		if event.Delta != nil && event.Delta.StopReason != nil {
-			universal.StopReason = event.Delta.StopReason
+			stopReason := mapAnthropicStopReason(*event.Delta.StopReason)
+			universal.StopReason = &stopReason
 			universal.IsLast = true
 		}
+		if event.Usage != nil {
+			universal.Usage = &formats.UniversalUsage{
+				InputTokens:  event.Usage.InputTokens,
+				OutputTokens: event.Usage.OutputTokens,
+			}
+		}
 	case "message_stop":
 		universal.IsLast = true
 	}
@@ -67,56 +130,136 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 	return universal, nil
 }
 
+// EventName implements formats.NamedEventStreamer; events are named after their type
+func (h *StreamHandler) EventName(data []byte) string {
+	var event struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return ""
+	}
+	return event.Type
+}
+
 // BuildStreamChunk implements StreamHandler
-func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([]byte, error) {
+// Thinking, text and each tool call are written to their own content block.
+// A block is closed when content of another kind starts.
+func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([][]byte, error) {
+	if h.finished {
+		return nil, nil
+	}
+	if chunk.Usage != nil {
+		h.usage = chunk.Usage
+	}
+	events := h.startEvents(chunk.Model, chunk.ID)
+
+	if chunk.ReasoningDelta != "" {
+		events = append(events, h.openBlock("thinking", map[string]string{"type": "thinking", "thinking": ""})...)
+		events = append(events, blockDelta(h.block, &StreamDelta{
+			Type:     "thinking_delta",
+			Thinking: chunk.ReasoningDelta,
+		}))
+	}
+	if chunk.ReasoningSignature != "" {
+		events = append(events, h.openBlock("thinking", map[string]string{"type": "thinking", "thinking": ""})...)
+		events = append(events, blockDelta(h.block, &StreamDelta{
+			Type:      "signature_delta",
+			Signature: chunk.ReasoningSignature,
+		}))
+	}
+
 	if chunk.Delta != "" {
-		event := StreamEvent{
-			Type:  "content_block_delta",
-			Index: intPtr(0),
-			Delta: &StreamDelta{
-				Type: "text_delta",
-				Text: chunk.Delta,
-			},
-		}
-		return json.Marshal(event)
+		events = append(events, h.openBlock("text", map[string]string{"type": "text", "text": ""})...)
+		events = append(events, blockDelta(h.block, &StreamDelta{
+			Type: "text_delta",
+			Text: chunk.Delta,
+		}))
 	}
 
-	if chunk.StopReason != nil {
-		stopReason := *chunk.StopReason
-		switch stopReason {
-		case "stop":
-			stopReason = "end_turn"
-		case "length":
-			stopReason = "max_tokens"
-		}
-		event := StreamEvent{
-			Type:  "message_delta",
-			Delta: &StreamDelta{StopReason: &stopReason},
+	for _, call := range chunk.ToolCalls {
+		index, ok := h.toolBlocks[call.Index]
+		if call.ID != "" || call.Name != "" || !ok {
+			id := call.ID
+			if id == "" {
+				id = "toolu_" + uuid.New().String()[:12]
+			}
+			events = append(events, h.openBlock("tool_use", &Content{
+				Type:  "tool_use",
+				ID:    id,
+				Name:  call.Name,
+				Input: json.RawMessage("{}"),
+			})...)
+			index = h.block
+			if h.toolBlocks == nil {
+				h.toolBlocks = make(map[int]int)
+			}
+			h.toolBlocks[call.Index] = index
 		}
-		return json.Marshal(event)
-	}
-
-	if chunk.IsFirst {
-		event := StreamEvent{
-			Type: "message_start",
-			Message: &Response{
-				ID:    chunk.ID,
-				Type:  "message",
-				Role:  "assistant",
-				Model: chunk.Model,
-			},
+		if call.Arguments != "" {
+			events = append(events, blockDelta(index, &StreamDelta{
+				Type:        "input_json_delta",
+				PartialJSON: call.Arguments,
+			}))
 		}
-		return json.Marshal(event)
 	}
 
-	return nil, nil
+	if chunk.StopReason != nil {
+		// message_delta and message_stop follow in BuildEndEvent, after any trailing usage
+		stopReason := mapToAnthropicStopReason(*chunk.StopReason)
+		h.stopReason = &stopReason
+		events = append(events, h.closeBlock()...)
+	}
+
+	return marshalEvents(events)
 }
 
-// BuildStartEvent implements StreamHandler
-func (h *StreamHandler) BuildStartEvent(model string, id string) [][]byte {
-	events := make([][]byte, 0)
+// BuildEndEvent implements StreamHandler
+func (h *StreamHandler) BuildEndEvent() [][]byte {
+	if h.finished {
+		return nil
+	}
+	events := h.startEvents("", "")
+	events = append(events, h.closeBlock()...)
 
-	messageStart := StreamEvent{
+	stopReason := h.stopReason
+	if stopReason == nil {
+		endTurn := "end_turn"
+		stopReason = &endTurn
+	}
+	usage := &StreamUsage{}
+	if h.usage != nil {
+		usage.InputTokens = h.usage.InputTokens
+		usage.OutputTokens = h.usage.OutputTokens
+	}
+	events = append(events,
+		StreamEvent{
+			Type:  "message_delta",
+			Delta: &StreamDelta{StopReason: stopReason},
+			Usage: usage,
+		},
+		StreamEvent{Type: "message_stop"},
+	)
+	h.finished = true
+
+	result, _ := marshalEvents(events)
+	return result
+}
+
+// startEvents returns the message_start event the first time it is called
+func (h *StreamHandler) startEvents(model, id string) []interface{} {
+	if h.started {
+		return nil
+	}
+	h.started = true
+	h.block = -1
+	if id == "" {
+		id = "msg_" + uuid.New().String()[:12]
+	}
+	usage := &Usage{}
+	if h.usage != nil {
+		usage.InputTokens = h.usage.InputTokens
+	}
+	return []interface{}{StreamEvent{
 		Type: "message_start",
 		Message: &Response{
 			ID:      id,
@@ -124,41 +267,59 @@ func (h *StreamHandler) BuildStartEvent(model string, id string) [][]byte {
 			Role:    "assistant",
 			Model:   model,
 			Content: []Content{},
+			Usage:   usage,
 		},
-	}
-	if data, err := json.Marshal(messageStart); err == nil {
-		events = append(events, data)
-	}
+	}}
+}
 
-	contentStart := map[string]interface{}{
-		"type":          "content_block_start",
-		"index":         0,
-		"content_block": map[string]string{"type": "text", "text": ""},
+// openBlock starts a new content block unless a text or thinking block of the
+// same type is open; every tool call gets its own block
+func (h *StreamHandler) openBlock(blockType string, contentBlock interface{}) []interface{} {
+	if h.openType == blockType && blockType != "tool_use" {
+		return nil
 	}
-	if data, err := json.Marshal(contentStart); err == nil {
-		events = append(events, data)
-	}
-
-	return events
+	events := h.closeBlock()
+	h.block++
+	h.openType = blockType
+	return append(events, contentBlockStartEvent{
+		Type:         "content_block_start",
+		Index:        h.block,
+		ContentBlock: contentBlock,
+	})
 }
 
-// BuildEndEvent implements StreamHandler
-func (h *StreamHandler) BuildEndEvent() [][]byte {
-	events := make([][]byte, 0)
-
-	contentStop := map[string]interface{}{"type": "content_block_stop", "index": 0}
-	if data, err := json.Marshal(contentStop); err == nil {
-		events = append(events, data)
+// closeBlock stops the open content block, if any
+func (h *StreamHandler) closeBlock() []interface{} {
+	if h.openType == "" {
+		return nil
 	}
+	h.openType = ""
+	return []interface{}{StreamEvent{Type: "content_block_stop", Index: intPtr(h.block)}}
+}
 
-	messageStop := map[string]string{"type": "message_stop"}
-	if data, err := json.Marshal(messageStop); err == nil {
-		events = append(events, data)
-	}
+func blockDelta(index int, delta *StreamDelta) StreamEvent {
+	return StreamEvent{Type: "content_block_delta", Index: intPtr(index), Delta: delta}
+}
 
-	return events
+func marshalEvents(events []interface{}) ([][]byte, error) {
+	result := make([][]byte, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, nil
 }
 
 func intPtr(i int) *int {
 	return &i
 }
+
+func eventIndex(event StreamEvent) int {
+	if event.Index == nil {
+		return 0
+	}
+	return *event.Index
+}
@@ -1,5 +1,11 @@
 package formats
 
+import "errors"
+
+// ErrUnsupportedContent is returned when a request carries content the target
+// format has no way to represent, such as audio sent to Anthropic
+var ErrUnsupportedContent = errors.New("unsupported content")
+
 // FormatHandler defines the interface for handling request/response format conversion
 // Each format (OpenAI, Anthropic, Gemini) implements this interface
 type FormatHandler interface {
@@ -19,31 +25,58 @@ type FormatHandler interface {
 	BuildResponse(resp *UniversalResponse) ([]byte, error)
 
 	// Path mapping
-	// GetAPIPath converts a client action to the format's API path
-	GetAPIPath(action string) string
+	// GetAPIPath converts a client action to the format's API path; stream tells
+	// whether the converted request streams, for formats that stream on a separate path
+	GetAPIPath(action string, stream bool) string
 	// GetClientAction converts an API path to a client action
 	GetClientAction(apiPath string) string
 }
 
 // StreamHandler defines the interface for handling streaming format conversion
-// Separated from FormatHandler since streaming has unique requirements
+// Separated from FormatHandler since streaming has unique requirements.
+// A stream handler serves a single stream and keeps state across its chunks,
+// such as message IDs, open content blocks and accumulated text.
 type StreamHandler interface {
 	// Name returns the format identifier
 	Name() string
 
 	// ParseStreamChunk parses a format-specific stream chunk into UniversalStreamChunk
 	ParseStreamChunk(chunk []byte) (*UniversalStreamChunk, error)
-	// BuildStreamChunk builds a format-specific stream chunk from UniversalStreamChunk
-	BuildStreamChunk(chunk *UniversalStreamChunk) ([]byte, error)
+	// BuildStreamChunk builds the format-specific stream event(s) for a UniversalStreamChunk.
+	// The first chunk also starts the stream with its ID and model, if the format needs that.
+	// One chunk may need several events, e.g. Anthropic's content_block_start followed by
+	// content_block_delta for a tool call; an empty result means nothing is emitted
+	BuildStreamChunk(chunk *UniversalStreamChunk) ([][]byte, error)
 
-	// BuildStartEvent builds the initial event(s) for streaming (some formats need this)
-	BuildStartEvent(model string, id string) [][]byte
-	// BuildEndEvent builds the final event(s) for streaming
+	// BuildEndEvent closes open content and builds the final event(s) for streaming.
+	// It returns nothing if the stream has already ended
 	BuildEndEvent() [][]byte
 }
 
-// FormatInfo contains both handlers for a format
+// WholeToolCallStreamer is implemented by stream handlers whose format cannot
+// express partial tool-call arguments (e.g. Gemini sends each functionCall whole).
+// The converter buffers tool-call deltas for them and passes complete calls with
+// the final chunk.
+type WholeToolCallStreamer interface {
+	RequiresWholeToolCalls() bool
+}
+
+// NamedEventStreamer is implemented by stream handlers whose SSE events carry an
+// event name ("event:" field), e.g. Anthropic's "message_start". Other formats
+// only send unnamed "data:" events.
+type NamedEventStreamer interface {
+	EventName(data []byte) string
+}
+
+// MediaURLAcceptor is implemented by format handlers that can reference some
+// media by remote URL. Other media must be sent inline as base64, so the
+// converter fetches remote URLs for it when a media fetcher is set.
+type MediaURLAcceptor interface {
+	AcceptsMediaURL(part UniversalContentPart) bool
+}
+
+// FormatInfo contains the handler and the stream handler constructor for a format
 type FormatInfo struct {
-	Handler       FormatHandler
-	StreamHandler StreamHandler
+	Handler          FormatHandler
+	NewStreamHandler func() StreamHandler
 }
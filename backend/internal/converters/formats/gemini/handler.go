@@ -3,12 +3,18 @@ package gemini
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"api-key-rotator/backend/internal/converters/formats"
+
+	"github.com/google/uuid"
 )
 
+// fileAPIURLPrefix is the prefix of files uploaded through the Gemini File API
+const fileAPIURLPrefix = "https://generativelanguage.googleapis.com/"
+
 func init() {
-	formats.RegisterFormat("gemini", &Handler{}, &StreamHandler{})
+	formats.RegisterFormat("gemini", &Handler{}, func() formats.StreamHandler { return &StreamHandler{} })
 }
 
 // Handler implements FormatHandler for Gemini format
@@ -23,6 +29,8 @@ type Request struct {
 	Contents          []RequestContent  `json:"contents"`
 	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
 	SystemInstruction *RequestContent   `json:"systemInstruction,omitempty"`
+	Tools             []Tool            `json:"tools,omitempty"`
+	ToolConfig        *ToolConfig       `json:"toolConfig,omitempty"`
 }
 
 type RequestContent struct {
@@ -31,7 +39,59 @@ type RequestContent struct {
 }
 
 type Part struct {
-	Text string `json:"text"`
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"` // Text is a thought summary
+	ThoughtSignature string            `json:"thoughtSignature,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FileData         *FileData         `json:"fileData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// Blob is inline base64 media
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// FileData references media by URI, e.g. a File API or Cloud Storage URI
+type FileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type FunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Tool types
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+type FunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // OpenAPI subset schema
+
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"` // Full JSON Schema, alternative to Parameters
+}
+
+type ToolConfig struct {
+	FunctionCallingConfig *FunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+type FunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"` // "AUTO", "ANY", "NONE"
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
 }
 
 type GenerationConfig struct {
@@ -39,12 +99,27 @@ type GenerationConfig struct {
 	Temperature     *float64 `json:"temperature,omitempty"`
 	TopP            *float64 `json:"topP,omitempty"`
 	StopSequences   []string `json:"stopSequences,omitempty"`
+
+	ThinkingConfig *ThinkingConfig `json:"thinkingConfig,omitempty"`
+
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`   // "application/json" for JSON output
+	ResponseSchema     json.RawMessage `json:"responseSchema,omitempty"`     // OpenAPI subset schema
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"` // Full JSON Schema, alternative to ResponseSchema
+}
+
+// ThinkingConfig configures thinking; a budget of 0 disables it and -1 lets the model decide
+type ThinkingConfig struct {
+	ThinkingBudget  *int   `json:"thinkingBudget,omitempty"`
+	ThinkingLevel   string `json:"thinkingLevel,omitempty"` // "low" or "high"
+	IncludeThoughts bool   `json:"includeThoughts,omitempty"`
 }
 
 // Response types
 type Response struct {
-	Candidates    []Candidate    `json:"candidates"`
+	Candidates    []Candidate    `json:"candidates,omitempty"` // Absent in usage-only stream chunks
 	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string         `json:"modelVersion,omitempty"`
+	ResponseID    string         `json:"responseId,omitempty"`
 }
 
 type Candidate struct {
@@ -79,19 +154,70 @@ func (h *Handler) ParseRequest(body []byte) (*formats.UniversalRequest, error) {
 		universal.System = req.SystemInstruction.Parts[0].Text
 	}
 
+	for _, tool := range req.Tools {
+		for _, declaration := range tool.FunctionDeclarations {
+			universal.Tools = append(universal.Tools, formats.UniversalTool{
+				Name:        declaration.Name,
+				Description: declaration.Description,
+				Parameters:  parseParameters(declaration),
+			})
+		}
+	}
+	if req.ToolConfig != nil && req.ToolConfig.FunctionCallingConfig != nil {
+		universal.ToolChoice = parseToolChoice(req.ToolConfig.FunctionCallingConfig)
+	}
+
+	// Gemini function calls usually carry no ID; generate IDs and match each
+	// functionResponse to the earliest unanswered call with the same name
+	pendingCalls := make(map[string][]string)
 	for _, content := range req.Contents {
 		role := content.Role
 		if role == "model" {
 			role = "assistant"
 		}
-		var text string
+		message := formats.UniversalMessage{Role: role}
+		var contentParts []formats.UniversalContentPart
+		var results []formats.UniversalToolResult
 		for _, part := range content.Parts {
-			text += part.Text
+			switch {
+			case part.FunctionCall != nil:
+				call := parseFunctionCall(part.FunctionCall)
+				pendingCalls[call.Name] = append(pendingCalls[call.Name], call.ID)
+				message.ToolCalls = append(message.ToolCalls, call)
+			case part.FunctionResponse != nil:
+				id := part.FunctionResponse.ID
+				if queue := pendingCalls[part.FunctionResponse.Name]; len(queue) > 0 {
+					if id == "" {
+						id = queue[0]
+					}
+					pendingCalls[part.FunctionResponse.Name] = queue[1:]
+				}
+				results = append(results, formats.UniversalToolResult{
+					ToolCallID: id,
+					Name:       part.FunctionResponse.Name,
+					Content:    string(part.FunctionResponse.Response),
+				})
+			case part.InlineData != nil:
+				contentParts = append(contentParts, formats.MediaPart(formats.PartTypeForMediaType(part.InlineData.MimeType),
+					part.InlineData.MimeType, part.InlineData.Data, ""))
+			case part.Thought:
+				// Thought summaries from earlier turns are not sent back to the model
+			case part.FileData != nil:
+				contentParts = append(contentParts, formats.MediaPart(formats.PartTypeForMediaType(part.FileData.MimeType),
+					part.FileData.MimeType, "", part.FileData.FileURI))
+			default:
+				contentParts = append(contentParts, formats.UniversalContentPart{Type: formats.ContentPartText, Text: part.Text})
+			}
 		}
-		universal.Messages = append(universal.Messages, formats.UniversalMessage{
-			Role:    role,
-			Content: text,
-		})
+		message.SetContentParts(contentParts)
+
+		if len(results) > 0 {
+			universal.Messages = append(universal.Messages, formats.UniversalMessage{Role: "tool", ToolResults: results})
+			if message.Content == "" && !message.HasMedia() && len(message.ToolCalls) == 0 {
+				continue
+			}
+		}
+		universal.Messages = append(universal.Messages, message)
 	}
 
 	if req.GenerationConfig != nil {
@@ -99,6 +225,8 @@ func (h *Handler) ParseRequest(body []byte) (*formats.UniversalRequest, error) {
 		universal.Temperature = req.GenerationConfig.Temperature
 		universal.TopP = req.GenerationConfig.TopP
 		universal.Stop = req.GenerationConfig.StopSequences
+		universal.Reasoning = parseThinkingConfig(req.GenerationConfig.ThinkingConfig)
+		universal.ResponseFormat = parseResponseFormat(req.GenerationConfig)
 	}
 
 	return universal, nil
@@ -116,14 +244,69 @@ func (h *Handler) BuildRequest(req *formats.UniversalRequest) ([]byte, error) {
 		}
 	}
 
+	if len(req.Tools) > 0 {
+		tool := Tool{}
+		for _, t := range req.Tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, FunctionDeclaration{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  sanitizeSchema(t.Parameters),
+			})
+		}
+		geminiReq.Tools = []Tool{tool}
+	}
+	if config := buildFunctionCallingConfig(req.ToolChoice); config != nil {
+		geminiReq.ToolConfig = &ToolConfig{FunctionCallingConfig: config}
+	}
+
+	// Tool results reference calls by ID, but Gemini function responses need the function name
+	toolNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		for _, call := range msg.ToolCalls {
+			toolNames[call.ID] = call.Name
+		}
+	}
+
 	for _, msg := range req.Messages {
 		role := msg.Role
-		if role == "assistant" {
+		switch role {
+		case "assistant":
 			role = "model"
+		case "tool":
+			role = "user"
+		}
+
+		parts := make([]Part, 0, 1+len(msg.Parts)+len(msg.ToolCalls)+len(msg.ToolResults))
+		if msg.HasMedia() {
+			parts = append(parts, buildContentParts(msg.Parts)...)
+		} else if msg.Content != "" || (len(msg.ToolCalls) == 0 && len(msg.ToolResults) == 0) {
+			parts = append(parts, Part{Text: msg.Content})
+		}
+		for _, call := range msg.ToolCalls {
+			parts = append(parts, Part{FunctionCall: &FunctionCall{
+				Name: call.Name,
+				Args: formats.ToolArgumentsObject(call.Arguments),
+			}})
+		}
+		for _, result := range msg.ToolResults {
+			name := result.Name
+			if name == "" {
+				name = toolNames[result.ToolCallID]
+			}
+			parts = append(parts, Part{FunctionResponse: &FunctionResponse{
+				Name:     name,
+				Response: functionResponseObject(result.Content),
+			}})
+		}
+
+		// Parallel function responses must share one content; merge consecutive contents of the same role
+		if n := len(geminiReq.Contents); n > 0 && geminiReq.Contents[n-1].Role == role {
+			geminiReq.Contents[n-1].Parts = append(geminiReq.Contents[n-1].Parts, parts...)
+			continue
 		}
 		geminiReq.Contents = append(geminiReq.Contents, RequestContent{
 			Role:  role,
-			Parts: []Part{{Text: msg.Content}},
+			Parts: parts,
 		})
 	}
 
@@ -132,11 +315,24 @@ func (h *Handler) BuildRequest(req *formats.UniversalRequest) ([]byte, error) {
 		Temperature:     req.Temperature,
 		TopP:            req.TopP,
 		StopSequences:   req.Stop,
+		ThinkingConfig:  buildThinkingConfig(req.Reasoning),
+	}
+	if req.ResponseFormat.IsJSON() {
+		geminiReq.GenerationConfig.ResponseMimeType = "application/json"
+		if req.ResponseFormat.Type == formats.ResponseFormatJSONSchema {
+			geminiReq.GenerationConfig.ResponseSchema = sanitizeSchema(req.ResponseFormat.Schema)
+		}
 	}
 
 	return json.Marshal(geminiReq)
 }
 
+// AcceptsMediaURL implements formats.MediaURLAcceptor; fileData only accepts
+// File API and Cloud Storage URIs, other URLs must be inlined
+func (h *Handler) AcceptsMediaURL(part formats.UniversalContentPart) bool {
+	return strings.HasPrefix(part.URL, "gs://") || strings.HasPrefix(part.URL, fileAPIURLPrefix)
+}
+
 // ParseResponse implements FormatHandler
 func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error) {
 	var resp Response
@@ -144,13 +340,24 @@ func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error)
 		return nil, fmt.Errorf("failed to parse Gemini response: %w", err)
 	}
 
-	universal := &formats.UniversalResponse{Role: "assistant"}
+	universal := &formats.UniversalResponse{
+		ID:    resp.ResponseID,
+		Model: resp.ModelVersion,
+		Role:  "assistant",
+	}
 
 	if len(resp.Candidates) > 0 {
 		candidate := resp.Candidates[0]
 		if candidate.Content != nil {
 			for _, part := range candidate.Content.Parts {
-				universal.Content += part.Text
+				switch {
+				case part.FunctionCall != nil:
+					universal.ToolCalls = append(universal.ToolCalls, parseFunctionCall(part.FunctionCall))
+				case part.Thought:
+					universal.Reasoning += part.Text
+				default:
+					universal.Content += part.Text
+				}
 			}
 			universal.Role = candidate.Content.Role
 			if universal.Role == "model" {
@@ -158,6 +365,9 @@ func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error)
 			}
 		}
 		universal.StopReason = mapGeminiFinishReason(candidate.FinishReason)
+		if len(universal.ToolCalls) > 0 && universal.StopReason == "stop" {
+			universal.StopReason = "tool_calls"
+		}
 	}
 
 	if resp.UsageMetadata != nil {
@@ -178,38 +388,64 @@ func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error)
 		role = "model"
 	}
 
+	parts := make([]Part, 0, 2+len(resp.ToolCalls))
+	if resp.Reasoning != "" {
+		parts = append(parts, Part{Text: resp.Reasoning, Thought: true})
+	}
+	if resp.Content != "" || len(resp.ToolCalls) == 0 {
+		parts = append(parts, Part{Text: resp.Content})
+	}
+	parts = append(parts, buildFunctionCallParts(resp.ToolCalls)...)
+
 	geminiResp := Response{
+		ModelVersion: resp.Model,
+		ResponseID:   resp.ID,
 		Candidates: []Candidate{
 			{
 				Index:        0,
 				FinishReason: mapToGeminiFinishReason(resp.StopReason),
 				Content: &ResponseContent{
 					Role:  role,
-					Parts: []Part{{Text: resp.Content}},
+					Parts: parts,
 				},
 			},
 		},
 	}
 
 	if resp.Usage != nil {
-		geminiResp.UsageMetadata = &UsageMetadata{
-			PromptTokenCount:     resp.Usage.InputTokens,
-			CandidatesTokenCount: resp.Usage.OutputTokens,
-			TotalTokenCount:      resp.Usage.TotalTokens,
-		}
+		geminiResp.UsageMetadata = usageMetadata(resp.Usage)
 	}
 
 	return json.Marshal(geminiResp)
 }
 
+func usageMetadata(usage *formats.UniversalUsage) *UsageMetadata {
+	return &UsageMetadata{
+		PromptTokenCount:     usage.InputTokens,
+		CandidatesTokenCount: usage.OutputTokens,
+		TotalTokenCount:      usage.TotalTokens,
+	}
+}
+
 // GetAPIPath implements FormatHandler
-func (h *Handler) GetAPIPath(action string) string {
+// Streaming uses streamGenerateContent with alt=sse; without it Gemini streams a JSON array
+func (h *Handler) GetAPIPath(action string, stream bool) string {
 	switch action {
-	case "chat/completions", "v1/chat/completions":
+	case "chat/completions", "v1/chat/completions", "messages", "v1/messages", "responses", "v1/responses":
+		if stream {
+			return "v1beta/models/{model}:streamGenerateContent?alt=sse"
+		}
 		return "v1beta/models/{model}:generateContent"
-	default:
-		return action
 	}
+
+	// Gemini actions keep their model; the method follows the stream mode
+	if model, ok := strings.CutSuffix(action, ":streamGenerateContent"); ok && !stream {
+		return model + ":generateContent"
+	}
+	if model, ok := strings.CutSuffix(action, ":generateContent"); ok && stream {
+		return model + ":streamGenerateContent?alt=sse"
+	}
+	return action
 }
 
 // GetClientAction implements FormatHandler
@@ -232,7 +468,7 @@ func mapGeminiFinishReason(reason string) string {
 
 func mapToGeminiFinishReason(reason string) string {
 	switch reason {
-	case "stop", "end_turn":
+	case "stop", "end_turn", "tool_calls":
 		return "STOP"
 	case "length", "max_tokens":
 		return "MAX_TOKENS"
@@ -242,3 +478,144 @@ func mapToGeminiFinishReason(reason string) string {
 		return "STOP"
 	}
 }
+
+// parseFunctionCall converts a Gemini function call, generating an ID when Gemini did not provide one
+func parseFunctionCall(call *FunctionCall) formats.UniversalToolCall {
+	id := call.ID
+	if id == "" {
+		id = "call_" + uuid.New().String()[:8]
+	}
+	return formats.UniversalToolCall{
+		ID:        id,
+		Name:      call.Name,
+		Arguments: string(formats.ToolArgumentsObject(string(call.Args))),
+	}
+}
+
+func buildFunctionCallParts(calls []formats.UniversalToolCall) []Part {
+	parts := make([]Part, 0, len(calls))
+	for _, call := range calls {
+		parts = append(parts, Part{FunctionCall: &FunctionCall{
+			Name: call.Name,
+			Args: formats.ToolArgumentsObject(call.Arguments),
+		}})
+	}
+	return parts
+}
+
+// functionResponseObject wraps a tool result as the JSON object Gemini expects
+func functionResponseObject(content string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &object); err == nil && object != nil {
+		return json.RawMessage(content)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"result": content})
+	return wrapped
+}
+
+func parseToolChoice(config *FunctionCallingConfig) *formats.UniversalToolChoice {
+	switch config.Mode {
+	case "ANY":
+		if len(config.AllowedFunctionNames) == 1 {
+			return &formats.UniversalToolChoice{Mode: formats.ToolChoiceTool, Name: config.AllowedFunctionNames[0]}
+		}
+		return &formats.UniversalToolChoice{Mode: formats.ToolChoiceRequired}
+	case "NONE":
+		return &formats.UniversalToolChoice{Mode: formats.ToolChoiceNone}
+	case "AUTO":
+		return &formats.UniversalToolChoice{Mode: formats.ToolChoiceAuto}
+	default:
+		return nil
+	}
+}
+
+func buildFunctionCallingConfig(choice *formats.UniversalToolChoice) *FunctionCallingConfig {
+	if choice == nil {
+		return nil
+	}
+	switch choice.Mode {
+	case formats.ToolChoiceRequired:
+		return &FunctionCallingConfig{Mode: "ANY"}
+	case formats.ToolChoiceTool:
+		return &FunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{choice.Name}}
+	case formats.ToolChoiceNone:
+		return &FunctionCallingConfig{Mode: "NONE"}
+	default:
+		return &FunctionCallingConfig{Mode: "AUTO"}
+	}
+}
+
+// buildContentParts converts universal content parts to Gemini parts
+func buildContentParts(contentParts []formats.UniversalContentPart) []Part {
+	parts := make([]Part, 0, len(contentParts))
+	for _, part := range contentParts {
+		switch {
+		case part.Type == formats.ContentPartText:
+			if part.Text != "" {
+				parts = append(parts, Part{Text: part.Text})
+			}
+		case part.Data != "":
+			parts = append(parts, Part{InlineData: &Blob{MimeType: formats.GuessMediaType(part), Data: part.Data}})
+		default:
+			parts = append(parts, Part{FileData: &FileData{MimeType: formats.GuessMediaType(part), FileURI: part.URL}})
+		}
+	}
+	return parts
+}
+
+func parseThinkingConfig(config *ThinkingConfig) *formats.UniversalReasoning {
+	if config == nil {
+		return nil
+	}
+	reasoning := &formats.UniversalReasoning{Effort: config.ThinkingLevel}
+	if config.ThinkingBudget != nil {
+		switch budget := *config.ThinkingBudget; {
+		case budget == 0:
+			reasoning.Effort = formats.ReasoningEffortNone
+		case budget > 0:
+			reasoning.BudgetTokens = budget
+		}
+	}
+	return reasoning
+}
+
+// buildThinkingConfig requests thought summaries whenever reasoning is enabled,
+// so that the client receives the thinking content
+func buildThinkingConfig(reasoning *formats.UniversalReasoning) *ThinkingConfig {
+	if reasoning == nil {
+		return nil
+	}
+	budget := reasoning.Budget()
+	switch {
+	case reasoning.Disabled():
+		budget = 0
+	case budget == 0:
+		budget = -1
+	}
+	return &ThinkingConfig{
+		ThinkingBudget:  &budget,
+		IncludeThoughts: budget != 0,
+	}
+}
+
+// parseParameters returns a function declaration's parameters as JSON Schema
+func parseParameters(declaration FunctionDeclaration) json.RawMessage {
+	if len(declaration.ParametersJSONSchema) > 0 {
+		return declaration.ParametersJSONSchema
+	}
+	return schemaToJSONSchema(declaration.Parameters)
+}
+
+func parseResponseFormat(config *GenerationConfig) *formats.UniversalResponseFormat {
+	if config.ResponseMimeType != "application/json" {
+		return nil
+	}
+	switch {
+	case len(config.ResponseJSONSchema) > 0:
+		return &formats.UniversalResponseFormat{Type: formats.ResponseFormatJSONSchema, Schema: config.ResponseJSONSchema}
+	case len(config.ResponseSchema) > 0:
+		return &formats.UniversalResponseFormat{Type: formats.ResponseFormatJSONSchema, Schema: schemaToJSONSchema(config.ResponseSchema)}
+	default:
+		return &formats.UniversalResponseFormat{Type: formats.ResponseFormatJSONObject}
+	}
+}
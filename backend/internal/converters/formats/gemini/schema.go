@@ -0,0 +1,301 @@
+package gemini
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Gemini's responseSchema and function parameters accept a subset of the
+// OpenAPI 3.0 schema object rather than full JSON Schema. Other formats send
+// JSON Schema, so schemas are adjusted in both directions.
+
+// maxRefDepth bounds $ref inlining so recursive schemas terminate
+const maxRefDepth = 8
+
+// schemaKeywords are the JSON Schema keywords Gemini accepts as they are
+var schemaKeywords = map[string]bool{
+	"title":            true,
+	"description":      true,
+	"nullable":         true,
+	"required":         true,
+	"minItems":         true,
+	"maxItems":         true,
+	"minimum":          true,
+	"maximum":          true,
+	"minLength":        true,
+	"maxLength":        true,
+	"pattern":          true,
+	"minProperties":    true,
+	"maxProperties":    true,
+	"propertyOrdering": true,
+	"example":          true,
+	"default":          true,
+}
+
+// schemaFormats are the only formats Gemini supports
+var schemaFormats = map[string]bool{
+	"date-time": true,
+	"enum":      true,
+	"int32":     true,
+	"int64":     true,
+	"float":     true,
+	"double":    true,
+}
+
+// sanitizeSchema converts a JSON schema to the dialect Gemini accepts: local
+// $refs are inlined, type arrays and null variants become "nullable", oneOf
+// becomes anyOf, const becomes a single-value enum and unsupported keywords
+// (additionalProperties, $schema, ...) are dropped
+func sanitizeSchema(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(raw, &root); err != nil || root == nil {
+		return raw
+	}
+
+	defs := make(map[string]interface{})
+	for _, key := range []string{"definitions", "$defs"} {
+		if group, ok := root[key].(map[string]interface{}); ok {
+			for name, def := range group {
+				defs["#/"+key+"/"+name] = def
+			}
+		}
+	}
+
+	result, err := json.Marshal(sanitizeSchemaNode(root, defs, 0))
+	if err != nil {
+		return raw
+	}
+	return result
+}
+
+func sanitizeSchemaNode(node map[string]interface{}, defs map[string]interface{}, depth int) map[string]interface{} {
+	if ref, ok := node["$ref"].(string); ok {
+		def, found := defs[ref].(map[string]interface{})
+		if !found || depth >= maxRefDepth {
+			return map[string]interface{}{"type": "OBJECT"}
+		}
+		resolved := sanitizeSchemaNode(def, defs, depth+1)
+		if description, ok := node["description"].(string); ok {
+			resolved["description"] = description
+		}
+		return resolved
+	}
+
+	out := make(map[string]interface{})
+	switch t := node["type"].(type) {
+	case string:
+		if t == "null" {
+			out["nullable"] = true
+		} else {
+			out["type"] = strings.ToUpper(t)
+		}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				if name == "null" {
+					out["nullable"] = true
+				} else {
+					types = append(types, strings.ToUpper(name))
+				}
+			}
+		}
+		if len(types) == 1 {
+			out["type"] = types[0]
+		} else if len(types) > 1 {
+			variants := make([]interface{}, 0, len(types))
+			for _, name := range types {
+				variants = append(variants, map[string]interface{}{"type": name})
+			}
+			out["anyOf"] = variants
+		}
+	}
+
+	for key, value := range node {
+		switch key {
+		case "type":
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			converted := make(map[string]interface{}, len(properties))
+			for name, property := range properties {
+				if propertyNode, ok := property.(map[string]interface{}); ok {
+					converted[name] = sanitizeSchemaNode(propertyNode, defs, depth)
+				}
+			}
+			out["properties"] = converted
+		case "items":
+			// Tuple schemas are not supported; use the first item schema
+			if list, ok := value.([]interface{}); ok && len(list) > 0 {
+				value = list[0]
+			}
+			if itemNode, ok := value.(map[string]interface{}); ok {
+				out["items"] = sanitizeSchemaNode(itemNode, defs, depth)
+			}
+		case "anyOf", "oneOf":
+			list, _ := value.([]interface{})
+			variants := make([]interface{}, 0, len(list))
+			for _, item := range list {
+				variant, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if variant["type"] == "null" {
+					out["nullable"] = true
+					continue
+				}
+				variants = append(variants, sanitizeSchemaNode(variant, defs, depth))
+			}
+			if len(variants) == 1 {
+				mergeSchema(out, variants[0].(map[string]interface{}))
+			} else if len(variants) > 1 {
+				out["anyOf"] = variants
+			}
+		case "allOf":
+			// Only a single allOf entry can be represented, by merging it in
+			if list, ok := value.([]interface{}); ok && len(list) == 1 {
+				if item, ok := list[0].(map[string]interface{}); ok {
+					mergeSchema(out, sanitizeSchemaNode(item, defs, depth))
+				}
+			}
+		case "const":
+			if s, ok := value.(string); ok {
+				out["enum"] = []interface{}{s}
+				out["format"] = "enum"
+			}
+		case "enum":
+			// Gemini enums must be strings
+			list, _ := value.([]interface{})
+			values := make([]interface{}, 0, len(list))
+			for _, item := range list {
+				if item == nil {
+					out["nullable"] = true
+					continue
+				}
+				if _, ok := item.(string); !ok {
+					values = nil
+					if _, typed := node["type"]; !typed {
+						out["type"] = enumValueType(item)
+					}
+					break
+				}
+				values = append(values, item)
+			}
+			if len(values) > 0 {
+				out["enum"] = values
+				out["format"] = "enum"
+			}
+		case "format":
+			if format, ok := value.(string); ok && schemaFormats[format] {
+				if _, isEnum := out["enum"]; !isEnum {
+					out["format"] = format
+				}
+			}
+		default:
+			if schemaKeywords[key] {
+				out[key] = value
+			}
+		}
+	}
+
+	// Enums are only valid on strings
+	if _, ok := out["enum"]; ok {
+		out["type"] = "STRING"
+	}
+	return out
+}
+
+// enumValueType returns the Gemini type of a non-string enum value
+func enumValueType(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "BOOLEAN"
+	case float64:
+		return "NUMBER"
+	default:
+		return "OBJECT"
+	}
+}
+
+// mergeSchema copies keys from src that dst does not set yet
+func mergeSchema(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if _, exists := dst[key]; !exists {
+			dst[key] = value
+		}
+	}
+}
+
+// schemaToJSONSchema converts a Gemini schema to JSON Schema: types are
+// lowercased, "nullable" becomes a null type and Gemini-only keywords are dropped
+func schemaToJSONSchema(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(raw, &root); err != nil || root == nil {
+		return raw
+	}
+	result, err := json.Marshal(jsonSchemaNode(root))
+	if err != nil {
+		return raw
+	}
+	return result
+}
+
+func jsonSchemaNode(node map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(node))
+	for key, value := range node {
+		switch key {
+		case "type":
+			if t, ok := value.(string); ok {
+				value = strings.ToLower(t)
+			}
+			out[key] = value
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			converted := make(map[string]interface{}, len(properties))
+			for name, property := range properties {
+				if propertyNode, ok := property.(map[string]interface{}); ok {
+					converted[name] = jsonSchemaNode(propertyNode)
+				}
+			}
+			out[key] = converted
+		case "items":
+			if itemNode, ok := value.(map[string]interface{}); ok {
+				out[key] = jsonSchemaNode(itemNode)
+			}
+		case "anyOf":
+			list, _ := value.([]interface{})
+			variants := make([]interface{}, 0, len(list))
+			for _, item := range list {
+				if variant, ok := item.(map[string]interface{}); ok {
+					variants = append(variants, jsonSchemaNode(variant))
+				}
+			}
+			out[key] = variants
+		case "nullable", "propertyOrdering":
+		case "format":
+			if value != "enum" {
+				out[key] = value
+			}
+		default:
+			out[key] = value
+		}
+	}
+
+	if nullable, _ := node["nullable"].(bool); nullable {
+		if t, ok := out["type"].(string); ok {
+			out["type"] = []interface{}{t, "null"}
+		}
+	}
+	return out
+}
@@ -8,7 +8,11 @@ import (
 )
 
 // StreamHandler implements formats.StreamHandler for Gemini format
-type StreamHandler struct{}
+type StreamHandler struct {
+	// Every chunk of a stream repeats the response ID and model version
+	id    string
+	model string
+}
 
 func (h *StreamHandler) Name() string {
 	return "gemini"
@@ -21,12 +25,30 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 		return nil, fmt.Errorf("failed to parse Gemini stream chunk: %w", err)
 	}
 
-	universal := &formats.UniversalStreamChunk{}
+	universal := &formats.UniversalStreamChunk{
+		ID:    resp.ResponseID,
+		Model: resp.ModelVersion,
+	}
 
 	if len(resp.Candidates) > 0 {
 		candidate := resp.Candidates[0]
 		if candidate.Content != nil {
 			for _, part := range candidate.Content.Parts {
+				if part.FunctionCall != nil {
+					// Gemini streams each function call whole, with complete arguments
+					call := parseFunctionCall(part.FunctionCall)
+					universal.ToolCalls = append(universal.ToolCalls, formats.UniversalToolCallDelta{
+						Index:     len(universal.ToolCalls),
+						ID:        call.ID,
+						Name:      call.Name,
+						Arguments: call.Arguments,
+					})
+					continue
+				}
+				if part.Thought {
+					universal.ReasoningDelta += part.Text
+					continue
+				}
 				universal.Delta += part.Text
 			}
 			role := candidate.Content.Role
@@ -38,28 +60,81 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 
 		if candidate.FinishReason != "" {
 			reason := mapGeminiFinishReason(candidate.FinishReason)
+			if len(universal.ToolCalls) > 0 && reason == "stop" {
+				reason = "tool_calls"
+			}
 			universal.StopReason = &reason
 			universal.IsLast = true
 		}
 	}
 
+	// Chunks repeat the running usage; the last one has the final counts
+	if resp.UsageMetadata != nil {
+		universal.Usage = &formats.UniversalUsage{
+			InputTokens:  resp.UsageMetadata.PromptTokenCount,
+			OutputTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:  resp.UsageMetadata.TotalTokenCount,
+		}
+	}
+
 	return universal, nil
 }
 
 // BuildStreamChunk implements StreamHandler
-func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([]byte, error) {
+func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([][]byte, error) {
+	if h.id == "" {
+		h.id = chunk.ID
+	}
+	if h.model == "" {
+		h.model = chunk.Model
+	}
+
+	// Gemini has no lifecycle events, so chunks without content are dropped;
+	// usage reported after the content is sent on its own
+	if chunk.Delta == "" && chunk.ReasoningDelta == "" && len(chunk.ToolCalls) == 0 && chunk.StopReason == nil {
+		if chunk.Usage == nil {
+			return nil, nil
+		}
+		data, err := json.Marshal(Response{
+			ModelVersion:  h.model,
+			ResponseID:    h.id,
+			UsageMetadata: usageMetadata(chunk.Usage),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{data}, nil
+	}
+
 	role := chunk.Role
 	if role == "assistant" || role == "" {
 		role = "model"
 	}
 
+	parts := make([]Part, 0, 2+len(chunk.ToolCalls))
+	if chunk.ReasoningDelta != "" {
+		parts = append(parts, Part{Text: chunk.ReasoningDelta, Thought: true})
+	}
+	if chunk.Delta != "" {
+		parts = append(parts, Part{Text: chunk.Delta})
+	}
+	// Tool calls arrive complete because the handler requires whole tool calls
+	for _, call := range chunk.ToolCalls {
+		parts = append(parts, Part{FunctionCall: &FunctionCall{
+			Name: call.Name,
+			Args: formats.ToolArgumentsObject(call.Arguments),
+		}})
+	}
+
 	geminiChunk := Response{
+		ModelVersion: h.model,
+		ResponseID:   h.id,
 		Candidates: []Candidate{
 			{
 				Index: 0,
 				Content: &ResponseContent{
 					Role:  role,
-					Parts: []Part{{Text: chunk.Delta}},
+					Parts: parts,
 				},
 			},
 		},
@@ -68,13 +143,20 @@ func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([
 	if chunk.StopReason != nil {
 		geminiChunk.Candidates[0].FinishReason = mapToGeminiFinishReason(*chunk.StopReason)
 	}
+	if chunk.Usage != nil {
+		geminiChunk.UsageMetadata = usageMetadata(chunk.Usage)
+	}
 
-	return json.Marshal(geminiChunk)
+	data, err := json.Marshal(geminiChunk)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{data}, nil
 }
 
-// BuildStartEvent implements StreamHandler
-func (h *StreamHandler) BuildStartEvent(model string, id string) [][]byte {
-	return nil
+// RequiresWholeToolCalls implements formats.WholeToolCallStreamer
+func (h *StreamHandler) RequiresWholeToolCalls() bool {
+	return true
 }
 
 // BuildEndEvent implements StreamHandler
@@ -3,13 +3,14 @@ package openai
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"api-key-rotator/backend/internal/converters/formats"
 )
 
 func init() {
-	formats.RegisterFormat("openai", &Handler{}, &StreamHandler{})
+	formats.RegisterFormat("openai", &Handler{}, func() formats.StreamHandler { return &StreamHandler{} })
 }
 
 // Handler implements FormatHandler for OpenAI format
@@ -28,11 +29,83 @@ type Request struct {
 	TopP        *float64         `json:"top_p,omitempty"`
 	Stream      bool             `json:"stream,omitempty"`
 	Stop        []string         `json:"stop,omitempty"`
+
+	ReasoningEffort string `json:"reasoning_effort,omitempty"` // "none", "minimal", "low", "medium", "high"
+
+	Tools             []Tool      `json:"tools,omitempty"`
+	ToolChoice        interface{} `json:"tool_choice,omitempty"` // "auto", "none", "required" or {"type":"function","function":{"name":...}}
+	ParallelToolCalls *bool       `json:"parallel_tool_calls,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat constrains the model output to text, any JSON object or a JSON schema
+type ResponseFormat struct {
+	Type       string      `json:"type"` // "text", "json_object" or "json_schema"
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
 }
 
 type RequestMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string      `json:"role"`
+	Content    interface{} `json:"content"` // String, array of content parts, or null for tool-call-only assistant messages
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`
+	ToolCallID string      `json:"tool_call_id,omitempty"`
+	Name       string      `json:"name,omitempty"`
+}
+
+// ContentPart is an element of array message content
+type ContentPart struct {
+	Type       string      `json:"type"` // "text", "image_url", "input_audio" or "file"
+	Text       string      `json:"text,omitempty"`
+	ImageURL   *ImageURL   `json:"image_url,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+	File       *File       `json:"file,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"` // Remote URL or base64 data URL
+	Detail string `json:"detail,omitempty"`
+}
+
+type InputAudio struct {
+	Data   string `json:"data"`   // Base64-encoded audio
+	Format string `json:"format"` // "wav", "mp3", ...
+}
+
+type File struct {
+	FileData string `json:"file_data,omitempty"` // Base64 data URL
+	FileID   string `json:"file_id,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// Tool types
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // Response types
@@ -52,8 +125,10 @@ type Choice struct {
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role             string     `json:"role"`
+	Content          *string    `json:"content"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"` // Thinking text, as returned by OpenAI-compatible reasoning models
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
 }
 
 type Usage struct {
@@ -76,20 +151,55 @@ func (h *Handler) ParseRequest(body []byte) (*formats.UniversalRequest, error) {
 		Stop:        req.Stop,
 		Temperature: req.Temperature,
 		TopP:        req.TopP,
+
+		ParallelToolCalls: req.ParallelToolCalls,
+		ToolChoice:        parseToolChoice(req.ToolChoice),
+		ResponseFormat:    parseResponseFormat(req.ResponseFormat),
 	}
 
 	if req.MaxTokens != nil {
 		universal.MaxTokens = *req.MaxTokens
 	}
+	if req.ReasoningEffort != "" {
+		universal.Reasoning = &formats.UniversalReasoning{Effort: req.ReasoningEffort}
+	}
+
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		universal.Tools = append(universal.Tools, formats.UniversalTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
 
 	for _, msg := range req.Messages {
-		if msg.Role == "system" {
-			universal.System = msg.Content
-		} else {
+		content := extractTextContent(msg.Content)
+		switch msg.Role {
+		case "system":
+			universal.System = content
+		case "tool":
 			universal.Messages = append(universal.Messages, formats.UniversalMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
+				Role: "tool",
+				ToolResults: []formats.UniversalToolResult{{
+					ToolCallID: msg.ToolCallID,
+					Name:       msg.Name,
+					Content:    content,
+				}},
 			})
+		default:
+			message := formats.UniversalMessage{Role: msg.Role}
+			message.SetContentParts(parseContentParts(msg.Content))
+			for _, call := range msg.ToolCalls {
+				message.ToolCalls = append(message.ToolCalls, formats.UniversalToolCall{
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				})
+			}
+			universal.Messages = append(universal.Messages, message)
 		}
 	}
 
@@ -105,11 +215,30 @@ func (h *Handler) BuildRequest(req *formats.UniversalRequest) ([]byte, error) {
 		Stop:        req.Stop,
 		Temperature: req.Temperature,
 		TopP:        req.TopP,
+
+		ParallelToolCalls: req.ParallelToolCalls,
+		ToolChoice:        buildToolChoice(req.ToolChoice),
+		ResponseFormat:    buildResponseFormat(req.ResponseFormat),
 	}
 
 	if req.MaxTokens > 0 {
 		openaiReq.MaxTokens = &req.MaxTokens
 	}
+	// Disabled reasoning is left out since not every model accepts "none"
+	if req.Reasoning != nil && !req.Reasoning.Disabled() {
+		openaiReq.ReasoningEffort = req.Reasoning.EffortLevel()
+	}
+
+	for _, tool := range req.Tools {
+		openaiReq.Tools = append(openaiReq.Tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
 
 	if req.System != "" {
 		openaiReq.Messages = append(openaiReq.Messages, RequestMessage{
@@ -119,15 +248,43 @@ func (h *Handler) BuildRequest(req *formats.UniversalRequest) ([]byte, error) {
 	}
 
 	for _, msg := range req.Messages {
-		openaiReq.Messages = append(openaiReq.Messages, RequestMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
+		// Each tool result is a separate "tool" message in OpenAI format
+		for _, result := range msg.ToolResults {
+			openaiReq.Messages = append(openaiReq.Messages, RequestMessage{
+				Role:       "tool",
+				Content:    result.Content,
+				ToolCallID: result.ToolCallID,
+			})
+		}
+		if msg.Role == "tool" {
+			continue
+		}
+
+		message := RequestMessage{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			ToolCalls: buildToolCalls(msg.ToolCalls),
+		}
+		if msg.HasMedia() {
+			parts, err := buildContentParts(msg.Parts)
+			if err != nil {
+				return nil, err
+			}
+			message.Content = parts
+		} else if msg.Content == "" && len(msg.ToolCalls) > 0 {
+			message.Content = nil
+		}
+		openaiReq.Messages = append(openaiReq.Messages, message)
 	}
 
 	return json.Marshal(openaiReq)
 }
 
+// AcceptsMediaURL implements formats.MediaURLAcceptor; only images can be passed by URL
+func (h *Handler) AcceptsMediaURL(part formats.UniversalContentPart) bool {
+	return part.Type == formats.ContentPartImage
+}
+
 // ParseResponse implements FormatHandler
 func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error) {
 	var resp Response
@@ -144,8 +301,18 @@ func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error)
 	if len(resp.Choices) > 0 {
 		choice := resp.Choices[0]
 		if choice.Message != nil {
-			universal.Content = choice.Message.Content
+			if choice.Message.Content != nil {
+				universal.Content = *choice.Message.Content
+			}
 			universal.Role = choice.Message.Role
+			universal.Reasoning = choice.Message.ReasoningContent
+			for _, call := range choice.Message.ToolCalls {
+				universal.ToolCalls = append(universal.ToolCalls, formats.UniversalToolCall{
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				})
+			}
 		}
 		if choice.FinishReason != nil {
 			universal.StopReason = *choice.FinishReason
@@ -170,6 +337,16 @@ func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error)
 		finishReason = "stop"
 	}
 
+	message := &Message{
+		Role:             resp.Role,
+		Content:          &resp.Content,
+		ReasoningContent: resp.Reasoning,
+		ToolCalls:        buildToolCalls(resp.ToolCalls),
+	}
+	if resp.Content == "" && len(resp.ToolCalls) > 0 {
+		message.Content = nil
+	}
+
 	openaiResp := Response{
 		ID:      resp.ID,
 		Object:  "chat.completion",
@@ -177,11 +354,8 @@ func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error)
 		Model:   resp.Model,
 		Choices: []Choice{
 			{
-				Index: 0,
-				Message: &Message{
-					Role:    resp.Role,
-					Content: resp.Content,
-				},
+				Index:        0,
+				Message:      message,
 				FinishReason: &finishReason,
 			},
 		},
@@ -199,7 +373,7 @@ func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error)
 }
 
 // GetAPIPath implements FormatHandler - converts client action to OpenAI API path
-func (h *Handler) GetAPIPath(action string) string {
+func (h *Handler) GetAPIPath(action string, stream bool) string {
 	switch action {
 	case "v1/messages", "messages":
 		// Anthropic endpoint -> OpenAI endpoint
@@ -210,6 +384,10 @@ func (h *Handler) GetAPIPath(action string) string {
 	case "v1/chat/completions":
 		return "chat/completions"
 	default:
+		if strings.Contains(action, ":generateContent") || strings.Contains(action, ":streamGenerateContent") {
+			// Gemini endpoint -> OpenAI endpoint
+			return "chat/completions"
+		}
 		return action
 	}
 }
@@ -223,3 +401,184 @@ func (h *Handler) GetClientAction(apiPath string) string {
 		return apiPath
 	}
 }
+
+// Helper functions
+func extractTextContent(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var text string
+		for _, part := range c {
+			if partMap, ok := part.(map[string]interface{}); ok && partMap["type"] == "text" {
+				if t, ok := partMap["text"].(string); ok {
+					text += t
+				}
+			}
+		}
+		return text
+	default:
+		return ""
+	}
+}
+
+// parseContentParts converts string or array message content to universal content parts
+func parseContentParts(content interface{}) []formats.UniversalContentPart {
+	if text, ok := content.(string); ok {
+		return []formats.UniversalContentPart{{Type: formats.ContentPartText, Text: text}}
+	}
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil
+	}
+	var parts []ContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil
+	}
+
+	var universal []formats.UniversalContentPart
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			universal = append(universal, formats.UniversalContentPart{Type: formats.ContentPartText, Text: part.Text})
+		case "image_url":
+			if part.ImageURL == nil {
+				continue
+			}
+			image := formats.MediaPart(formats.ContentPartImage, "", "", part.ImageURL.URL)
+			image.Detail = part.ImageURL.Detail
+			universal = append(universal, image)
+		case "input_audio":
+			if part.InputAudio == nil {
+				continue
+			}
+			universal = append(universal, formats.MediaPart(formats.ContentPartAudio,
+				formats.AudioMediaType(part.InputAudio.Format), part.InputAudio.Data, ""))
+		case "file":
+			// Uploaded file IDs cannot be resolved outside OpenAI
+			if part.File == nil || part.File.FileData == "" {
+				continue
+			}
+			document := formats.MediaPart(formats.ContentPartDocument, "application/pdf", "", part.File.FileData)
+			if document.URL != "" {
+				// file_data without a data URL prefix is plain base64
+				document.Data, document.URL = document.URL, ""
+			}
+			document.Filename = part.File.Filename
+			universal = append(universal, document)
+		}
+	}
+	return universal
+}
+
+// buildContentParts converts universal content parts to OpenAI array content
+func buildContentParts(parts []formats.UniversalContentPart) ([]ContentPart, error) {
+	result := make([]ContentPart, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case formats.ContentPartText:
+			result = append(result, ContentPart{Type: "text", Text: part.Text})
+		case formats.ContentPartImage:
+			url := part.URL
+			if part.Data != "" {
+				url = formats.DataURL(formats.GuessMediaType(part), part.Data)
+			}
+			result = append(result, ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url, Detail: part.Detail}})
+		case formats.ContentPartAudio:
+			if part.Data == "" {
+				return nil, fmt.Errorf("%w: OpenAI requires inline base64 data for audio input", formats.ErrUnsupportedContent)
+			}
+			result = append(result, ContentPart{Type: "input_audio", InputAudio: &InputAudio{
+				Data:   part.Data,
+				Format: formats.AudioFormat(formats.GuessMediaType(part)),
+			}})
+		case formats.ContentPartDocument:
+			if part.Data == "" {
+				return nil, fmt.Errorf("%w: OpenAI requires inline base64 data for document input", formats.ErrUnsupportedContent)
+			}
+			result = append(result, ContentPart{Type: "file", File: &File{
+				FileData: formats.DataURL(formats.GuessMediaType(part), part.Data),
+				Filename: part.Filename,
+			}})
+		}
+	}
+	return result, nil
+}
+
+func buildToolCalls(calls []formats.UniversalToolCall) []ToolCall {
+	var toolCalls []ToolCall
+	for _, call := range calls {
+		toolCalls = append(toolCalls, ToolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: ToolCallFunction{
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			},
+		})
+	}
+	return toolCalls
+}
+
+func parseToolChoice(choice interface{}) *formats.UniversalToolChoice {
+	switch c := choice.(type) {
+	case string:
+		return &formats.UniversalToolChoice{Mode: c}
+	case map[string]interface{}:
+		if function, ok := c["function"].(map[string]interface{}); ok {
+			name, _ := function["name"].(string)
+			return &formats.UniversalToolChoice{Mode: formats.ToolChoiceTool, Name: name}
+		}
+	}
+	return nil
+}
+
+func buildToolChoice(choice *formats.UniversalToolChoice) interface{} {
+	if choice == nil {
+		return nil
+	}
+	if choice.Mode == formats.ToolChoiceTool {
+		return map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": choice.Name},
+		}
+	}
+	return choice.Mode
+}
+
+func parseResponseFormat(format *ResponseFormat) *formats.UniversalResponseFormat {
+	if format == nil {
+		return nil
+	}
+	universal := &formats.UniversalResponseFormat{Type: format.Type}
+	if format.JSONSchema != nil {
+		universal.Name = format.JSONSchema.Name
+		universal.Description = format.JSONSchema.Description
+		universal.Schema = format.JSONSchema.Schema
+		universal.Strict = format.JSONSchema.Strict
+	}
+	return universal
+}
+
+func buildResponseFormat(format *formats.UniversalResponseFormat) *ResponseFormat {
+	if format == nil {
+		return nil
+	}
+	if format.Type != formats.ResponseFormatJSONSchema {
+		return &ResponseFormat{Type: format.Type}
+	}
+	name := format.Name
+	if name == "" {
+		// OpenAI requires a schema name, which other formats do not have
+		name = "response"
+	}
+	return &ResponseFormat{
+		Type: formats.ResponseFormatJSONSchema,
+		JSONSchema: &JSONSchema{
+			Name:        name,
+			Description: format.Description,
+			Schema:      format.Schema,
+			Strict:      format.Strict,
+		},
+	}
+}
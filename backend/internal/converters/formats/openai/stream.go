@@ -6,10 +6,23 @@ import (
 	"time"
 
 	"api-key-rotator/backend/internal/converters/formats"
+
+	"github.com/google/uuid"
 )
 
 // StreamHandler implements formats.StreamHandler for OpenAI format
-type StreamHandler struct{}
+type StreamHandler struct {
+	// Every chunk of a stream repeats the same ID, creation time and model
+	id      string
+	model   string
+	created int64
+
+	started    bool
+	finishSent bool
+	finished   bool
+	// usage is sent in a final chunk without choices, as OpenAI does with include_usage
+	usage *formats.UniversalUsage
+}
 
 func (h *StreamHandler) Name() string {
 	return "openai"
@@ -22,6 +35,7 @@ type StreamChunk struct {
 	Created int64          `json:"created"`
 	Model   string         `json:"model"`
 	Choices []StreamChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"` // Final chunk with stream_options.include_usage
 }
 
 type StreamChoice struct {
@@ -31,8 +45,24 @@ type StreamChoice struct {
 }
 
 type StreamDelta struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role             string           `json:"role,omitempty"`
+	Content          string           `json:"content,omitempty"`
+	ReasoningContent string           `json:"reasoning_content,omitempty"`
+	ToolCalls        []StreamToolCall `json:"tool_calls,omitempty"`
+}
+
+// StreamToolCall is an incremental tool call in a stream delta; id, type and
+// name are only present in the first delta of each call
+type StreamToolCall struct {
+	Index    int                    `json:"index"`
+	ID       string                 `json:"id,omitempty"`
+	Type     string                 `json:"type,omitempty"`
+	Function StreamToolCallFunction `json:"function"`
+}
+
+type StreamToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 // ParseStreamChunk implements StreamHandler
@@ -51,7 +81,16 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 		choice := streamChunk.Choices[0]
 		if choice.Delta != nil {
 			universal.Delta = choice.Delta.Content
+			universal.ReasoningDelta = choice.Delta.ReasoningContent
 			universal.Role = choice.Delta.Role
+			for _, call := range choice.Delta.ToolCalls {
+				universal.ToolCalls = append(universal.ToolCalls, formats.UniversalToolCallDelta{
+					Index:     call.Index,
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				})
+			}
 		}
 		if choice.FinishReason != nil && *choice.FinishReason != "" {
 			universal.StopReason = choice.FinishReason
@@ -59,44 +98,130 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 		}
 	}
 
+	if streamChunk.Usage != nil {
+		universal.Usage = &formats.UniversalUsage{
+			InputTokens:  streamChunk.Usage.PromptTokens,
+			OutputTokens: streamChunk.Usage.CompletionTokens,
+			TotalTokens:  streamChunk.Usage.TotalTokens,
+		}
+	}
+
 	return universal, nil
 }
 
 // BuildStreamChunk implements StreamHandler
-func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([]byte, error) {
-	streamChunk := StreamChunk{
-		ID:      chunk.ID,
-		Object:  "chat.completion.chunk",
-		Created: time.Now().Unix(),
-		Model:   chunk.Model,
-		Choices: []StreamChoice{
-			{
-				Index: 0,
-				Delta: &StreamDelta{
-					Content: chunk.Delta,
-				},
-			},
-		},
+func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([][]byte, error) {
+	if h.finished {
+		return nil, nil
+	}
+	if chunk.Usage != nil {
+		h.usage = chunk.Usage
+	}
+	// Thinking signatures have no OpenAI equivalent
+	if chunk.ReasoningSignature != "" && chunk.Delta == "" && chunk.ReasoningDelta == "" &&
+		len(chunk.ToolCalls) == 0 && chunk.StopReason == nil {
+		return nil, nil
 	}
 
-	if chunk.Role != "" {
-		streamChunk.Choices[0].Delta.Role = chunk.Role
+	events := h.startEvents(chunk.Model, chunk.ID)
+	if chunk.Delta == "" && chunk.ReasoningDelta == "" && len(chunk.ToolCalls) == 0 && chunk.StopReason == nil {
+		return events, nil
 	}
 
-	if chunk.StopReason != nil {
-		streamChunk.Choices[0].FinishReason = chunk.StopReason
-		streamChunk.Choices[0].Delta = &StreamDelta{}
+	delta := &StreamDelta{
+		Content:          chunk.Delta,
+		ReasoningContent: chunk.ReasoningDelta,
+	}
+	for _, call := range chunk.ToolCalls {
+		toolCall := StreamToolCall{
+			Index: call.Index,
+			ID:    call.ID,
+			Function: StreamToolCallFunction{
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			},
+		}
+		if call.ID != "" {
+			toolCall.Type = "function"
+		}
+		delta.ToolCalls = append(delta.ToolCalls, toolCall)
 	}
 
-	return json.Marshal(streamChunk)
+	if chunk.StopReason != nil {
+		h.finishSent = true
+	}
+	data, err := h.marshalChunk(delta, chunk.StopReason)
+	if err != nil {
+		return nil, err
+	}
+	return append(events, data), nil
 }
 
-// BuildStartEvent implements StreamHandler
-func (h *StreamHandler) BuildStartEvent(model string, id string) [][]byte {
-	return nil
+// startEvents returns the first chunk, which carries the assistant role, the first time it is called
+func (h *StreamHandler) startEvents(model string, id string) [][]byte {
+	if h.started {
+		return nil
+	}
+	h.started = true
+	h.id = id
+	if h.id == "" {
+		h.id = "chatcmpl-" + uuid.New().String()[:12]
+	}
+	h.model = model
+	h.created = time.Now().Unix()
+
+	data, err := h.marshalChunk(&StreamDelta{Role: "assistant"}, nil)
+	if err != nil {
+		return nil
+	}
+	return [][]byte{data}
 }
 
-// BuildEndEvent implements StreamHandler
+// BuildEndEvent implements StreamHandler - sends a finish reason if the source
+// stream ended without one, the usage chunk if usage is known, then the [DONE] marker
 func (h *StreamHandler) BuildEndEvent() [][]byte {
-	return [][]byte{[]byte("[DONE]")}
+	if h.finished {
+		return nil
+	}
+	events := h.startEvents("", "")
+	if !h.finishSent {
+		stop := "stop"
+		if data, err := h.marshalChunk(&StreamDelta{}, &stop); err == nil {
+			events = append(events, data)
+		}
+	}
+	if h.usage != nil {
+		usageChunk := StreamChunk{
+			ID:      h.id,
+			Object:  "chat.completion.chunk",
+			Created: h.created,
+			Model:   h.model,
+			Choices: []StreamChoice{},
+			Usage: &Usage{
+				PromptTokens:     h.usage.InputTokens,
+				CompletionTokens: h.usage.OutputTokens,
+				TotalTokens:      h.usage.TotalTokens,
+			},
+		}
+		if data, err := json.Marshal(usageChunk); err == nil {
+			events = append(events, data)
+		}
+	}
+	h.finished = true
+	return append(events, []byte("[DONE]"))
+}
+
+// marshalChunk builds a chunk of this stream with a single choice
+func (h *StreamHandler) marshalChunk(delta *StreamDelta, finishReason *string) ([]byte, error) {
+	return json.Marshal(StreamChunk{
+		ID:      h.id,
+		Object:  "chat.completion.chunk",
+		Created: h.created,
+		Model:   h.model,
+		Choices: []StreamChoice{{
+			Index:        0,
+			Delta:        delta,
+			FinishReason: finishReason,
+		}},
+	})
 }
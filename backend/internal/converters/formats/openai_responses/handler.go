@@ -11,7 +11,7 @@ import (
 )
 
 func init() {
-	formats.RegisterFormat("openai_responses", &Handler{}, &StreamHandler{})
+	formats.RegisterFormat("openai_responses", &Handler{}, func() formats.StreamHandler { return &StreamHandler{} })
 }
 
 // Handler implements FormatHandler for OpenAI Responses API format (v1/responses)
@@ -33,13 +33,69 @@ type Request struct {
 	PreviousResponseID string      `json:"previous_response_id,omitempty"` // For multi-turn conversations
 	Store              *bool       `json:"store,omitempty"`
 	Metadata           interface{} `json:"metadata,omitempty"`
+	Tools              []Tool      `json:"tools,omitempty"`
+	ToolChoice         interface{} `json:"tool_choice,omitempty"` // "auto", "none", "required" or {"type":"function","name":...}
+	ParallelToolCalls  *bool       `json:"parallel_tool_calls,omitempty"`
+	Reasoning          *Reasoning  `json:"reasoning,omitempty"`
+	Text               *TextConfig `json:"text,omitempty"`
+}
+
+// TextConfig configures the text output
+type TextConfig struct {
+	Format *TextFormat `json:"format,omitempty"`
+}
+
+// TextFormat constrains the output to text, any JSON object or a JSON schema
+type TextFormat struct {
+	Type        string          `json:"type"` // "text", "json_object" or "json_schema"
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// Reasoning configures reasoning models
+type Reasoning struct {
+	Effort  string `json:"effort,omitempty"`  // "none", "minimal", "low", "medium", "high"
+	Summary string `json:"summary,omitempty"` // "auto", "concise" or "detailed"
+}
+
+// Tool is a function tool definition; built-in tools (web search etc.) are not converted
+type Tool struct {
+	Type        string          `json:"type"` // "function"
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
 }
 
 // InputItem represents an item in the input array
 type InputItem struct {
-	Type    string      `json:"type"`              // "message"
+	Type    string      `json:"type"`              // "message", "function_call", "function_call_output"
 	Role    string      `json:"role,omitempty"`    // "user", "assistant", "system"
 	Content interface{} `json:"content,omitempty"` // Can be string or array of content parts
+
+	CallID    string `json:"call_id,omitempty"`   // function_call / function_call_output
+	Name      string `json:"name,omitempty"`      // function_call
+	Arguments string `json:"arguments,omitempty"` // function_call
+	Output    string `json:"output,omitempty"`    // function_call_output
+}
+
+// InputContentPart is a part of array input message content
+type InputContentPart struct {
+	Type       string      `json:"type"` // "input_text", "input_image", "input_file" or "input_audio"
+	Text       string      `json:"text,omitempty"`
+	ImageURL   string      `json:"image_url,omitempty"` // Remote URL or base64 data URL
+	Detail     string      `json:"detail,omitempty"`
+	FileData   string      `json:"file_data,omitempty"` // Base64 data URL
+	FileURL    string      `json:"file_url,omitempty"`
+	FileID     string      `json:"file_id,omitempty"`
+	Filename   string      `json:"filename,omitempty"`
+	InputAudio *InputAudio `json:"input_audio,omitempty"`
+}
+
+type InputAudio struct {
+	Data   string `json:"data"`   // Base64-encoded audio
+	Format string `json:"format"` // "wav", "mp3", ...
 }
 
 // Response types for OpenAI Responses API
@@ -56,16 +112,22 @@ type Response struct {
 
 // OutputItem represents an item in the output array
 type OutputItem struct {
-	Type    string        `json:"type"` // "message"
+	Type    string        `json:"type"` // "message", "function_call", "reasoning"
 	ID      string        `json:"id"`
 	Role    string        `json:"role,omitempty"`   // "assistant"
 	Status  string        `json:"status,omitempty"` // "completed"
 	Content []ContentPart `json:"content,omitempty"`
+
+	CallID    string `json:"call_id,omitempty"`   // function_call
+	Name      string `json:"name,omitempty"`      // function_call
+	Arguments string `json:"arguments,omitempty"` // function_call
+
+	Summary []ContentPart `json:"summary,omitempty"` // reasoning
 }
 
 // ContentPart represents a part of the content
 type ContentPart struct {
-	Type string `json:"type"` // "output_text"
+	Type string `json:"type"` // "output_text", "summary_text" or "reasoning_text"
 	Text string `json:"text,omitempty"`
 }
 
@@ -90,11 +152,37 @@ func (h *Handler) ParseRequest(body []byte) (*formats.UniversalRequest, error) {
 		Stream:      req.Stream,
 		Temperature: req.Temperature,
 		TopP:        req.TopP,
+
+		ParallelToolCalls: req.ParallelToolCalls,
+		ToolChoice:        parseToolChoice(req.ToolChoice),
 	}
 
 	if req.MaxOutputTokens != nil {
 		universal.MaxTokens = *req.MaxOutputTokens
 	}
+	if req.Reasoning != nil && req.Reasoning.Effort != "" {
+		universal.Reasoning = &formats.UniversalReasoning{Effort: req.Reasoning.Effort}
+	}
+	if req.Text != nil && req.Text.Format != nil {
+		universal.ResponseFormat = &formats.UniversalResponseFormat{
+			Type:        req.Text.Format.Type,
+			Name:        req.Text.Format.Name,
+			Description: req.Text.Format.Description,
+			Schema:      req.Text.Format.Schema,
+			Strict:      req.Text.Format.Strict,
+		}
+	}
+
+	for _, tool := range req.Tools {
+		if tool.Type != "function" {
+			continue
+		}
+		universal.Tools = append(universal.Tools, formats.UniversalTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
 
 	// Parse input - can be string or array of input items
 	switch input := req.Input.(type) {
@@ -109,13 +197,20 @@ func (h *Handler) ParseRequest(body []byte) (*formats.UniversalRequest, error) {
 		for _, item := range input {
 			if itemMap, ok := item.(map[string]interface{}); ok {
 				msg := parseInputItem(itemMap)
-				if msg != nil {
-					if msg.Role == "system" {
-						// Move system message to System field
-						universal.System = msg.Content
-					} else {
-						universal.Messages = append(universal.Messages, *msg)
-					}
+				if msg == nil {
+					continue
+				}
+				n := len(universal.Messages)
+				switch {
+				case msg.Role == "system" || msg.Role == "developer":
+					// Move system message to System field
+					universal.System = msg.Content
+				case msg.Role == "assistant" && msg.Content == "" && len(msg.ToolCalls) > 0 &&
+					n > 0 && universal.Messages[n-1].Role == "assistant":
+					// function_call items following an assistant message belong to that message
+					universal.Messages[n-1].ToolCalls = append(universal.Messages[n-1].ToolCalls, msg.ToolCalls...)
+				default:
+					universal.Messages = append(universal.Messages, *msg)
 				}
 			}
 		}
@@ -127,7 +222,25 @@ func (h *Handler) ParseRequest(body []byte) (*formats.UniversalRequest, error) {
 // parseInputItem extracts a message from an input item map
 func parseInputItem(item map[string]interface{}) *formats.UniversalMessage {
 	itemType, _ := item["type"].(string)
-	if itemType != "message" {
+	switch itemType {
+	case "function_call":
+		callID, _ := item["call_id"].(string)
+		name, _ := item["name"].(string)
+		arguments, _ := item["arguments"].(string)
+		return &formats.UniversalMessage{
+			Role:      "assistant",
+			ToolCalls: []formats.UniversalToolCall{{ID: callID, Name: name, Arguments: arguments}},
+		}
+	case "function_call_output":
+		callID, _ := item["call_id"].(string)
+		output, _ := item["output"].(string)
+		return &formats.UniversalMessage{
+			Role:        "tool",
+			ToolResults: []formats.UniversalToolResult{{ToolCallID: callID, Content: output}},
+		}
+	case "message", "":
+		// Items without a type are shorthand messages
+	default:
 		return nil
 	}
 
@@ -136,27 +249,96 @@ func parseInputItem(item map[string]interface{}) *formats.UniversalMessage {
 		role = "user"
 	}
 
-	var content string
-	switch c := item["content"].(type) {
-	case string:
-		content = c
-	case []interface{}:
-		// Array of content parts - extract text
-		for _, part := range c {
-			if partMap, ok := part.(map[string]interface{}); ok {
-				if partMap["type"] == "input_text" || partMap["type"] == "text" {
-					if text, ok := partMap["text"].(string); ok {
-						content += text
-					}
+	message := &formats.UniversalMessage{Role: role}
+	message.SetContentParts(parseContentParts(item["content"]))
+	return message
+}
+
+// parseContentParts converts string or array message content to universal content parts
+func parseContentParts(content interface{}) []formats.UniversalContentPart {
+	if text, ok := content.(string); ok {
+		return []formats.UniversalContentPart{{Type: formats.ContentPartText, Text: text}}
+	}
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil
+	}
+	var parts []InputContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil
+	}
+
+	var universal []formats.UniversalContentPart
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			universal = append(universal, formats.UniversalContentPart{Type: formats.ContentPartText, Text: part.Text})
+		case "input_image":
+			// Uploaded file IDs cannot be resolved outside OpenAI
+			if part.ImageURL == "" {
+				continue
+			}
+			image := formats.MediaPart(formats.ContentPartImage, "", "", part.ImageURL)
+			image.Detail = part.Detail
+			universal = append(universal, image)
+		case "input_file":
+			var document formats.UniversalContentPart
+			switch {
+			case part.FileData != "":
+				document = formats.MediaPart(formats.ContentPartDocument, "application/pdf", "", part.FileData)
+				if document.URL != "" {
+					// file_data without a data URL prefix is plain base64
+					document.Data, document.URL = document.URL, ""
 				}
+			case part.FileURL != "":
+				document = formats.MediaPart(formats.ContentPartDocument, "", "", part.FileURL)
+			default:
+				continue
 			}
+			document.Filename = part.Filename
+			universal = append(universal, document)
+		case "input_audio":
+			if part.InputAudio == nil {
+				continue
+			}
+			universal = append(universal, formats.MediaPart(formats.ContentPartAudio,
+				formats.AudioMediaType(part.InputAudio.Format), part.InputAudio.Data, ""))
 		}
 	}
+	return universal
+}
 
-	return &formats.UniversalMessage{
-		Role:    role,
-		Content: content,
+// buildContentParts converts universal content parts to Responses input content
+func buildContentParts(parts []formats.UniversalContentPart) ([]InputContentPart, error) {
+	result := make([]InputContentPart, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case formats.ContentPartText:
+			result = append(result, InputContentPart{Type: "input_text", Text: part.Text})
+		case formats.ContentPartImage:
+			url := part.URL
+			if part.Data != "" {
+				url = formats.DataURL(formats.GuessMediaType(part), part.Data)
+			}
+			result = append(result, InputContentPart{Type: "input_image", ImageURL: url, Detail: part.Detail})
+		case formats.ContentPartDocument:
+			file := InputContentPart{Type: "input_file", Filename: part.Filename, FileURL: part.URL}
+			if part.Data != "" {
+				file.FileData = formats.DataURL(formats.GuessMediaType(part), part.Data)
+				file.FileURL = ""
+			}
+			result = append(result, file)
+		case formats.ContentPartAudio:
+			if part.Data == "" {
+				return nil, fmt.Errorf("%w: OpenAI Responses requires inline base64 data for audio input", formats.ErrUnsupportedContent)
+			}
+			result = append(result, InputContentPart{Type: "input_audio", InputAudio: &InputAudio{
+				Data:   part.Data,
+				Format: formats.AudioFormat(formats.GuessMediaType(part)),
+			}})
+		}
 	}
+	return result, nil
 }
 
 // BuildRequest implements FormatHandler - builds v1/responses request from universal format
@@ -167,24 +349,84 @@ func (h *Handler) BuildRequest(req *formats.UniversalRequest) ([]byte, error) {
 		Stream:       req.Stream,
 		Temperature:  req.Temperature,
 		TopP:         req.TopP,
+
+		ParallelToolCalls: req.ParallelToolCalls,
+		ToolChoice:        buildToolChoice(req.ToolChoice),
 	}
 
 	if req.MaxTokens > 0 {
 		responsesReq.MaxOutputTokens = &req.MaxTokens
 	}
+	// Request reasoning summaries so the client receives the thinking content;
+	// disabled reasoning is left out since not every model accepts "none"
+	if req.Reasoning != nil && !req.Reasoning.Disabled() {
+		responsesReq.Reasoning = &Reasoning{Effort: req.Reasoning.EffortLevel(), Summary: "auto"}
+	}
+	if format := req.ResponseFormat; format != nil {
+		textFormat := &TextFormat{Type: format.Type}
+		if format.Type == formats.ResponseFormatJSONSchema {
+			textFormat.Name = format.Name
+			if textFormat.Name == "" {
+				// The Responses API requires a schema name, which other formats do not have
+				textFormat.Name = "response"
+			}
+			textFormat.Description = format.Description
+			textFormat.Schema = format.Schema
+			textFormat.Strict = format.Strict
+		}
+		responsesReq.Text = &TextConfig{Format: textFormat}
+	}
+
+	for _, tool := range req.Tools {
+		responsesReq.Tools = append(responsesReq.Tools, Tool{
+			Type:        "function",
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
 
-	// Build input array from messages
+	// Build input array from messages; tool calls and results are separate items
 	input := make([]InputItem, 0, len(req.Messages))
 	for _, msg := range req.Messages {
-		input = append(input, InputItem{
-			Type:    "message",
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
+		if msg.Role != "tool" && (msg.Content != "" || msg.HasMedia() || len(msg.ToolCalls) == 0) {
+			item := InputItem{
+				Type:    "message",
+				Role:    msg.Role,
+				Content: msg.Content,
+			}
+			if msg.HasMedia() {
+				parts, err := buildContentParts(msg.Parts)
+				if err != nil {
+					return nil, err
+				}
+				item.Content = parts
+			}
+			input = append(input, item)
+		}
+		for _, call := range msg.ToolCalls {
+			input = append(input, InputItem{
+				Type:      "function_call",
+				CallID:    call.ID,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			})
+		}
+		for _, result := range msg.ToolResults {
+			input = append(input, InputItem{
+				Type:   "function_call_output",
+				CallID: result.ToolCallID,
+				Output: result.Content,
+			})
+		}
 	}
 
-	if len(input) == 1 {
-		// Single message - can be simplified to string
+	// A single text user message can be simplified to a string
+	simpleInput := len(input) == 1 && input[0].Type == "message" && input[0].Role == "user"
+	if simpleInput {
+		_, simpleInput = input[0].Content.(string)
+	}
+	if simpleInput {
 		responsesReq.Input = input[0].Content
 	} else {
 		responsesReq.Input = input
@@ -193,6 +435,11 @@ func (h *Handler) BuildRequest(req *formats.UniversalRequest) ([]byte, error) {
 	return json.Marshal(responsesReq)
 }
 
+// AcceptsMediaURL implements formats.MediaURLAcceptor; images and files can be passed by URL
+func (h *Handler) AcceptsMediaURL(part formats.UniversalContentPart) bool {
+	return part.Type == formats.ContentPartImage || part.Type == formats.ContentPartDocument
+}
+
 // ParseResponse implements FormatHandler - parses v1/responses response to universal format
 func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error) {
 	var resp Response
@@ -208,21 +455,28 @@ func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error)
 
 	// Extract content from output items
 	for _, item := range resp.Output {
-		if item.Type == "message" && item.Role == "assistant" {
+		switch {
+		case item.Type == "message" && item.Role == "assistant":
 			for _, part := range item.Content {
 				if part.Type == "output_text" {
 					universal.Content += part.Text
 				}
 			}
+		case item.Type == "reasoning":
+			for _, part := range append(item.Summary, item.Content...) {
+				universal.Reasoning += part.Text
+			}
+		case item.Type == "function_call":
+			universal.ToolCalls = append(universal.ToolCalls, formats.UniversalToolCall{
+				ID:        item.CallID,
+				Name:      item.Name,
+				Arguments: item.Arguments,
+			})
 		}
 	}
 
 	// Map status to stop reason
-	if resp.Status == "completed" {
-		universal.StopReason = "stop"
-	} else if resp.Status != "" {
-		universal.StopReason = resp.Status
-	}
+	universal.StopReason = mapStatusToStopReason(resp.Status, len(universal.ToolCalls) > 0)
 
 	if resp.Usage != nil {
 		universal.Usage = &formats.UniversalUsage{
@@ -239,31 +493,45 @@ func (h *Handler) ParseResponse(body []byte) (*formats.UniversalResponse, error)
 func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error) {
 	messageID := "msg_" + uuid.New().String()[:12]
 
-	status := "completed"
-	if resp.StopReason != "" && resp.StopReason != "stop" {
-		status = resp.StopReason
-	}
-
 	responsesResp := Response{
 		ID:        resp.ID,
 		Object:    "response",
 		CreatedAt: time.Now().Unix(),
 		Model:     resp.Model,
-		Status:    status,
-		Output: []OutputItem{
-			{
-				Type:   "message",
-				ID:     messageID,
-				Role:   "assistant",
-				Status: "completed",
-				Content: []ContentPart{
-					{
-						Type: "output_text",
-						Text: resp.Content,
-					},
+		Status:    mapStopReasonToStatus(resp.StopReason),
+		Output:    []OutputItem{},
+	}
+
+	if resp.Reasoning != "" {
+		responsesResp.Output = append(responsesResp.Output, OutputItem{
+			Type:    "reasoning",
+			ID:      "rs_" + uuid.New().String()[:12],
+			Summary: []ContentPart{{Type: "summary_text", Text: resp.Reasoning}},
+		})
+	}
+	if resp.Content != "" || len(resp.ToolCalls) == 0 {
+		responsesResp.Output = append(responsesResp.Output, OutputItem{
+			Type:   "message",
+			ID:     messageID,
+			Role:   "assistant",
+			Status: "completed",
+			Content: []ContentPart{
+				{
+					Type: "output_text",
+					Text: resp.Content,
 				},
 			},
-		},
+		})
+	}
+	for _, call := range resp.ToolCalls {
+		responsesResp.Output = append(responsesResp.Output, OutputItem{
+			Type:      "function_call",
+			ID:        "fc_" + uuid.New().String()[:12],
+			Status:    "completed",
+			CallID:    call.ID,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
 	}
 
 	if resp.Usage != nil {
@@ -278,7 +546,7 @@ func (h *Handler) BuildResponse(resp *formats.UniversalResponse) ([]byte, error)
 }
 
 // GetAPIPath implements FormatHandler - converts client action to target API path
-func (h *Handler) GetAPIPath(action string) string {
+func (h *Handler) GetAPIPath(action string, stream bool) string {
 	// When client sends to v1/responses, we need to convert to chat/completions
 	// for the actual backend API call
 	if action == "v1/responses" || action == "responses" {
@@ -294,3 +562,52 @@ func (h *Handler) GetClientAction(apiPath string) string {
 	}
 	return apiPath
 }
+
+func parseToolChoice(choice interface{}) *formats.UniversalToolChoice {
+	switch c := choice.(type) {
+	case string:
+		return &formats.UniversalToolChoice{Mode: c}
+	case map[string]interface{}:
+		if name, ok := c["name"].(string); ok && c["type"] == "function" {
+			return &formats.UniversalToolChoice{Mode: formats.ToolChoiceTool, Name: name}
+		}
+	}
+	return nil
+}
+
+func buildToolChoice(choice *formats.UniversalToolChoice) interface{} {
+	if choice == nil {
+		return nil
+	}
+	if choice.Mode == formats.ToolChoiceTool {
+		return map[string]string{"type": "function", "name": choice.Name}
+	}
+	return choice.Mode
+}
+
+// mapStatusToStopReason converts a response status to a universal stop reason
+func mapStatusToStopReason(status string, hasToolCalls bool) string {
+	switch status {
+	case "completed":
+		if hasToolCalls {
+			return "tool_calls"
+		}
+		return "stop"
+	case "incomplete":
+		return "length"
+	default:
+		return status
+	}
+}
+
+// mapStopReasonToStatus converts a universal stop reason to a response status
+func mapStopReasonToStatus(reason string) string {
+	switch reason {
+	case "", "stop", "tool_calls":
+		return "completed"
+	case "length":
+		return "incomplete"
+	default:
+		return reason
+	}
+}
@@ -11,7 +11,25 @@ import (
 )
 
 // StreamHandler implements formats.StreamHandler for OpenAI Responses API format
-type StreamHandler struct{}
+type StreamHandler struct {
+	id        string
+	model     string
+	createdAt int64
+	sequence  int
+
+	started  bool
+	finished bool
+
+	// output holds the items built so far; the last one stays open until content
+	// of another kind starts, then its done events are sent
+	output   []OutputItem
+	itemOpen bool
+	// toolItems maps tool call indices to their output indices
+	toolItems  map[int]int
+	stopReason string
+	// usage is reported in the final response event
+	usage *formats.UniversalUsage
+}
 
 func (h *StreamHandler) Name() string {
 	return "openai_responses"
@@ -27,45 +45,114 @@ type StreamEvent struct {
 	ContentIndex int       `json:"content_index,omitempty"`
 }
 
-// ResponseCreatedEvent is emitted at the start of streaming
-type ResponseCreatedEvent struct {
-	Type     string   `json:"type"` // "response.created"
-	Response Response `json:"response"`
+// ResponseEvent reports the response state: "response.created", "response.in_progress",
+// "response.completed" or "response.incomplete"
+type ResponseEvent struct {
+	Type           string   `json:"type"`
+	SequenceNumber int      `json:"sequence_number"`
+	Response       Response `json:"response"`
+}
+
+// OutputItemEvent is emitted when an output item (message, reasoning or function call)
+// is added ("response.output_item.added") or complete ("response.output_item.done")
+type OutputItemEvent struct {
+	Type           string     `json:"type"`
+	SequenceNumber int        `json:"sequence_number"`
+	OutputIndex    int        `json:"output_index"`
+	Item           OutputItem `json:"item"`
 }
 
-// ResponseDoneEvent is emitted at the end of streaming
-type ResponseDoneEvent struct {
-	Type     string   `json:"type"` // "response.done"
-	Response Response `json:"response"`
+// ContentPartEvent is emitted when a message content part is added
+// ("response.content_part.added") or complete ("response.content_part.done")
+type ContentPartEvent struct {
+	Type           string      `json:"type"`
+	SequenceNumber int         `json:"sequence_number"`
+	ItemID         string      `json:"item_id"`
+	OutputIndex    int         `json:"output_index"`
+	ContentIndex   int         `json:"content_index"`
+	Part           ContentPart `json:"part"`
 }
 
 // OutputTextDeltaEvent is emitted for each text chunk
 type OutputTextDeltaEvent struct {
-	Type         string `json:"type"` // "response.output_text.delta"
-	ItemID       string `json:"item_id"`
-	OutputIndex  int    `json:"output_index"`
-	ContentIndex int    `json:"content_index"`
-	Delta        string `json:"delta"`
+	Type           string `json:"type"` // "response.output_text.delta"
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	ContentIndex   int    `json:"content_index"`
+	Delta          string `json:"delta"`
 }
 
 // OutputTextDoneEvent is emitted when text output is complete
 type OutputTextDoneEvent struct {
-	Type         string `json:"type"` // "response.output_text.done"
-	ItemID       string `json:"item_id"`
-	OutputIndex  int    `json:"output_index"`
-	ContentIndex int    `json:"content_index"`
-	Text         string `json:"text"`
+	Type           string `json:"type"` // "response.output_text.done"
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	ContentIndex   int    `json:"content_index"`
+	Text           string `json:"text"`
+}
+
+// ReasoningSummaryPartEvent is emitted when a reasoning summary part is added
+// ("response.reasoning_summary_part.added") or complete ("response.reasoning_summary_part.done")
+type ReasoningSummaryPartEvent struct {
+	Type           string      `json:"type"`
+	SequenceNumber int         `json:"sequence_number"`
+	ItemID         string      `json:"item_id"`
+	OutputIndex    int         `json:"output_index"`
+	SummaryIndex   int         `json:"summary_index"`
+	Part           ContentPart `json:"part"`
+}
+
+// ReasoningSummaryTextDeltaEvent is emitted for each fragment of a reasoning summary
+type ReasoningSummaryTextDeltaEvent struct {
+	Type           string `json:"type"` // "response.reasoning_summary_text.delta"
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	SummaryIndex   int    `json:"summary_index"`
+	Delta          string `json:"delta"`
+}
+
+// ReasoningSummaryTextDoneEvent is emitted when a reasoning summary is complete
+type ReasoningSummaryTextDoneEvent struct {
+	Type           string `json:"type"` // "response.reasoning_summary_text.done"
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	SummaryIndex   int    `json:"summary_index"`
+	Text           string `json:"text"`
+}
+
+// FunctionCallArgumentsDeltaEvent is emitted for each fragment of function call arguments
+type FunctionCallArgumentsDeltaEvent struct {
+	Type           string `json:"type"` // "response.function_call_arguments.delta"
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	Delta          string `json:"delta"`
+}
+
+// FunctionCallArgumentsDoneEvent is emitted when function call arguments are complete
+type FunctionCallArgumentsDoneEvent struct {
+	Type           string `json:"type"` // "response.function_call_arguments.done"
+	SequenceNumber int    `json:"sequence_number"`
+	ItemID         string `json:"item_id"`
+	OutputIndex    int    `json:"output_index"`
+	Arguments      string `json:"arguments"`
 }
 
 // ParseStreamChunk implements StreamHandler - parses Responses API stream chunk to universal format
 func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStreamChunk, error) {
 	// First try to parse as a generic event to determine type
 	var event struct {
-		Type     string    `json:"type"`
-		Delta    string    `json:"delta,omitempty"`
-		Text     string    `json:"text,omitempty"`
-		ItemID   string    `json:"item_id,omitempty"`
-		Response *Response `json:"response,omitempty"`
+		Type        string      `json:"type"`
+		Delta       string      `json:"delta,omitempty"`
+		Text        string      `json:"text,omitempty"`
+		ItemID      string      `json:"item_id,omitempty"`
+		OutputIndex int         `json:"output_index,omitempty"`
+		Item        *OutputItem `json:"item,omitempty"`
+		Response    *Response   `json:"response,omitempty"`
 	}
 
 	if err := json.Unmarshal(chunk, &event); err != nil {
@@ -88,22 +175,50 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 		// Text delta event
 		universal.Delta = event.Delta
 
+	case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+		universal.ReasoningDelta = event.Delta
+
 	case "response.output_text.done":
 		// Text complete event - could include full text
 		// Not marking as last since response.done comes after
 
-	case "response.done":
+	case "response.done", "response.completed", "response.incomplete":
 		// Final event
 		universal.IsLast = true
 		if event.Response != nil {
-			stopReason := "stop"
-			if event.Response.Status != "completed" {
-				stopReason = event.Response.Status
+			hasToolCalls := false
+			for _, item := range event.Response.Output {
+				hasToolCalls = hasToolCalls || item.Type == "function_call"
 			}
+			stopReason := mapStatusToStopReason(event.Response.Status, hasToolCalls)
 			universal.StopReason = &stopReason
+			if event.Response.Usage != nil {
+				universal.Usage = &formats.UniversalUsage{
+					InputTokens:  event.Response.Usage.InputTokens,
+					OutputTokens: event.Response.Usage.OutputTokens,
+					TotalTokens:  event.Response.Usage.TotalTokens,
+				}
+			}
+		}
+
+	case "response.output_item.added":
+		// A function_call item starts a tool call; its arguments follow as deltas
+		if event.Item != nil && event.Item.Type == "function_call" {
+			universal.ToolCalls = []formats.UniversalToolCallDelta{{
+				Index:     event.OutputIndex,
+				ID:        event.Item.CallID,
+				Name:      event.Item.Name,
+				Arguments: event.Item.Arguments,
+			}}
 		}
 
-	case "response.output_item.added", "response.output_item.done",
+	case "response.function_call_arguments.delta":
+		universal.ToolCalls = []formats.UniversalToolCallDelta{{
+			Index:     event.OutputIndex,
+			Arguments: event.Delta,
+		}}
+
+	case "response.output_item.done", "response.function_call_arguments.done",
 		"response.content_part.added", "response.content_part.done":
 		// Structural events - skip
 
@@ -114,146 +229,232 @@ func (h *StreamHandler) ParseStreamChunk(chunk []byte) (*formats.UniversalStream
 	return universal, nil
 }
 
-// BuildStreamChunk implements StreamHandler - builds Responses API stream chunk from universal format
-func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([]byte, error) {
-	// Generate consistent IDs for the stream
-	itemID := "item_" + uuid.New().String()[:8]
-
-	if chunk.IsFirst {
-		// Build response.created event
-		event := ResponseCreatedEvent{
-			Type: "response.created",
-			Response: Response{
-				ID:        chunk.ID,
-				Object:    "response",
-				CreatedAt: time.Now().Unix(),
-				Model:     chunk.Model,
-				Status:    "in_progress",
-				Output:    []OutputItem{},
-			},
-		}
-		return json.Marshal(event)
+// EventName implements formats.NamedEventStreamer; events are named after their type
+func (h *StreamHandler) EventName(data []byte) string {
+	var event struct {
+		Type string `json:"type"`
 	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return ""
+	}
+	return event.Type
+}
 
-	if chunk.StopReason != nil {
-		// Build response.done event
-		status := "completed"
-		if *chunk.StopReason != "stop" && *chunk.StopReason != "" {
-			status = *chunk.StopReason
+// BuildStreamChunk implements StreamHandler - builds Responses API stream events from universal format
+// Reasoning, text and each tool call become separate output items in the order they arrive
+func (h *StreamHandler) BuildStreamChunk(chunk *formats.UniversalStreamChunk) ([][]byte, error) {
+	if h.finished {
+		return nil, nil
+	}
+	if chunk.Usage != nil {
+		h.usage = chunk.Usage
+	}
+	events := h.startEvents(chunk.Model, chunk.ID)
+
+	if chunk.ReasoningDelta != "" {
+		index := len(h.output) - 1
+		if !h.isOpen("reasoning") {
+			events = append(events, h.closeItem()...)
+			index = len(h.output)
+			item := OutputItem{Type: "reasoning", ID: "rs_" + uuid.New().String()[:12], Summary: []ContentPart{}}
+			h.output = append(h.output, item)
+			h.itemOpen = true
+			events = append(events,
+				OutputItemEvent{Type: "response.output_item.added", SequenceNumber: h.nextSequence(), OutputIndex: index, Item: item},
+				ReasoningSummaryPartEvent{Type: "response.reasoning_summary_part.added", SequenceNumber: h.nextSequence(),
+					ItemID: item.ID, OutputIndex: index, Part: ContentPart{Type: "summary_text"}},
+			)
+			h.output[index].Summary = []ContentPart{{Type: "summary_text"}}
 		}
+		item := &h.output[index]
+		item.Summary[0].Text += chunk.ReasoningDelta
+		events = append(events, ReasoningSummaryTextDeltaEvent{
+			Type:           "response.reasoning_summary_text.delta",
+			SequenceNumber: h.nextSequence(),
+			ItemID:         item.ID,
+			OutputIndex:    index,
+			Delta:          chunk.ReasoningDelta,
+		})
+	}
 
-		event := ResponseDoneEvent{
-			Type: "response.done",
-			Response: Response{
-				ID:        chunk.ID,
-				Object:    "response",
-				CreatedAt: time.Now().Unix(),
-				Model:     chunk.Model,
-				Status:    status,
-				Output:    []OutputItem{},
-			},
+	if chunk.Delta != "" {
+		index := len(h.output) - 1
+		if !h.isOpen("message") {
+			events = append(events, h.closeItem()...)
+			index = len(h.output)
+			item := OutputItem{Type: "message", ID: "msg_" + uuid.New().String()[:12], Role: "assistant", Status: "in_progress", Content: []ContentPart{}}
+			h.output = append(h.output, item)
+			h.itemOpen = true
+			events = append(events,
+				OutputItemEvent{Type: "response.output_item.added", SequenceNumber: h.nextSequence(), OutputIndex: index, Item: item},
+				ContentPartEvent{Type: "response.content_part.added", SequenceNumber: h.nextSequence(),
+					ItemID: item.ID, OutputIndex: index, Part: ContentPart{Type: "output_text"}},
+			)
+			h.output[index].Content = []ContentPart{{Type: "output_text"}}
 		}
-		return json.Marshal(event)
+		item := &h.output[index]
+		item.Content[0].Text += chunk.Delta
+		events = append(events, OutputTextDeltaEvent{
+			Type:           "response.output_text.delta",
+			SequenceNumber: h.nextSequence(),
+			ItemID:         item.ID,
+			OutputIndex:    index,
+			Delta:          chunk.Delta,
+		})
 	}
 
-	if chunk.Delta != "" {
-		// Build response.output_text.delta event
-		event := OutputTextDeltaEvent{
-			Type:         "response.output_text.delta",
-			ItemID:       itemID,
-			OutputIndex:  0,
-			ContentIndex: 0,
-			Delta:        chunk.Delta,
+	for _, call := range chunk.ToolCalls {
+		index, ok := h.toolItems[call.Index]
+		if call.ID != "" || call.Name != "" || !ok {
+			events = append(events, h.closeItem()...)
+			index = len(h.output)
+			item := OutputItem{Type: "function_call", ID: "fc_" + uuid.New().String()[:12], Status: "in_progress", CallID: call.ID, Name: call.Name}
+			h.output = append(h.output, item)
+			h.itemOpen = true
+			if h.toolItems == nil {
+				h.toolItems = make(map[int]int)
+			}
+			h.toolItems[call.Index] = index
+			events = append(events, OutputItemEvent{Type: "response.output_item.added", SequenceNumber: h.nextSequence(), OutputIndex: index, Item: item})
+		}
+		if call.Arguments != "" {
+			item := &h.output[index]
+			item.Arguments += call.Arguments
+			events = append(events, FunctionCallArgumentsDeltaEvent{
+				Type:           "response.function_call_arguments.delta",
+				SequenceNumber: h.nextSequence(),
+				ItemID:         item.ID,
+				OutputIndex:    index,
+				Delta:          call.Arguments,
+			})
 		}
-		return json.Marshal(event)
 	}
 
-	return nil, nil
+	if chunk.StopReason != nil {
+		// The final response event follows in BuildEndEvent, after any trailing usage
+		h.stopReason = *chunk.StopReason
+		events = append(events, h.closeItem()...)
+	}
+
+	return marshalEvents(events)
 }
 
-// BuildStartEvent implements StreamHandler - returns initial events for streaming
-func (h *StreamHandler) BuildStartEvent(model string, id string) [][]byte {
-	if id == "" {
-		id = "resp_" + uuid.New().String()[:12]
+// BuildEndEvent implements StreamHandler - returns final events for streaming
+func (h *StreamHandler) BuildEndEvent() [][]byte {
+	if h.finished {
+		return nil
 	}
-
-	itemID := "item_" + uuid.New().String()[:8]
-
-	events := make([][]byte, 0)
-
-	// 1. response.created
-	createdEvent := ResponseCreatedEvent{
-		Type: "response.created",
-		Response: Response{
-			ID:        id,
-			Object:    "response",
-			CreatedAt: time.Now().Unix(),
-			Model:     model,
-			Status:    "in_progress",
-			Output:    []OutputItem{},
-		},
+	events := h.startEvents("", "")
+	events = append(events, h.closeItem()...)
+
+	response := h.response(mapStopReasonToStatus(h.stopReason))
+	if h.usage != nil {
+		response.Usage = &Usage{
+			InputTokens:  h.usage.InputTokens,
+			OutputTokens: h.usage.OutputTokens,
+			TotalTokens:  h.usage.TotalTokens,
+		}
 	}
-	if data, err := json.Marshal(createdEvent); err == nil {
-		events = append(events, data)
+	eventType := "response.completed"
+	if response.Status == "incomplete" {
+		eventType = "response.incomplete"
 	}
+	events = append(events, ResponseEvent{Type: eventType, SequenceNumber: h.nextSequence(), Response: response})
+	h.finished = true
 
-	// 2. response.output_item.added
-	itemAddedEvent := struct {
-		Type        string     `json:"type"`
-		OutputIndex int        `json:"output_index"`
-		Item        OutputItem `json:"item"`
-	}{
-		Type:        "response.output_item.added",
-		OutputIndex: 0,
-		Item: OutputItem{
-			Type:    "message",
-			ID:      itemID,
-			Role:    "assistant",
-			Status:  "in_progress",
-			Content: []ContentPart{},
-		},
-	}
-	if data, err := json.Marshal(itemAddedEvent); err == nil {
-		events = append(events, data)
-	}
+	result, _ := marshalEvents(events)
+	return result
+}
 
-	// 3. response.content_part.added
-	contentAddedEvent := struct {
-		Type         string      `json:"type"`
-		ItemID       string      `json:"item_id"`
-		OutputIndex  int         `json:"output_index"`
-		ContentIndex int         `json:"content_index"`
-		Part         ContentPart `json:"part"`
-	}{
-		Type:         "response.content_part.added",
-		ItemID:       itemID,
-		OutputIndex:  0,
-		ContentIndex: 0,
-		Part: ContentPart{
-			Type: "output_text",
-			Text: "",
-		},
+// startEvents returns the response.created and response.in_progress events the first time it is called
+func (h *StreamHandler) startEvents(model, id string) []interface{} {
+	if h.started {
+		return nil
 	}
-	if data, err := json.Marshal(contentAddedEvent); err == nil {
-		events = append(events, data)
+	h.started = true
+	h.id = id
+	if h.id == "" {
+		h.id = "resp_" + uuid.New().String()[:12]
 	}
+	h.model = model
+	h.createdAt = time.Now().Unix()
+	return []interface{}{
+		ResponseEvent{Type: "response.created", SequenceNumber: h.nextSequence(), Response: h.response("in_progress")},
+		ResponseEvent{Type: "response.in_progress", SequenceNumber: h.nextSequence(), Response: h.response("in_progress")},
+	}
+}
 
-	return events
+// isOpen reports whether the last output item is open and of the given type
+func (h *StreamHandler) isOpen(itemType string) bool {
+	return h.itemOpen && h.output[len(h.output)-1].Type == itemType
 }
 
-// BuildEndEvent implements StreamHandler - returns final events for streaming
-func (h *StreamHandler) BuildEndEvent() [][]byte {
-	events := make([][]byte, 0)
+// closeItem sends the done events for the open output item, if any
+func (h *StreamHandler) closeItem() []interface{} {
+	if !h.itemOpen {
+		return nil
+	}
+	h.itemOpen = false
+	index := len(h.output) - 1
+	item := &h.output[index]
+
+	var events []interface{}
+	switch item.Type {
+	case "reasoning":
+		part := item.Summary[0]
+		events = append(events,
+			ReasoningSummaryTextDoneEvent{Type: "response.reasoning_summary_text.done", SequenceNumber: h.nextSequence(),
+				ItemID: item.ID, OutputIndex: index, Text: part.Text},
+			ReasoningSummaryPartEvent{Type: "response.reasoning_summary_part.done", SequenceNumber: h.nextSequence(),
+				ItemID: item.ID, OutputIndex: index, Part: part},
+		)
+	case "message":
+		part := item.Content[0]
+		events = append(events,
+			OutputTextDoneEvent{Type: "response.output_text.done", SequenceNumber: h.nextSequence(),
+				ItemID: item.ID, OutputIndex: index, Text: part.Text},
+			ContentPartEvent{Type: "response.content_part.done", SequenceNumber: h.nextSequence(),
+				ItemID: item.ID, OutputIndex: index, Part: part},
+		)
+		item.Status = "completed"
+	case "function_call":
+		events = append(events, FunctionCallArgumentsDoneEvent{Type: "response.function_call_arguments.done", SequenceNumber: h.nextSequence(),
+			ItemID: item.ID, OutputIndex: index, Arguments: item.Arguments})
+		item.Status = "completed"
+	}
+	return append(events, OutputItemEvent{Type: "response.output_item.done", SequenceNumber: h.nextSequence(), OutputIndex: index, Item: *item})
+}
 
-	// response.done - simplified
-	doneEvent := struct {
-		Type string `json:"type"`
-	}{
-		Type: "response.done",
+// response returns the response object of this stream with the given status
+func (h *StreamHandler) response(status string) Response {
+	output := h.output
+	if status == "in_progress" || output == nil {
+		output = []OutputItem{}
 	}
-	if data, err := json.Marshal(doneEvent); err == nil {
-		events = append(events, data)
+	return Response{
+		ID:        h.id,
+		Object:    "response",
+		CreatedAt: h.createdAt,
+		Model:     h.model,
+		Status:    status,
+		Output:    output,
 	}
+}
+
+func (h *StreamHandler) nextSequence() int {
+	sequence := h.sequence
+	h.sequence++
+	return sequence
+}
 
-	return events
+func marshalEvents(events []interface{}) ([][]byte, error) {
+	result := make([][]byte, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, nil
 }
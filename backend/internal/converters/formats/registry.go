@@ -11,13 +11,14 @@ var (
 	mu       sync.RWMutex
 )
 
-// RegisterFormat registers a format handler with the given name
-func RegisterFormat(name string, handler FormatHandler, streamHandler StreamHandler) {
+// RegisterFormat registers a format handler with the given name.
+// newStreamHandler creates a stream handler for a single stream.
+func RegisterFormat(name string, handler FormatHandler, newStreamHandler func() StreamHandler) {
 	mu.Lock()
 	defer mu.Unlock()
 	registry[name] = &FormatInfo{
-		Handler:       handler,
-		StreamHandler: streamHandler,
+		Handler:          handler,
+		NewStreamHandler: newStreamHandler,
 	}
 }
 
@@ -41,13 +42,13 @@ func GetHandler(name string) (FormatHandler, error) {
 	return info.Handler, nil
 }
 
-// GetStreamHandler returns the StreamHandler for the given format name
+// GetStreamHandler returns a new StreamHandler for one stream in the given format
 func GetStreamHandler(name string) (StreamHandler, error) {
 	info, err := GetFormat(name)
 	if err != nil {
 		return nil, err
 	}
-	return info.StreamHandler, nil
+	return info.NewStreamHandler(), nil
 }
 
 // ListFormats returns a list of all registered format names
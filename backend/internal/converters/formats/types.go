@@ -1,5 +1,13 @@
 package formats
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"path"
+	"strings"
+)
+
 // Universal message types for format-agnostic conversion
 // All format handlers convert to/from these types
 
@@ -13,22 +21,332 @@ type UniversalRequest struct {
 	TopP        *float64           `json:"top_p,omitempty"`
 	Stream      bool               `json:"stream,omitempty"`
 	Stop        []string           `json:"stop,omitempty"`
+
+	Tools             []UniversalTool      `json:"tools,omitempty"`
+	ToolChoice        *UniversalToolChoice `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool                `json:"parallel_tool_calls,omitempty"`
+
+	Reasoning *UniversalReasoning `json:"reasoning,omitempty"` // Nil leaves reasoning at the provider default
+
+	ResponseFormat *UniversalResponseFormat `json:"response_format,omitempty"` // Nil means free-form text
+}
+
+// Response format types
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object" // Any valid JSON object
+	ResponseFormatJSONSchema = "json_schema" // JSON matching Schema
+)
+
+// StructuredOutputToolName is the tool used to emulate structured output on
+// targets without native support. Calls to it are turned back into text content.
+const StructuredOutputToolName = "structured_output"
+
+// UniversalResponseFormat constrains the format of the model's text output
+type UniversalResponseFormat struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"` // JSON schema when Type is ResponseFormatJSONSchema
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// IsJSON reports whether the format asks for JSON output
+func (f *UniversalResponseFormat) IsJSON() bool {
+	return f != nil && (f.Type == ResponseFormatJSONObject || f.Type == ResponseFormatJSONSchema)
+}
+
+// StructuredOutputTool returns the tool that emulates a JSON response format
+func (f *UniversalResponseFormat) StructuredOutputTool() UniversalTool {
+	schema := f.Schema
+	if f.Type != ResponseFormatJSONSchema || len(schema) == 0 {
+		schema = json.RawMessage(`{"type":"object"}`)
+	}
+	description := f.Description
+	if description == "" {
+		description = "Respond to the user with structured output. Always call this tool to give your final answer."
+	}
+	return UniversalTool{Name: StructuredOutputToolName, Description: description, Parameters: schema}
+}
+
+// Reasoning effort levels
+const (
+	ReasoningEffortNone    = "none" // Reasoning explicitly disabled
+	ReasoningEffortMinimal = "minimal"
+	ReasoningEffortLow     = "low"
+	ReasoningEffortMedium  = "medium"
+	ReasoningEffortHigh    = "high"
+)
+
+// UniversalReasoning configures extended reasoning ("thinking"). Formats set
+// either an effort level or a token budget; the other one is derived from it.
+// Both empty means reasoning is enabled with the provider's default budget.
+type UniversalReasoning struct {
+	Effort       string `json:"effort,omitempty"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// Disabled reports whether reasoning was explicitly turned off
+func (r *UniversalReasoning) Disabled() bool {
+	return r.Effort == ReasoningEffortNone
+}
+
+// Budget returns the thinking token budget, derived from the effort level when
+// no budget was given; 0 means unspecified
+func (r *UniversalReasoning) Budget() int {
+	if r.BudgetTokens > 0 {
+		return r.BudgetTokens
+	}
+	switch r.Effort {
+	case ReasoningEffortMinimal, ReasoningEffortLow:
+		return 1024
+	case ReasoningEffortMedium:
+		return 8192
+	case ReasoningEffortHigh:
+		return 24576
+	default:
+		return 0
+	}
+}
+
+// EffortLevel returns the effort level, derived from the token budget when no
+// effort was given; "" means unspecified
+func (r *UniversalReasoning) EffortLevel() string {
+	if r.Effort != "" {
+		return r.Effort
+	}
+	switch {
+	case r.BudgetTokens <= 0:
+		return ""
+	case r.BudgetTokens <= 1024:
+		return ReasoningEffortLow
+	case r.BudgetTokens <= 8192:
+		return ReasoningEffortMedium
+	default:
+		return ReasoningEffortHigh
+	}
 }
 
 // UniversalMessage represents a single message in a conversation
 type UniversalMessage struct {
-	Role    string `json:"role"`    // "system", "user", "assistant"
-	Content string `json:"content"` // Text content
+	Role        string                 `json:"role"`                   // "system", "user", "assistant", "tool"
+	Content     string                 `json:"content"`                // Text content
+	Parts       []UniversalContentPart `json:"parts,omitempty"`        // Ordered content parts, only set when the message carries media
+	ToolCalls   []UniversalToolCall    `json:"tool_calls,omitempty"`   // Tool calls requested by an assistant message
+	ToolResults []UniversalToolResult  `json:"tool_results,omitempty"` // Tool results carried by a "tool" message
+}
+
+// Content part types
+const (
+	ContentPartText     = "text"
+	ContentPartImage    = "image"
+	ContentPartDocument = "document"
+	ContentPartAudio    = "audio"
+)
+
+// UniversalContentPart is one piece of multimodal message content. Media is
+// carried either inline as base64 Data with its MediaType, or as a remote URL.
+type UniversalContentPart struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	MediaType string `json:"media_type,omitempty"` // MIME type, e.g. "image/png"
+	Data      string `json:"data,omitempty"`       // Base64-encoded media
+	URL       string `json:"url,omitempty"`        // Remote media location when Data is empty
+	Filename  string `json:"filename,omitempty"`
+	Detail    string `json:"detail,omitempty"` // Image detail hint ("low", "high", "auto")
+}
+
+// SetContentParts sets the message content from ordered parts. Content always
+// holds the concatenated text; Parts is kept only when there is non-text media.
+func (m *UniversalMessage) SetContentParts(parts []UniversalContentPart) {
+	m.Content = ""
+	m.Parts = nil
+	hasMedia := false
+	for _, part := range parts {
+		if part.Type == ContentPartText {
+			m.Content += part.Text
+		} else {
+			hasMedia = true
+		}
+	}
+	if hasMedia {
+		m.Parts = parts
+	}
+}
+
+// ContentParts returns the message content as ordered parts
+func (m *UniversalMessage) ContentParts() []UniversalContentPart {
+	if len(m.Parts) > 0 {
+		return m.Parts
+	}
+	if m.Content == "" {
+		return nil
+	}
+	return []UniversalContentPart{{Type: ContentPartText, Text: m.Content}}
+}
+
+// HasMedia reports whether the message carries non-text content
+func (m *UniversalMessage) HasMedia() bool {
+	return len(m.Parts) > 0
+}
+
+// ParseDataURL splits a base64 data URL ("data:<media type>;base64,<data>")
+func ParseDataURL(url string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+	header, data, found := strings.Cut(rest, ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(header, ";base64"), data, true
+}
+
+// DataURL builds a base64 data URL from inline media
+func DataURL(mediaType, data string) string {
+	return "data:" + mediaType + ";base64," + data
+}
+
+// MediaPart builds an image, document or audio part from inline data or a URL.
+// Data URLs are unpacked into inline data.
+func MediaPart(partType, mediaType, data, url string) UniversalContentPart {
+	if dataMediaType, dataContent, ok := ParseDataURL(url); ok {
+		if dataMediaType != "" {
+			mediaType = dataMediaType
+		}
+		data, url = dataContent, ""
+	}
+	return UniversalContentPart{Type: partType, MediaType: mediaType, Data: data, URL: url}
+}
+
+// PartTypeForMediaType classifies a MIME type as an image, audio or document part
+func PartTypeForMediaType(mediaType string) string {
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return ContentPartImage
+	case strings.HasPrefix(mediaType, "audio/"):
+		return ContentPartAudio
+	default:
+		return ContentPartDocument
+	}
+}
+
+// GuessMediaType returns the part's MIME type, guessing it from the URL or
+// filename extension and falling back to a generic type for the part type
+func GuessMediaType(part UniversalContentPart) string {
+	if part.MediaType != "" {
+		return part.MediaType
+	}
+	for _, name := range []string{part.Filename, part.URL} {
+		if name == "" {
+			continue
+		}
+		name, _, _ = strings.Cut(name, "?")
+		if mediaType := mime.TypeByExtension(path.Ext(name)); mediaType != "" {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			return mediaType
+		}
+	}
+	switch part.Type {
+	case ContentPartImage:
+		return "image/jpeg"
+	case ContentPartAudio:
+		return "audio/wav"
+	case ContentPartDocument:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// AudioFormat returns the short audio format name ("wav", "mp3", ...) for a MIME type
+func AudioFormat(mediaType string) string {
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	default:
+		return strings.TrimPrefix(mediaType, "audio/")
+	}
+}
+
+// AudioMediaType returns the MIME type for a short audio format name
+func AudioMediaType(format string) string {
+	switch format {
+	case "mp3":
+		return "audio/mpeg"
+	case "":
+		return "audio/wav"
+	default:
+		return "audio/" + format
+	}
+}
+
+// TextDocumentData encodes plain text as inline document data
+func TextDocumentData(text string) string {
+	return base64.StdEncoding.EncodeToString([]byte(text))
+}
+
+// UniversalTool describes a function the model may call
+type UniversalTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // JSON schema of the arguments
+}
+
+// Tool choice modes
+const (
+	ToolChoiceAuto     = "auto"     // The model decides whether to call a tool
+	ToolChoiceNone     = "none"     // The model must not call tools
+	ToolChoiceRequired = "required" // The model must call at least one tool
+	ToolChoiceTool     = "tool"     // The model must call the named tool
+)
+
+// UniversalToolChoice controls whether and which tools the model calls
+type UniversalToolChoice struct {
+	Mode string `json:"mode"`
+	Name string `json:"name,omitempty"` // Tool name when Mode is ToolChoiceTool
+}
+
+// UniversalToolCall is a complete tool call made by the assistant
+type UniversalToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
+}
+
+// UniversalToolResult is the output of a tool call sent back to the model
+type UniversalToolResult struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name,omitempty"` // Name of the called tool, required by Gemini
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error,omitempty"`
+}
+
+// UniversalToolCallDelta is an incremental piece of a streamed tool call.
+// ID and Name are set on the first delta of a call, Arguments carries the next
+// fragment of the JSON-encoded arguments.
+type UniversalToolCallDelta struct {
+	Index     int    `json:"index"` // Position of the tool call within the response
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 // UniversalResponse represents a chat completion response in a format-agnostic way
 type UniversalResponse struct {
-	ID         string          `json:"id"`
-	Model      string          `json:"model"`
-	Content    string          `json:"content"`
-	Role       string          `json:"role"`
-	StopReason string          `json:"stop_reason,omitempty"`
-	Usage      *UniversalUsage `json:"usage,omitempty"`
+	ID         string              `json:"id"`
+	Model      string              `json:"model"`
+	Content    string              `json:"content"`
+	Role       string              `json:"role"`
+	ToolCalls  []UniversalToolCall `json:"tool_calls,omitempty"`
+	StopReason string              `json:"stop_reason,omitempty"`
+
+	Reasoning          string          `json:"reasoning,omitempty"`           // Thinking text or reasoning summary
+	ReasoningSignature string          `json:"reasoning_signature,omitempty"` // Anthropic thinking block signature
+	Usage              *UniversalUsage `json:"usage,omitempty"`
 }
 
 // UniversalUsage represents token usage statistics
@@ -38,13 +356,48 @@ type UniversalUsage struct {
 	TotalTokens  int `json:"total_tokens"`
 }
 
+// Merge folds usage reported later in a stream into u. Formats report usage in
+// parts (Anthropic sends input tokens at the start and output tokens at the end),
+// so only the counts that are set replace earlier ones.
+func (u *UniversalUsage) Merge(other *UniversalUsage) {
+	if other.InputTokens > 0 {
+		u.InputTokens = other.InputTokens
+	}
+	if other.OutputTokens > 0 {
+		u.OutputTokens = other.OutputTokens
+	}
+	if other.TotalTokens > 0 {
+		u.TotalTokens = other.TotalTokens
+	}
+	if u.TotalTokens < u.InputTokens+u.OutputTokens {
+		u.TotalTokens = u.InputTokens + u.OutputTokens
+	}
+}
+
 // UniversalStreamChunk represents a single streaming chunk in a format-agnostic way
 type UniversalStreamChunk struct {
-	ID         string  `json:"id,omitempty"`
-	Model      string  `json:"model,omitempty"`
-	Delta      string  `json:"delta"`          // The text delta
-	Role       string  `json:"role,omitempty"` // Role (usually only in first chunk)
-	StopReason *string `json:"stop_reason,omitempty"`
-	IsFirst    bool    `json:"-"` // Internal flag for first chunk
-	IsLast     bool    `json:"-"` // Internal flag for last chunk
+	ID         string                   `json:"id,omitempty"`
+	Model      string                   `json:"model,omitempty"`
+	Delta      string                   `json:"delta"`          // The text delta
+	Role       string                   `json:"role,omitempty"` // Role (usually only in first chunk)
+	ToolCalls  []UniversalToolCallDelta `json:"tool_calls,omitempty"`
+	StopReason *string                  `json:"stop_reason,omitempty"`
+
+	ReasoningDelta     string `json:"reasoning_delta,omitempty"`     // The thinking text delta
+	ReasoningSignature string `json:"reasoning_signature,omitempty"` // Anthropic thinking block signature
+	IsFirst            bool   `json:"-"`                             // Internal flag for first chunk
+	IsLast             bool   `json:"-"`                             // Internal flag for last chunk
+
+	// Usage is the token usage reported so far; the converter passes running totals to builders
+	Usage *UniversalUsage `json:"usage,omitempty"`
+}
+
+// ToolArgumentsObject returns JSON-encoded tool arguments as a raw JSON object,
+// falling back to an empty object when the arguments are empty or not an object
+func ToolArgumentsObject(arguments string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(arguments), &object); err != nil || object == nil {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
 }
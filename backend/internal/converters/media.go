@@ -0,0 +1,83 @@
+package converters
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+
+	"api-key-rotator/backend/internal/converters/formats"
+)
+
+// MediaFetcher downloads remote media and returns its MIME type and content
+type MediaFetcher func(rawURL string) (mediaType string, data []byte, err error)
+
+// SetMediaFetcher enables inlining remote media URLs that the target format
+// cannot reference. Without a fetcher, such media is replaced by a text
+// reference to its URL.
+func (c *Converter) SetMediaFetcher(fetch MediaFetcher) {
+	c.fetchMedia = fetch
+}
+
+// resolveRemoteMedia handles media URLs the target format cannot accept: with a
+// media fetcher, remote HTTP(S) URLs are inlined as base64 data; otherwise the
+// part is replaced by a text reference to the URL so the request still goes through
+func (c *Converter) resolveRemoteMedia(req *formats.UniversalRequest) error {
+	acceptor, ok := c.to.(formats.MediaURLAcceptor)
+	if !ok {
+		return nil
+	}
+	for i := range req.Messages {
+		msg := &req.Messages[i]
+		parts := msg.Parts
+		changed := false
+		for j := range parts {
+			part := &parts[j]
+			if part.Type == formats.ContentPartText || part.Data != "" || part.URL == "" || acceptor.AcceptsMediaURL(*part) {
+				continue
+			}
+
+			if c.fetchMedia != nil && isHTTPURL(part.URL) {
+				if err := c.inlineRemoteMedia(part); err != nil {
+					return err
+				}
+				continue
+			}
+			*part = formats.UniversalContentPart{
+				Type: formats.ContentPartText,
+				Text: fmt.Sprintf("\n[%s: %s]\n", part.Type, part.URL),
+			}
+			changed = true
+		}
+		if changed {
+			// Recompute the text content; the message may no longer carry media
+			msg.SetContentParts(parts)
+		}
+	}
+	return nil
+}
+
+// inlineRemoteMedia replaces a remote media URL with inline base64 data
+func (c *Converter) inlineRemoteMedia(part *formats.UniversalContentPart) error {
+	mediaType, data, err := c.fetchMedia(part.URL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s content: %w", part.Type, err)
+	}
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType = formats.GuessMediaType(*part)
+	}
+	if part.Filename == "" && part.Type == formats.ContentPartDocument {
+		if u, err := url.Parse(part.URL); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			part.Filename = path.Base(u.Path)
+		}
+	}
+	part.MediaType = mediaType
+	part.Data = base64.StdEncoding.EncodeToString(data)
+	part.URL = ""
+	return nil
+}
+
+func isHTTPURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
@@ -0,0 +1,122 @@
+package converters
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"api-key-rotator/backend/internal/converters/formats"
+)
+
+func TestConvertRequestRemoteMediaWithoutFetcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		body     string
+		want     []string // 转换后的请求体必须包含的内容
+		wantNot  []string
+	}{
+		{
+			name: "anthropic URL document to openai becomes a text reference",
+			from: "anthropic", to: "openai",
+			body: `{"model":"m","max_tokens":100,"messages":[{"role":"user","content":[
+				{"type":"text","text":"Summarize"},
+				{"type":"document","source":{"type":"url","url":"https://example.com/report.pdf"}}]}]}`,
+			want:    []string{`[document: https://example.com/report.pdf]`, `Summarize`},
+			wantNot: []string{`"file"`},
+		},
+		{
+			name: "gemini audio file to anthropic becomes a text reference",
+			from: "gemini", to: "anthropic",
+			body: `{"contents":[{"role":"user","parts":[
+				{"text":"Transcribe"},
+				{"fileData":{"mimeType":"audio/mp3","fileUri":"gs://bucket/a.mp3"}}]}]}`,
+			want: []string{`[audio: gs://bucket/a.mp3]`},
+		},
+		{
+			name: "URL image to openai is passed by URL",
+			from: "anthropic", to: "openai",
+			body: `{"model":"m","max_tokens":100,"messages":[{"role":"user","content":[
+				{"type":"image","source":{"type":"url","url":"https://example.com/cat.png"}}]}]}`,
+			want:    []string{`"image_url":{"url":"https://example.com/cat.png"}`},
+			wantNot: []string{`[image:`},
+		},
+		{
+			name: "URL PDF to anthropic is passed by URL",
+			from: "openai_responses", to: "anthropic",
+			body: `{"model":"m","input":[{"role":"user","content":[
+				{"type":"input_file","file_url":"https://example.com/a.pdf"}]}]}`,
+			want: []string{`"source":{"type":"url","url":"https://example.com/a.pdf"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter, err := NewConverter(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := converter.ConvertRequest([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("ConvertRequest() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(result), want) {
+					t.Errorf("converted request %s does not contain %s", result, want)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(string(result), unwanted) {
+					t.Errorf("converted request %s should not contain %s", result, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertRequestInlinesRemoteMedia(t *testing.T) {
+	converter, err := NewConverter("anthropic", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetched []string
+	converter.SetMediaFetcher(func(rawURL string) (string, []byte, error) {
+		fetched = append(fetched, rawURL)
+		return "application/pdf", []byte("%PDF"), nil
+	})
+
+	result, err := converter.ConvertRequest([]byte(`{"model":"m","max_tokens":100,"messages":[{"role":"user","content":[
+		{"type":"document","source":{"type":"url","url":"https://example.com/files/report.pdf"}},
+		{"type":"image","source":{"type":"url","url":"https://example.com/cat.png"}}]}]}`))
+	if err != nil {
+		t.Fatalf("ConvertRequest() error = %v", err)
+	}
+	if len(fetched) != 1 || fetched[0] != "https://example.com/files/report.pdf" {
+		t.Errorf("fetched %v, want only the document", fetched)
+	}
+	if !strings.Contains(string(result), `"file_data":"data:application/pdf;base64,JVBERg=="`) ||
+		!strings.Contains(string(result), `"filename":"report.pdf"`) {
+		t.Errorf("document was not inlined: %s", result)
+	}
+
+	converter, _ = NewConverter("anthropic", "openai")
+	converter.SetMediaFetcher(func(string) (string, []byte, error) { return "", nil, errors.New("too large") })
+	if _, err := converter.ConvertRequest([]byte(`{"model":"m","max_tokens":100,"messages":[{"role":"user","content":[
+		{"type":"document","source":{"type":"url","url":"https://example.com/a.pdf"}}]}]}`)); err == nil {
+		t.Error("ConvertRequest() should report fetch errors")
+	}
+}
+
+func TestConvertRequestUnsupportedContent(t *testing.T) {
+	converter, err := NewConverter("gemini", "anthropic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = converter.ConvertRequest([]byte(`{"contents":[{"role":"user","parts":[
+		{"inlineData":{"mimeType":"audio/wav","data":"UklGRg=="}}]}]}`))
+	if !errors.Is(err, formats.ErrUnsupportedContent) {
+		t.Fatalf("ConvertRequest() error = %v, want ErrUnsupportedContent", err)
+	}
+	if !strings.Contains(err.Error(), "audio") {
+		t.Errorf("error should name the unsupported part: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package converters
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertRequestReasoning(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		body     string
+		want     []string // 转换后的请求体必须包含的内容
+		wantNot  []string
+	}{
+		{
+			name: "budget shrinks to leave room for the answer under the client's max_tokens",
+			from: "openai", to: "anthropic",
+			body: `{"model":"m","max_tokens":3000,"reasoning_effort":"high","messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{`"max_tokens":3000`, `"thinking":{"type":"enabled","budget_tokens":1976}`},
+		},
+		{
+			name: "thinking is disabled when the minimum budget does not fit",
+			from: "openai", to: "anthropic",
+			body: `{"model":"m","max_tokens":2000,"reasoning_effort":"high","temperature":0.5,"messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{`"max_tokens":2000`, `"temperature":0.5`, `"thinking":{"type":"disabled"}`},
+		},
+		{
+			name: "budget below the client's max_tokens is kept",
+			from: "openai", to: "anthropic",
+			body: `{"model":"m","max_tokens":32000,"reasoning_effort":"low","messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{`"max_tokens":32000`, `"thinking":{"type":"enabled","budget_tokens":1024}`},
+		},
+		{
+			name: "default max_tokens is raised above the budget",
+			from: "openai", to: "anthropic",
+			body:    `{"model":"m","reasoning_effort":"medium","temperature":0.5,"messages":[{"role":"user","content":"hi"}]}`,
+			want:    []string{`"max_tokens":12288`, `"thinking":{"type":"enabled","budget_tokens":8192}`},
+			wantNot: []string{`"temperature"`},
+		},
+		{
+			name: "effort none disables thinking",
+			from: "openai", to: "anthropic",
+			body: `{"model":"m","max_tokens":100,"reasoning_effort":"none","messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{`"thinking":{"type":"disabled"}`},
+		},
+		{
+			name: "anthropic budget maps to an openai effort level",
+			from: "anthropic", to: "openai",
+			body: `{"model":"m","max_tokens":20000,"thinking":{"type":"enabled","budget_tokens":10000},"messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{`"reasoning_effort":"high"`},
+		},
+		{
+			name: "anthropic budget is passed to gemini",
+			from: "anthropic", to: "gemini",
+			body: `{"model":"m","max_tokens":4096,"thinking":{"type":"enabled","budget_tokens":2048},"messages":[{"role":"user","content":"hi"}]}`,
+			want: []string{`"thinkingBudget":2048`, `"includeThoughts":true`},
+		},
+		{
+			name: "gemini zero budget disables anthropic thinking",
+			from: "gemini", to: "anthropic",
+			body: `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"thinkingConfig":{"thinkingBudget":0}}}`,
+			want: []string{`"thinking":{"type":"disabled"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter, err := NewConverter(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := converter.ConvertRequest([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("ConvertRequest() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(result), want) {
+					t.Errorf("converted request %s does not contain %s", result, want)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(string(result), unwanted) {
+					t.Errorf("converted request %s should not contain %s", result, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertResponseReasoning(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		body     string
+		want     []string // 转换后的响应体必须包含的内容
+	}{
+		{
+			name: "anthropic thinking becomes openai reasoning_content",
+			from: "anthropic", to: "openai",
+			body: `{"id":"msg_1","type":"message","role":"assistant","model":"m","stop_reason":"end_turn",
+				"content":[{"type":"thinking","thinking":"Let me think","signature":"sig"},{"type":"text","text":"Answer"}],
+				"usage":{"input_tokens":1,"output_tokens":2}}`,
+			want: []string{`"reasoning_content":"Let me think"`, `"content":"Answer"`},
+		},
+		{
+			name: "openai reasoning_content becomes an anthropic thinking block",
+			from: "openai", to: "anthropic",
+			body: `{"id":"c1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop",
+				"message":{"role":"assistant","content":"Answer","reasoning_content":"Let me think"}}]}`,
+			want: []string{`{"type":"thinking","thinking":"Let me think"`, `{"type":"text","text":"Answer"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converter, err := NewConverter(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := converter.ConvertResponse([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("ConvertResponse() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(result), want) {
+					t.Errorf("converted response %s does not contain %s", result, want)
+				}
+			}
+		})
+	}
+}
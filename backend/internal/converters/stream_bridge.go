@@ -0,0 +1,171 @@
+package converters
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"api-key-rotator/backend/internal/converters/formats"
+
+	"github.com/google/uuid"
+)
+
+// Stream bridging serves clients whose stream mode differs from the upstream's:
+// streamed chunks are aggregated into a single response, or a whole response is
+// replayed as a stream. Both directions go through the format handlers.
+
+// SetUpstreamStream sets the stream mode of the converted request regardless
+// of what the client asked for; call it before ConvertRequest
+func (c *Converter) SetUpstreamStream(stream bool) {
+	c.upstreamStream = &stream
+}
+
+// ClientStream reports whether the client asked for a stream; valid after ConvertRequest
+func (c *Converter) ClientStream() bool {
+	return c.clientStream
+}
+
+// UpstreamStream reports whether the converted request streams; valid after ConvertRequest
+func (c *Converter) UpstreamStream() bool {
+	return c.stream
+}
+
+// setRequestStream sets the stream mode of a request body in its own format.
+// Used when only the stream mode changes, so fields the universal request does
+// not model are kept. Gemini streams by path and keeps its body.
+func setRequestStream(format string, body []byte, stream bool) []byte {
+	if format == "gemini" {
+		return body
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return body
+	}
+	if stream {
+		fields["stream"] = json.RawMessage("true")
+	} else {
+		// stream_options is rejected on non-stream requests
+		delete(fields, "stream")
+		delete(fields, "stream_options")
+	}
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return result
+}
+
+// AggregateStreamChunk adds a source-format stream chunk to the response built
+// by AggregatedResponse
+func (c *Converter) AggregateStreamChunk(chunk []byte) error {
+	universal, err := c.fromStream.ParseStreamChunk(chunk)
+	if err != nil {
+		return fmt.Errorf("parse stream chunk error: %w", err)
+	}
+
+	resp := &c.aggregate
+	if resp.ID == "" {
+		resp.ID = universal.ID
+	}
+	if resp.Model == "" {
+		resp.Model = universal.Model
+	}
+	resp.Content += universal.Delta
+	resp.Reasoning += universal.ReasoningDelta
+	resp.ReasoningSignature += universal.ReasoningSignature
+
+	for _, call := range universal.ToolCalls {
+		index, ok := c.toolIndexes[call.Index]
+		if !ok || call.ID != "" {
+			// A new ID starts a new call even if the source reuses the position (Gemini chunks)
+			index = len(resp.ToolCalls)
+			c.toolIndexes[call.Index] = index
+			resp.ToolCalls = append(resp.ToolCalls, formats.UniversalToolCall{ID: call.ID})
+		}
+		if call.Name != "" {
+			resp.ToolCalls[index].Name = call.Name
+		}
+		resp.ToolCalls[index].Arguments += call.Arguments
+	}
+
+	if universal.StopReason != nil {
+		resp.StopReason = *universal.StopReason
+	}
+	c.trackUsage(universal)
+	if universal.Usage != nil {
+		usage := *universal.Usage
+		resp.Usage = &usage
+	}
+	return nil
+}
+
+// AggregatedResponse builds the target-format response from the aggregated stream chunks
+func (c *Converter) AggregatedResponse() ([]byte, error) {
+	resp := c.aggregate
+	resp.Role = "assistant"
+	resp.ToolCalls = append([]formats.UniversalToolCall(nil), resp.ToolCalls...)
+	for i := range resp.ToolCalls {
+		if resp.ToolCalls[i].ID == "" {
+			resp.ToolCalls[i].ID = "call_" + uuid.New().String()[:8]
+		}
+	}
+	if resp.StopReason == "" {
+		resp.StopReason = "stop"
+	}
+	unwrapStructuredOutput(&resp)
+
+	result, err := c.to.BuildResponse(&resp)
+	if err != nil {
+		return nil, fmt.Errorf("build response error: %w", err)
+	}
+	return result, nil
+}
+
+// SynthesizeStream replays a whole source-format response as target-format
+// stream events, end events included
+func (c *Converter) SynthesizeStream(body []byte) ([][]byte, error) {
+	resp, err := c.from.ParseResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("parse response error: %w", err)
+	}
+	unwrapStructuredOutput(resp)
+
+	// Usage goes on the first chunk too, for formats that report input tokens at the start
+	chunks := []*formats.UniversalStreamChunk{{Role: "assistant", IsFirst: true, Usage: resp.Usage}}
+	if resp.Reasoning != "" || resp.ReasoningSignature != "" {
+		chunks = append(chunks, &formats.UniversalStreamChunk{
+			ReasoningDelta:     resp.Reasoning,
+			ReasoningSignature: resp.ReasoningSignature,
+		})
+	}
+	if resp.Content != "" {
+		chunks = append(chunks, &formats.UniversalStreamChunk{Delta: resp.Content})
+	}
+	for i, call := range resp.ToolCalls {
+		chunks = append(chunks, &formats.UniversalStreamChunk{
+			ToolCalls: []formats.UniversalToolCallDelta{{
+				Index:     i,
+				ID:        call.ID,
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			}},
+		})
+	}
+	stopReason := resp.StopReason
+	if stopReason == "" {
+		stopReason = "stop"
+	}
+	chunks = append(chunks, &formats.UniversalStreamChunk{StopReason: &stopReason, IsLast: true, Usage: resp.Usage})
+
+	var events [][]byte
+	for _, chunk := range chunks {
+		chunk.ID, chunk.Model = resp.ID, resp.Model
+		chunk.ToolCalls = c.trackToolCalls(chunk)
+		c.trackUsage(chunk)
+		built, err := c.toStream.BuildStreamChunk(chunk)
+		if err != nil {
+			return nil, fmt.Errorf("build stream chunk error: %w", err)
+		}
+		events = append(events, built...)
+	}
+	return append(events, c.GetStreamEndEvents()...), nil
+}
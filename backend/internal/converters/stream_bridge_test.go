@@ -0,0 +1,95 @@
+package converters
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertRequestUpstreamStream(t *testing.T) {
+	tests := []struct {
+		format         string
+		action         string
+		body           string
+		upstreamStream bool
+		want           string
+		wantPath       string
+	}{
+		// 格式相同时只改流式字段，保留未知字段
+		{"openai", "chat/completions", `{"model":"m","custom":1}`, true, `{"custom":1,"model":"m","stream":true}`, "chat/completions"},
+		{"openai", "chat/completions", `{"model":"m","stream":true,"stream_options":{"include_usage":true}}`, false, `{"model":"m"}`, "chat/completions"},
+		// Gemini 的流式由路径决定，请求体不变
+		{"gemini", "v1beta/models/g:generateContent", `{"contents":[]}`, true, `{"contents":[]}`, "v1beta/models/g:streamGenerateContent?alt=sse"},
+		{"gemini", "v1beta/models/g:streamGenerateContent", `{"contents":[]}`, false, `{"contents":[]}`, "v1beta/models/g:generateContent"},
+	}
+	for _, tt := range tests {
+		converter, err := NewConverter(tt.format, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converter.SetStream(IsStreamAction(tt.action))
+		converter.SetUpstreamStream(tt.upstreamStream)
+		result, err := converter.ConvertRequest([]byte(tt.body))
+		if err != nil || string(result) != tt.want {
+			t.Errorf("ConvertRequest(%s) = %s, %v, want %s", tt.body, result, err, tt.want)
+		}
+		if path := converter.GetTargetPath(tt.action); path != tt.wantPath {
+			t.Errorf("GetTargetPath(%q) = %q, want %q", tt.action, path, tt.wantPath)
+		}
+	}
+}
+
+func TestAggregateStreamChunk(t *testing.T) {
+	converter, err := NewConverter("anthropic", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[]}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Hmm"}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Check"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"ing"}}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+	} {
+		if err := converter.AggregateStreamChunk([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	convertAndCheck(t, "anthropic", "openai", func(*Converter) ([]byte, error) { return converter.AggregatedResponse() }, []string{
+		`"id":"msg_1"`, `"content":"Checking"`, `"reasoning_content":"Hmm"`,
+		`{"id":"toolu_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}`,
+		`"finish_reason":"tool_calls"`,
+	})
+}
+
+func TestSynthesizeStream(t *testing.T) {
+	tests := []struct {
+		from, to string
+		body     string
+		want     []string
+	}{
+		{"openai", "anthropic",
+			`{"id":"c1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"tool_calls",
+				"message":{"role":"assistant","content":"Checking","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]}}]}`,
+			[]string{`"type":"message_start"`, `"text":"Checking"`, `"type":"tool_use","id":"call_1"`, `"stop_reason":"tool_use"`, `"type":"message_stop"`}},
+		{"anthropic", "openai",
+			`{"id":"msg_1","type":"message","role":"assistant","model":"m","stop_reason":"end_turn",
+				"content":[{"type":"thinking","thinking":"Hmm","signature":"sig"},{"type":"text","text":"Answer"}]}`,
+			[]string{`"role":"assistant"`, `"reasoning_content":"Hmm"`, `"content":"Answer"`, `"finish_reason":"stop"`}},
+	}
+	for _, tt := range tests {
+		body := []byte(tt.body)
+		convertAndCheck(t, tt.from, tt.to, func(c *Converter) ([]byte, error) {
+			events, err := c.SynthesizeStream(body)
+			var all []string
+			for _, event := range events {
+				all = append(all, string(event))
+			}
+			return []byte(strings.Join(all, "\n")), err
+		}, tt.want)
+	}
+}
@@ -0,0 +1,61 @@
+package converters
+
+import (
+	"api-key-rotator/backend/internal/converters/formats"
+)
+
+// Targets without a JSON output mode emulate response formats with a call to
+// formats.StructuredOutputToolName. The calls are turned back into text content
+// here so the client receives a regular JSON response.
+
+// unwrapStructuredOutput moves structured output tool calls into the response content
+func unwrapStructuredOutput(resp *formats.UniversalResponse) {
+	calls := resp.ToolCalls[:0]
+	unwrapped := false
+	for _, call := range resp.ToolCalls {
+		if call.Name == formats.StructuredOutputToolName {
+			resp.Content += call.Arguments
+			unwrapped = true
+			continue
+		}
+		calls = append(calls, call)
+	}
+	if !unwrapped {
+		return
+	}
+	if len(calls) == 0 {
+		resp.ToolCalls = nil
+		if resp.StopReason == "tool_calls" {
+			resp.StopReason = "stop"
+		}
+		return
+	}
+	resp.ToolCalls = calls
+}
+
+// unwrapStructuredOutputDeltas turns streamed structured output tool call
+// arguments into text deltas
+func (c *Converter) unwrapStructuredOutputDeltas(chunk *formats.UniversalStreamChunk) {
+	calls := chunk.ToolCalls[:0]
+	for _, call := range chunk.ToolCalls {
+		if call.Name == formats.StructuredOutputToolName {
+			c.structuredIndexes[call.Index] = true
+		} else if call.ID != "" || call.Name != "" {
+			// A new call at the same source position
+			delete(c.structuredIndexes, call.Index)
+		}
+		if c.structuredIndexes[call.Index] {
+			chunk.Delta += call.Arguments
+			c.structuredOutput = true
+			continue
+		}
+		c.otherToolCalls = true
+		calls = append(calls, call)
+	}
+	chunk.ToolCalls = calls
+
+	if chunk.StopReason != nil && *chunk.StopReason == "tool_calls" && c.structuredOutput && !c.otherToolCalls {
+		reason := "stop"
+		chunk.StopReason = &reason
+	}
+}
@@ -0,0 +1,70 @@
+package converters
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertRequestStructuredOutput(t *testing.T) {
+	schema := `"response_format":{"type":"json_schema","json_schema":{"name":"answer","strict":true,
+		"schema":{"type":"object","properties":{"value":{"type":["string","null"]}},"additionalProperties":false}}}`
+
+	tests := []struct {
+		to   string
+		body string
+		want []string
+	}{
+		{"anthropic", `{"model":"m","max_tokens":100,"messages":[{"role":"user","content":"hi"}],` + schema + `}`,
+			[]string{`"name":"structured_output"`, `"tool_choice":{"type":"tool","name":"structured_output"}`}},
+		// 有其他工具时只要求调用任意工具，模型仍可先使用其他工具
+		{"anthropic", `{"model":"m","max_tokens":100,"messages":[{"role":"user","content":"hi"}],
+			"tools":[{"type":"function","function":{"name":"search"}}],"response_format":{"type":"json_object"}}`,
+			[]string{`"name":"search"`, `"name":"structured_output"`, `"tool_choice":{"type":"any"}`}},
+		// 开启思考时不能强制调用工具
+		{"anthropic", `{"model":"m","max_tokens":4000,"reasoning_effort":"low","messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_object"}}`,
+			[]string{`"name":"structured_output"`, `"tool_choice":{"type":"auto"}`}},
+		{"gemini", `{"model":"m","messages":[{"role":"user","content":"hi"}],` + schema + `}`,
+			[]string{`"responseMimeType":"application/json"`, `"responseSchema":{`, `"nullable":true`, `"type":"STRING"`}},
+		{"openai_responses", `{"model":"m","messages":[{"role":"user","content":"hi"}],` + schema + `}`,
+			[]string{`"text":{"format":{"type":"json_schema","name":"answer"`, `"strict":true`}},
+	}
+	for _, tt := range tests {
+		body := []byte(tt.body)
+		convertAndCheck(t, "openai", tt.to, func(c *Converter) ([]byte, error) { return c.ConvertRequest(body) }, tt.want)
+	}
+
+	// Gemini 不支持 additionalProperties，OpenAI 要求 schema 名称
+	convertAndCheck(t, "gemini", "openai", func(c *Converter) ([]byte, error) {
+		return c.ConvertRequest([]byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}],
+			"generationConfig":{"responseMimeType":"application/json","responseSchema":{"type":"OBJECT","properties":{"value":{"type":"STRING"}}}}}`))
+	}, []string{`"response_format":{"type":"json_schema","json_schema":{"name":"response"`, `"type":"string"`})
+}
+
+func TestConvertResponseStructuredOutput(t *testing.T) {
+	messageStart := `{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","model":"m","content":[]}}`
+	converter, err := NewConverter("anthropic", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed, err := streamEvents(converter, messageStart,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"structured_output","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"value\":\"x\"}"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := converter.ConvertResponse([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"m","stop_reason":"tool_use",
+		"content":[{"type":"tool_use","id":"toolu_1","name":"structured_output","input":{"value":"x"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 结构化输出的工具调用还原为文本内容，结束原因为 stop
+	for _, result := range []string{string(streamed), string(response)} {
+		if !strings.Contains(result, `"content":"{\"value\":\"x\"}"`) || !strings.Contains(result, `"finish_reason":"stop"`) ||
+			strings.Contains(result, "tool_calls") {
+			t.Errorf("structured output was not turned into text content: %s", result)
+		}
+	}
+}
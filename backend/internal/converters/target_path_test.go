@@ -0,0 +1,30 @@
+package converters
+
+import "testing"
+
+func TestGetTargetPath(t *testing.T) {
+	tests := []struct {
+		from, to string
+		action   string
+		body     string
+		want     string
+	}{
+		{"openai", "gemini", "v1/chat/completions", `{"model":"m","stream":true}`, "v1beta/models/{model}:streamGenerateContent?alt=sse"},
+		{"openai", "gemini", "chat/completions", `{"model":"m"}`, "v1beta/models/{model}:generateContent"},
+		{"gemini", "gemini", "v1beta/models/g:streamGenerateContent", `{}`, "v1beta/models/g:streamGenerateContent"},
+		{"gemini", "anthropic", "v1beta/models/g:streamGenerateContent", `{}`, "v1/messages"},
+	}
+	for _, tt := range tests {
+		converter, err := NewConverter(tt.from, tt.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converter.SetStream(IsStreamAction(tt.action))
+		if _, err := converter.ConvertRequest([]byte(tt.body)); err != nil {
+			t.Fatal(err)
+		}
+		if got := converter.GetTargetPath(tt.action); got != tt.want {
+			t.Errorf("%s -> %s GetTargetPath(%q) = %q, want %q", tt.from, tt.to, tt.action, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package converters
+
+import (
+	"strings"
+	"testing"
+)
+
+// convertAndCheck 转换请求或响应，并检查结果按顺序包含 want 中的每一项
+func convertAndCheck(t *testing.T, from, to string, convert func(*Converter) ([]byte, error), want []string) {
+	t.Helper()
+	converter, err := NewConverter(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := convert(converter)
+	if err != nil {
+		t.Fatalf("%s -> %s: %v", from, to, err)
+	}
+	rest := string(result)
+	for _, w := range want {
+		i := strings.Index(rest, w)
+		if i < 0 {
+			t.Errorf("%s -> %s: %s does not contain %s in order", from, to, result, w)
+			return
+		}
+		rest = rest[i+len(w):]
+	}
+}
+
+// streamEvents 逐个转换流式数据块并拼接所有输出事件，包括结束事件
+func streamEvents(converter *Converter, chunks ...string) ([]byte, error) {
+	var events []string
+	for _, chunk := range chunks {
+		converted, err := converter.ConvertStreamChunk([]byte(chunk))
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range converted {
+			events = append(events, string(event))
+		}
+	}
+	for _, event := range converter.GetStreamEndEvents() {
+		events = append(events, string(event))
+	}
+	return []byte(strings.Join(events, "\n")), nil
+}
+
+func TestConvertRequestTools(t *testing.T) {
+	body := []byte(`{"model":"m","max_tokens":100,
+		"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}],
+		"tool_choice":{"type":"function","function":{"name":"get_weather"}},
+		"messages":[
+			{"role":"user","content":"Weather in Paris?"},
+			{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+			{"role":"tool","tool_call_id":"call_1","content":"sunny"}]}`)
+
+	tests := []struct {
+		to   string
+		want []string
+	}{
+		{"anthropic", []string{
+			`{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}}`,
+			`{"type":"tool_result","tool_use_id":"call_1","content":"sunny"}`,
+			`"tool_choice":{"type":"tool","name":"get_weather"}`,
+		}},
+		{"gemini", []string{
+			`"functionCall":{"name":"get_weather","args":{"city":"Paris"}}`,
+			`"functionResponse":{"name":"get_weather"`,
+			`"allowedFunctionNames":["get_weather"]`,
+		}},
+		{"openai_responses", []string{
+			`"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"`,
+			`"type":"function_call_output","call_id":"call_1","output":"sunny"`,
+		}},
+	}
+	for _, tt := range tests {
+		convertAndCheck(t, "openai", tt.to, func(c *Converter) ([]byte, error) { return c.ConvertRequest(body) }, tt.want)
+	}
+}
+
+func TestConvertResponseTools(t *testing.T) {
+	tests := []struct {
+		from, to string
+		body     string
+		want     []string
+	}{
+		{"anthropic", "openai",
+			`{"id":"msg_1","type":"message","role":"assistant","model":"m","stop_reason":"tool_use",
+				"content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}]}`,
+			[]string{`"tool_calls":[{"id":"toolu_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]`, `"finish_reason":"tool_calls"`}},
+		{"gemini", "openai",
+			`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}]}`,
+			[]string{`"function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}`, `"finish_reason":"tool_calls"`}},
+		{"openai_responses", "anthropic",
+			`{"id":"resp_1","object":"response","model":"m","status":"completed",
+				"output":[{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}]}`,
+			[]string{`{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}}`, `"stop_reason":"tool_use"`}},
+	}
+	for _, tt := range tests {
+		body := []byte(tt.body)
+		convertAndCheck(t, tt.from, tt.to, func(c *Converter) ([]byte, error) { return c.ConvertResponse(body) }, tt.want)
+	}
+}
+
+func TestConvertStreamChunkTools(t *testing.T) {
+	openAIChunks := []string{
+		`{"id":"c","model":"m","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		`{"id":"c","model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`{"id":"c","model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+
+	tests := []struct {
+		from, to string
+		chunks   []string
+		want     []string
+	}{
+		{"openai", "anthropic", openAIChunks, []string{
+			`"content_block":{"type":"tool_use","id":"call_1","name":"get_weather","input":{}}`,
+			`"partial_json":"{\"city\":"`, `"partial_json":"\"Paris\"}"`, `"stop_reason":"tool_use"`,
+		}},
+		// Gemini 只能发送完整的 functionCall，参数片段缓存到最后一起发送
+		{"openai", "gemini", openAIChunks, []string{`"functionCall":{"name":"get_weather","args":{"city":"Paris"}}`}},
+		// Gemini 的每个 functionCall 都是新的调用，即使位置相同
+		{"gemini", "openai", []string{
+			`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"a","args":{}}}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"b","args":{}}}]},"finishReason":"STOP"}]}`,
+		}, []string{`"index":0,`, `"name":"a"`, `"index":1,`, `"name":"b"`, `"finish_reason":"tool_calls"`}},
+	}
+	for _, tt := range tests {
+		convertAndCheck(t, tt.from, tt.to, func(c *Converter) ([]byte, error) { return streamEvents(c, tt.chunks...) }, tt.want)
+	}
+}
@@ -0,0 +1,157 @@
+package converters
+
+import (
+	"strings"
+	"testing"
+
+	"api-key-rotator/backend/internal/converters/formats"
+)
+
+func TestTrackStreamUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		chunks []string
+		want   *formats.UniversalUsage
+	}{
+		{
+			name:   "openai final usage chunk",
+			format: "openai_compatible",
+			chunks: []string{
+				`{"id":"c","object":"chat.completion.chunk","model":"m","choices":[{"index":0,"delta":{"content":"hi"}}]}`,
+				`{"id":"c","object":"chat.completion.chunk","model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}`,
+			},
+			want: &formats.UniversalUsage{InputTokens: 3, OutputTokens: 5, TotalTokens: 8},
+		},
+		{
+			name:   "anthropic message_start and message_delta",
+			format: "anthropic_native",
+			chunks: []string{
+				`{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","model":"m","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			},
+			want: &formats.UniversalUsage{InputTokens: 12, OutputTokens: 7, TotalTokens: 19},
+		},
+		{
+			name:   "gemini cumulative usageMetadata",
+			format: "gemini_native",
+			chunks: []string{
+				`{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]}}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":1,"totalTokenCount":5}}`,
+				`{"candidates":[{"content":{"role":"model","parts":[{"text":"!"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":6,"totalTokenCount":10}}`,
+			},
+			want: &formats.UniversalUsage{InputTokens: 4, OutputTokens: 6, TotalTokens: 10},
+		},
+		{
+			name:   "stream without usage",
+			format: "openai_compatible",
+			chunks: []string{`{"id":"c","choices":[{"index":0,"delta":{"content":"hi"}}]}`, `not json`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := NewConverter(tt.format, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, chunk := range tt.chunks {
+				tracker.TrackStreamUsage([]byte(chunk))
+			}
+			got := tracker.StreamUsage()
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("StreamUsage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertStreamChunkUsage(t *testing.T) {
+	converter, err := NewConverter("openai", "anthropic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, chunk := range []string{
+		`{"id":"c","model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"hi"}}]}`,
+		`{"id":"c","model":"m","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`{"id":"c","model":"m","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}`,
+	} {
+		converted, err := converter.ConvertStreamChunk([]byte(chunk))
+		if err != nil {
+			t.Fatalf("ConvertStreamChunk() error = %v", err)
+		}
+		for _, event := range converted {
+			events = append(events, string(event))
+		}
+	}
+	for _, event := range converter.GetStreamEndEvents() {
+		events = append(events, string(event))
+	}
+
+	joined := strings.Join(events, "\n")
+	if !strings.Contains(joined, `"usage":{"input_tokens":3,"output_tokens":5}`) {
+		t.Errorf("converted stream does not report usage in message_delta:\n%s", joined)
+	}
+	if usage := converter.StreamUsage(); usage == nil || *usage != (formats.UniversalUsage{InputTokens: 3, OutputTokens: 5, TotalTokens: 8}) {
+		t.Errorf("StreamUsage() = %+v", usage)
+	}
+}
+
+func TestAggregateStreamChunkUsage(t *testing.T) {
+	converter, err := NewConverter("anthropic", "openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []string{
+		`{"type":"message_start","message":{"id":"msg","type":"message","role":"assistant","model":"m","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+	} {
+		if err := converter.AggregateStreamChunk([]byte(chunk)); err != nil {
+			t.Fatalf("AggregateStreamChunk() error = %v", err)
+		}
+	}
+	body, err := converter.AggregatedResponse()
+	if err != nil {
+		t.Fatalf("AggregatedResponse() error = %v", err)
+	}
+	if !strings.Contains(string(body), `"prompt_tokens":12,"completion_tokens":7,"total_tokens":19`) {
+		t.Errorf("aggregated response %s does not carry the usage", body)
+	}
+	if usage := converter.StreamUsage(); usage == nil || usage.TotalTokens != 19 {
+		t.Errorf("StreamUsage() = %+v, want 19 total tokens", usage)
+	}
+}
+
+func TestResponseUsage(t *testing.T) {
+	tests := []struct {
+		format string
+		body   string
+		want   *formats.UniversalUsage
+	}{
+		{
+			format: "openai_compatible",
+			body:   `{"id":"c","choices":[{"index":0,"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}`,
+			want:   &formats.UniversalUsage{InputTokens: 3, OutputTokens: 5, TotalTokens: 8},
+		},
+		{
+			format: "anthropic_native",
+			body:   `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":2,"output_tokens":4}}`,
+			want:   &formats.UniversalUsage{InputTokens: 2, OutputTokens: 4, TotalTokens: 6},
+		},
+		{
+			format: "openai_compatible",
+			body:   `not json`,
+		},
+	}
+	for _, tt := range tests {
+		tracker, err := NewConverter(tt.format, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tracker.ResponseUsage([]byte(tt.body))
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("ResponseUsage(%s) = %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+}
@@ -21,17 +21,39 @@ type AppConfigResponse struct {
 
 // ProxyConfigCreate 创建或更新代理配置的统一请求
 type ProxyConfigCreate struct {
-	Name           string  `json:"name" binding:"required"`
-	Slug           string  `json:"slug" binding:"required"`
-	ConfigType     string  `json:"config_type" binding:"required"` // "generic" or "llm"
-	APIKeyLocation *string `json:"api_key_location,omitempty"`
-	APIKeyName     *string `json:"api_key_name,omitempty"`
-	IsActive       bool    `json:"is_active"`
-	Method         *string `json:"method,omitempty"`
-	TargetURL      *string `json:"target_url,omitempty"`
-	TargetBaseURL  *string `json:"target_base_url,omitempty"`
-	APIFormat      *string `json:"api_format,omitempty"`
-	OutputFormat   *string `json:"output_format,omitempty"`
+	Name                  string                        `json:"name" binding:"required"`
+	Slug                  string                        `json:"slug" binding:"required"`
+	ConfigType            string                        `json:"config_type" binding:"required"` // "generic" or "llm"
+	APIKeyLocation        *string                       `json:"api_key_location,omitempty"`
+	APIKeyName            *string                       `json:"api_key_name,omitempty"`
+	APIKeyTemplate        *string                       `json:"api_key_template,omitempty"`
+	IsActive              bool                          `json:"is_active"`
+	Method                *string                       `json:"method,omitempty"`
+	MaxBodySizeMB         *int                          `json:"max_body_size_mb,omitempty"`
+	ConnectTimeout        *int                          `json:"connect_timeout,omitempty"`
+	TLSHandshakeTimeout   *int                          `json:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout *int                          `json:"response_header_timeout,omitempty"`
+	TotalTimeout          *int                          `json:"total_timeout,omitempty"`
+	StreamIdleTimeout     *int                          `json:"stream_idle_timeout,omitempty"`
+	EgressProxyURL        *string                       `json:"egress_proxy_url,omitempty"`
+	TLS                   *models.TLSSettings           `json:"tls,omitempty"`
+	RouteRules            []models.RouteRule            `json:"route_rules,omitempty"`
+	ResponseCache         *models.ResponseCacheSettings `json:"response_cache,omitempty"`
+	RewriteRules          []models.RewriteRule          `json:"rewrite_rules,omitempty"`
+	TargetURL             *string                       `json:"target_url,omitempty"`
+	TargetBaseURL         *string                       `json:"target_base_url,omitempty"`
+	APIFormat             *string                       `json:"api_format,omitempty"`
+	OutputFormat          *string                       `json:"output_format,omitempty"`
+	ModelAliases          []models.ModelAlias           `json:"model_aliases,omitempty"`
+	RejectUnknownModels   bool                          `json:"reject_unknown_models"`
+	InlineRemoteMedia     bool                          `json:"inline_remote_media"`
+	StreamMode            *string                       `json:"stream_mode,omitempty"`
+	IncludeStreamUsage    bool                          `json:"include_stream_usage"`
+	FallbackSlugs         []string                      `json:"fallback_slugs,omitempty"`
+	OAuthTokenURL         *string                       `json:"oauth_token_url,omitempty"`
+	OAuthScope            *string                       `json:"oauth_scope,omitempty"`
+	IPAllowlist           []string                      `json:"ip_allowlist,omitempty"`
+	IPDenylist            []string                      `json:"ip_denylist,omitempty"`
 }
 
 // ProxyConfigStatusUpdate 更新代理配置状态的请求
@@ -40,8 +62,21 @@ type ProxyConfigStatusUpdate struct {
 }
 
 // APIKeyCreate 创建API密钥请求
+// 普通密钥必须提供 key_value；结构化凭证的 key_value 可省略，默认使用凭证中的标识
 type APIKeyCreate struct {
-	KeyValue string `json:"key_value" binding:"required"`
+	KeyValue       string                 `json:"key_value"`
+	Label          string                 `json:"label,omitempty"`
+	EgressProxyURL *string                `json:"egress_proxy_url,omitempty"`
+	IsActive       bool                   `json:"is_active"`
+	KeyType        string                 `json:"key_type,omitempty"`
+	Credentials    *models.KeyCredentials `json:"credentials,omitempty"`
+}
+
+// ModelRouteCreate 创建或更新模型路由请求
+type ModelRouteCreate struct {
+	Pattern  string `json:"pattern" binding:"required"`
+	Slug     string `json:"slug" binding:"required"`
+	Priority int    `json:"priority"`
 	IsActive bool   `json:"is_active"`
 }
 
@@ -52,35 +87,140 @@ type APIKeyStatusUpdate struct {
 
 // ProxyConfigResponse 代理配置的统一响应
 type ProxyConfigResponse struct {
-	ID             int32           `json:"id"`
-	Name           string          `json:"name"`
-	Slug           string          `json:"slug"`
-	ConfigType     string          `json:"config_type"`
-	APIKeyLocation *string         `json:"api_key_location,omitempty"`
-	APIKeyName     *string         `json:"api_key_name,omitempty"`
-	IsActive       bool            `json:"is_active"`
-	APIKeys        []models.APIKey `json:"api_keys"`
-	Method         *string         `json:"method,omitempty"`
-	TargetURL      *string         `json:"target_url,omitempty"`
-	TargetBaseURL  *string         `json:"target_base_url,omitempty"`
-	APIFormat      *string         `json:"api_format,omitempty"`
-	OutputFormat   *string         `json:"output_format,omitempty"`
+	ID                    int32                         `json:"id"`
+	Name                  string                        `json:"name"`
+	Slug                  string                        `json:"slug"`
+	ConfigType            string                        `json:"config_type"`
+	APIKeyLocation        *string                       `json:"api_key_location,omitempty"`
+	APIKeyName            *string                       `json:"api_key_name,omitempty"`
+	APIKeyTemplate        *string                       `json:"api_key_template,omitempty"`
+	IsActive              bool                          `json:"is_active"`
+	APIKeys               []APIKeyResponse              `json:"api_keys"`
+	Method                *string                       `json:"method,omitempty"`
+	MaxBodySizeMB         *int                          `json:"max_body_size_mb,omitempty"`
+	ConnectTimeout        *int                          `json:"connect_timeout,omitempty"`
+	TLSHandshakeTimeout   *int                          `json:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout *int                          `json:"response_header_timeout,omitempty"`
+	TotalTimeout          *int                          `json:"total_timeout,omitempty"`
+	StreamIdleTimeout     *int                          `json:"stream_idle_timeout,omitempty"`
+	EgressProxyURL        *string                       `json:"egress_proxy_url,omitempty"`
+	TLS                   *TLSSettingsResponse          `json:"tls,omitempty"`
+	RouteRules            []models.RouteRule            `json:"route_rules,omitempty"`
+	ResponseCache         *models.ResponseCacheSettings `json:"response_cache,omitempty"`
+	RewriteRules          []models.RewriteRule          `json:"rewrite_rules,omitempty"`
+	TargetURL             *string                       `json:"target_url,omitempty"`
+	TargetBaseURL         *string                       `json:"target_base_url,omitempty"`
+	APIFormat             *string                       `json:"api_format,omitempty"`
+	OutputFormat          *string                       `json:"output_format,omitempty"`
+	ModelAliases          []models.ModelAlias           `json:"model_aliases,omitempty"`
+	RejectUnknownModels   bool                          `json:"reject_unknown_models"`
+	InlineRemoteMedia     bool                          `json:"inline_remote_media"`
+	StreamMode            *string                       `json:"stream_mode,omitempty"`
+	IncludeStreamUsage    bool                          `json:"include_stream_usage"`
+	FallbackSlugs         []string                      `json:"fallback_slugs,omitempty"`
+	OAuthTokenURL         *string                       `json:"oauth_token_url,omitempty"`
+	OAuthScope            *string                       `json:"oauth_scope,omitempty"`
+	IPAllowlist           []string                      `json:"ip_allowlist,omitempty"`
+	IPDenylist            []string                      `json:"ip_denylist,omitempty"`
+}
+
+// TLSSettingsResponse TLS设置响应，不返回客户端私钥
+type TLSSettingsResponse struct {
+	CABundle           string `json:"ca_bundle,omitempty"`
+	ClientCert         string `json:"client_cert,omitempty"`
+	HasClientKey       bool   `json:"has_client_key"`
+	MinVersion         string `json:"min_version,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// MaskedSecret 响应中代替已保存密钥字段的掩码
+const MaskedSecret = "********"
+
+// APIKeyResponse API密钥响应，结构化凭证中的密钥字段只返回掩码
+type APIKeyResponse struct {
+	models.APIKey
+	Credentials *models.KeyCredentials `json:"credentials,omitempty"`
+}
+
+// ToAPIKeyResponse 将API密钥转换为响应DTO
+func ToAPIKeyResponse(apiKey *models.APIKey) APIKeyResponse {
+	resp := APIKeyResponse{APIKey: *apiKey}
+	if apiKey.Credentials != nil {
+		credentials := *apiKey.Credentials
+		for _, field := range []*string{&credentials.SecretAccessKey, &credentials.SessionToken, &credentials.ClientSecret} {
+			if *field != "" {
+				*field = MaskedSecret
+			}
+		}
+		resp.Credentials = &credentials
+	}
+	return resp
+}
+
+// ToAPIKeyResponses 批量转换API密钥响应
+func ToAPIKeyResponses(apiKeys []models.APIKey) []APIKeyResponse {
+	if apiKeys == nil {
+		return nil
+	}
+	responses := make([]APIKeyResponse, len(apiKeys))
+	for i := range apiKeys {
+		responses[i] = ToAPIKeyResponse(&apiKeys[i])
+	}
+	return responses
+}
+
+// ToTLSSettingsResponse 将TLS设置转换为响应DTO
+func ToTLSSettingsResponse(settings *models.TLSSettings) *TLSSettingsResponse {
+	if settings == nil {
+		return nil
+	}
+	return &TLSSettingsResponse{
+		CABundle:           settings.CABundle,
+		ClientCert:         settings.ClientCert,
+		HasClientKey:       settings.ClientKey != "",
+		MinVersion:         settings.MinVersion,
+		ServerName:         settings.ServerName,
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
 }
 
 // ToProxyConfigResponse 将模型转换为响应DTO
 func ToProxyConfigResponse(proxyConfig *models.ProxyConfig) ProxyConfigResponse {
 	resp := ProxyConfigResponse{
-		ID:            proxyConfig.ID,
-		Name:          proxyConfig.Name,
-		Slug:          proxyConfig.Slug,
-		ConfigType:    proxyConfig.ConfigType,
-		IsActive:      proxyConfig.IsActive,
-		APIKeys:       proxyConfig.APIKeys,
-		Method:        proxyConfig.Method,
-		TargetURL:     proxyConfig.TargetURL,
-		TargetBaseURL: proxyConfig.TargetBaseURL,
-		APIFormat:     proxyConfig.APIFormat,
-		OutputFormat:  proxyConfig.OutputFormat,
+		ID:                    proxyConfig.ID,
+		Name:                  proxyConfig.Name,
+		Slug:                  proxyConfig.Slug,
+		ConfigType:            proxyConfig.ConfigType,
+		APIKeyTemplate:        proxyConfig.APIKeyTemplate,
+		IsActive:              proxyConfig.IsActive,
+		APIKeys:               ToAPIKeyResponses(proxyConfig.APIKeys),
+		Method:                proxyConfig.Method,
+		MaxBodySizeMB:         proxyConfig.MaxBodySizeMB,
+		ConnectTimeout:        proxyConfig.ConnectTimeout,
+		TLSHandshakeTimeout:   proxyConfig.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: proxyConfig.ResponseHeaderTimeout,
+		TotalTimeout:          proxyConfig.TotalTimeout,
+		StreamIdleTimeout:     proxyConfig.StreamIdleTimeout,
+		EgressProxyURL:        proxyConfig.EgressProxyURL,
+		TLS:                   ToTLSSettingsResponse(proxyConfig.TLS),
+		RouteRules:            proxyConfig.RouteRules,
+		ResponseCache:         proxyConfig.ResponseCache,
+		RewriteRules:          proxyConfig.RewriteRules,
+		TargetURL:             proxyConfig.TargetURL,
+		TargetBaseURL:         proxyConfig.TargetBaseURL,
+		APIFormat:             proxyConfig.APIFormat,
+		OutputFormat:          proxyConfig.OutputFormat,
+		ModelAliases:          proxyConfig.ModelAliases,
+		RejectUnknownModels:   proxyConfig.RejectUnknownModels,
+		InlineRemoteMedia:     proxyConfig.InlineRemoteMedia,
+		StreamMode:            proxyConfig.StreamMode,
+		IncludeStreamUsage:    proxyConfig.IncludeStreamUsage,
+		FallbackSlugs:         proxyConfig.FallbackSlugs,
+		OAuthTokenURL:         proxyConfig.OAuthTokenURL,
+		OAuthScope:            proxyConfig.OAuthScope,
+		IPAllowlist:           proxyConfig.IPAllowlist,
+		IPDenylist:            proxyConfig.IPDenylist,
 	}
 
 	if proxyConfig.APIKeyLocation != nil {
@@ -110,3 +250,27 @@ type BatchAPIKeyImportResponse struct {
 type ClearAllAPIKeysResponse struct {
 	DeletedCount int `json:"deleted_count"`
 }
+
+// IPRejectionStatsResponse IP访问控制拒绝次数统计响应
+type IPRejectionStatsResponse struct {
+	Global  int64           `json:"global"`
+	Configs map[int32]int64 `json:"configs"`
+}
+
+// WebSocketStatsResponse 单个配置的WebSocket连接统计，in 为客户端发往上游，out 为上游发往客户端
+type WebSocketStatsResponse struct {
+	Active      int64 `json:"active"`
+	Total       int64 `json:"total"`
+	BytesIn     int64 `json:"bytes_in"`
+	BytesOut    int64 `json:"bytes_out"`
+	MessagesIn  int64 `json:"messages_in"`
+	MessagesOut int64 `json:"messages_out"`
+}
+
+// LLMUsageStatsResponse 单个配置的 token 用量统计，requests 为返回了用量的请求数
+type LLMUsageStatsResponse struct {
+	Requests     int64 `json:"requests"`
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	TotalTokens  int64 `json:"total_tokens"`
+}
@@ -1,19 +1,21 @@
 package handlers
 
 import (
-	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
 
 	"api-key-rotator/backend/internal/adapters"
 	"api-key-rotator/backend/internal/config"
 	"api-key-rotator/backend/internal/converters"
+	"api-key-rotator/backend/internal/converters/formats"
 	"api-key-rotator/backend/internal/infrastructure/cache"
 	"api-key-rotator/backend/internal/logger"
 	"api-key-rotator/backend/internal/models"
@@ -51,58 +53,198 @@ func (h *LLMProxyHandler) HandleLLMProxy(c *gin.Context) {
 		return
 	}
 
-	targetRequest, proxyConfig, err := h.prepareLLMRequest(c, slug, action)
-	if err != nil {
+	// 统一入口: 按请求的模型名查找目标配置，客户端格式由请求路径决定；
+	// 已有的LLM配置占用了统一入口的前缀时按普通服务标识处理
+	clientFormat := ""
+	if services.IsUnifiedLLMPrefix(slug) && !services.HasActiveLLMConfig(h.db, slug) {
+		routedSlug, format, ok := h.resolveUnifiedRoute(c, slug, action)
+		if !ok {
+			return
+		}
+		logger.Infof("Unified LLM endpoint routed %s request to slug '%s'", format, routedSlug)
+		action = slug + "/" + action
+		slug, clientFormat = routedSlug, format
+	}
+
+	// 记录原始请求，主服务不可用时按回退列表重新转换请求
+	original := captureLLMRequest(c)
+
+	targetRequest, proxyConfig, err := h.prepareLLMRequest(c, slug, action, clientFormat)
+	canFallBack := proxyConfig != nil && len(proxyConfig.FallbackSlugs) > 0 && !services.IsWebSocketUpgrade(c.Request)
+	if err != nil && !(canFallBack && errors.Is(err, services.ErrNoActiveKeys)) {
+		if errors.Is(err, services.ErrClientIPForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"detail": err.Error()})
+			return
+		}
+		if services.IsRequestBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"detail": services.ErrRequestBodyTooLarge.Error()})
+			return
+		}
 		logger.Warningf("Bad Request for LLM slug '%s': %v", slug, err)
 		c.JSON(http.StatusBadRequest, gin.H{"detail": err.Error()})
 		return
 	}
 
-	// 转发请求，传入proxyConfig以支持响应格式转换
-	if err := h.forwardLLMRequest(c, targetRequest, proxyConfig); err != nil {
+	// 转发请求，传入proxyConfig以支持响应格式转换；配置了回退服务时依次尝试；WebSocket 升级请求建立双向连接
+	forward := func() error { return h.forwardLLMRequest(c, targetRequest, proxyConfig) }
+	if canFallBack {
+		forward = func() error { return h.forwardWithFallback(c, original, action, proxyConfig, targetRequest, err) }
+	}
+	if services.IsWebSocketUpgrade(c.Request) {
+		forward = func() error { return services.ProxyWebSocket(c, h.cacheClient, proxyConfig, targetRequest) }
+	}
+	if err := forward(); err != nil {
+		switch {
+		case c.Request.Context().Err() != nil:
+			// 客户端已断开，上游请求已随上下文取消
+			logger.Warningf("Client disconnected before upstream responded for LLM slug '%s': %v", slug, err)
+			c.Abort()
+			return
+		case services.IsDestinationForbidden(err):
+			logger.Warningf("Blocked upstream request for LLM slug '%s': %v", slug, err)
+			writeLLMError(c, proxyConfig, http.StatusForbidden, services.ErrDestinationForbidden.Error())
+			return
+		case services.IsUpstreamTimeout(err):
+			logger.Errorf("Upstream timed out for LLM slug '%s': %v", slug, err)
+			writeLLMError(c, proxyConfig, http.StatusGatewayTimeout, "Upstream request timed out")
+			return
+		case errors.Is(err, services.ErrNoActiveKeys):
+			logger.Errorf("No provider available for LLM slug '%s': %v", slug, err)
+			writeLLMError(c, proxyConfig, http.StatusServiceUnavailable, err.Error())
+			return
+		}
 		logger.Errorf("An unexpected error occurred in LlmApiProxyHandler for slug '%s': %v", slug, err)
 		c.JSON(http.StatusBadGateway, gin.H{"detail": "Bad Gateway"})
 		return
 	}
 }
 
+// resolveUnifiedRoute 解析统一入口请求的客户端格式和目标配置，失败时直接写入错误响应
+func (h *LLMProxyHandler) resolveUnifiedRoute(c *gin.Context, prefix, action string) (string, string, bool) {
+	clientFormat := converters.DetectClientFormat(prefix + "/" + action)
+	if clientFormat == "" {
+		c.JSON(http.StatusNotFound, gin.H{"detail": fmt.Sprintf("unsupported unified LLM endpoint '/%s/%s'", prefix, action)})
+		return "", "", false
+	}
+
+	// 读取请求体以获得模型名，读取后放回供后续处理；此时尚未确定配置，使用全局大小上限
+	if err := services.LimitRequestBody(c, services.MaxBodyBytes(h.cfg, &models.ProxyConfig{})); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"detail": services.ErrRequestBodyTooLarge.Error()})
+		return "", "", false
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		if services.IsRequestBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"detail": services.ErrRequestBodyTooLarge.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"detail": "failed to read request body"})
+		}
+		return "", "", false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	model := extractModelFromBody(body)
+	if model == "" {
+		model = extractModelFromAction(action)
+	}
+	slug, err := services.ResolveModelRoute(h.db, model)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrNoModelRoute) {
+			status = http.StatusNotFound
+		}
+		logger.Warningf("Unified LLM endpoint could not route request: %v", err)
+		c.Data(status, "application/json", converters.BuildErrorResponse(clientFormat, status, err.Error()))
+		return "", "", false
+	}
+	return slug, clientFormat, true
+}
+
 // prepareLLMRequest 准备LLM代理请求，返回TargetRequest和ProxyConfig
-func (h *LLMProxyHandler) prepareLLMRequest(c *gin.Context, slug, action string) (*services.TargetRequest, *models.ProxyConfig, error) {
+// clientFormat 不为空时覆盖配置的输出格式（统一入口和回退服务按客户端的请求格式转换）
+// 适配器处理失败（如密钥耗尽）时仍返回已加载的配置，用于切换回退服务
+func (h *LLMProxyHandler) prepareLLMRequest(c *gin.Context, slug, action, clientFormat string) (*services.TargetRequest, *models.ProxyConfig, error) {
 	// 1. 加载基础配置
 	var proxyConfig models.ProxyConfig
 	if err := h.db.Preload("APIKeys").Where("slug = ? AND is_active = ? AND config_type = ?", slug, true, "LLM").First(&proxyConfig).Error; err != nil {
 		return nil, nil, fmt.Errorf("LLM service configuration with slug '%s' not found or inactive", slug)
 	}
+	if clientFormat != "" {
+		outputFormat := clientFormat
+		proxyConfig.OutputFormat = &outputFormat
+	}
+
+	// 访问控制: 校验客户端IP
+	if err := services.CheckConfigIPAccess(h.cacheClient, &proxyConfig, c.ClientIP()); err != nil {
+		return nil, nil, err
+	}
 
 	// 2. 获取格式配置
 	apiFormat := "openai_compatible"
 	if proxyConfig.APIFormat != nil {
 		apiFormat = *proxyConfig.APIFormat
 	}
-	clientFormat := "none"
+	clientFormat = "none"
 	if proxyConfig.OutputFormat != nil {
 		clientFormat = *proxyConfig.OutputFormat
 	}
 
-	// 3. 检查是否需要格式转换
-	needConversion := converters.NeedsConversion(clientFormat, apiFormat)
+	// 3. 检查是否需要格式转换；WebSocket 连接（如实时接口）按原样透传，不做格式转换
+	needConversion := converters.NeedsConversion(clientFormat, apiFormat) && !services.IsWebSocketUpgrade(c.Request)
+
+	// 客户端按自己的格式传递代理密钥，转换为上游格式适配器读取的位置
+	if needConversion {
+		translateProxyKey(c, clientFormat, apiFormat)
+	}
 
 	// 4. 读取请求体
+	if err := services.LimitRequestBody(c, services.MaxBodyBytes(h.cfg, &proxyConfig)); err != nil {
+		return nil, nil, err
+	}
 	bodyBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read request body: %w", err)
 	}
 
-	// 5. 如果需要，转换请求格式
+	// 5. 模型别名: 在格式转换前将客户端请求的模型名替换为上游模型名，响应中再还原
+	upstreamModel, err := h.applyModelAlias(c, &proxyConfig, &bodyBytes, &action)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 6. 如果需要，转换请求格式
+	// 配置了上游流式模式时，聊天请求即使格式相同也经过转换器，按上游的模式发送
+	upstreamStream, bridgeStream := services.UpstreamStreamMode(&proxyConfig)
+	bridgeStream = bridgeStream && converters.DetectClientFormat(action) != "" && !services.IsWebSocketUpgrade(c.Request)
+	c.Set(services.StreamBridgeContextKey, "")
+
 	convertedAction := action
-	if needConversion {
-		logger.Infof("Request format conversion enabled: %s -> %s", clientFormat, apiFormat)
+	if needConversion || bridgeStream {
+		fromFormat := apiFormat
+		if needConversion {
+			fromFormat = clientFormat
+			logger.Infof("Request format conversion enabled: %s -> %s", clientFormat, apiFormat)
+		}
 
-		converter, err := converters.NewConverter(clientFormat, apiFormat)
+		converter, err := converters.NewConverter(fromFormat, apiFormat)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create converter: %w", err)
 		}
 
+		// 目标格式不支持通过URL引用的媒体，按配置下载后内联
+		if proxyConfig.InlineRemoteMedia {
+			ctx := c.Request.Context()
+			converter.SetMediaFetcher(func(rawURL string) (string, []byte, error) {
+				return services.FetchRemoteMedia(ctx, h.cfg, &proxyConfig, rawURL)
+			})
+		}
+
+		// Gemini 客户端通过路径（streamGenerateContent）而不是请求体请求流式响应
+		converter.SetStream(converters.IsStreamAction(action))
+		if bridgeStream {
+			converter.SetUpstreamStream(upstreamStream)
+		}
+
 		// 转换请求体
 		convertedBody, err := converter.ConvertRequest(bodyBytes)
 		if err != nil {
@@ -111,24 +253,42 @@ func (h *LLMProxyHandler) prepareLLMRequest(c *gin.Context, slug, action string)
 		}
 		bodyBytes = convertedBody
 
+		// 记录桥接方式，响应按客户端请求的方式聚合或合成
+		switch {
+		case converter.ClientStream() && !converter.UpstreamStream():
+			c.Set(services.StreamBridgeContextKey, services.StreamBridgeSynthesize)
+		case !converter.ClientStream() && converter.UpstreamStream():
+			c.Set(services.StreamBridgeContextKey, services.StreamBridgeAggregate)
+		}
+
+		// Gemini 客户端的模型名在请求路径中，转换后的请求体需要补上模型名
+		if upstreamModel != "" && extractModelFromBody(bodyBytes) == "" {
+			bodyBytes = services.ReplaceRequestModel(bodyBytes, upstreamModel)
+		}
+
 		// 转换请求路径
 		convertedAction = converter.GetTargetPath(action)
 
-		// 如果路径包含 {model} 占位符，从请求体中提取模型名并替换
-		if strings.Contains(convertedAction, "{model}") {
-			model := extractModelFromBody(bodyBytes)
-			if model != "" {
-				convertedAction = strings.ReplaceAll(convertedAction, "{model}", model)
-			}
+		// 如果路径包含 {model} 占位符，使用转换前请求体中的模型名替换（Gemini 请求体不包含模型名）
+		if strings.Contains(convertedAction, "{model}") && upstreamModel != "" {
+			convertedAction = strings.ReplaceAll(convertedAction, "{model}", upstreamModel)
 		}
 
 		logger.Infof("Converted action path: %s -> %s", action, convertedAction)
 	}
 
-	// 6. 将转换后的body放回request
+	// OpenAI 兼容上游按配置在流式请求中要求返回用量
+	c.Set(services.StreamUsageInjectedContextKey, false)
+	if apiFormat == "openai_compatible" && proxyConfig.IncludeStreamUsage {
+		var injected bool
+		bodyBytes, injected = services.InjectStreamUsage(convertedAction, bodyBytes)
+		c.Set(services.StreamUsageInjectedContextKey, injected)
+	}
+
+	// 7. 将转换后的body放回request
 	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 
-	// 7. 根据 API format 选择适配器 (使用API格式，而非客户端格式)
+	// 8. 根据 API format 选择适配器 (使用API格式，而非客户端格式)
 	var adapter adapters.LLMAdapter
 	switch apiFormat {
 	case "openai_compatible":
@@ -142,55 +302,230 @@ func (h *LLMProxyHandler) prepareLLMRequest(c *gin.Context, slug, action string)
 		return nil, nil, fmt.Errorf("unsupported API format '%s' for LLM service '%s'", apiFormat, slug)
 	}
 
-	// 8. 实例化适配器并处理请求
+	// 9. 实例化适配器并处理请求
 	logger.Infof("LLM Proxy Handler for '%s': Dispatching to adapter: %s", slug, apiFormat)
 	targetRequest, err := adapter.ProcessRequest()
 	if err != nil {
-		return nil, nil, err
+		return nil, &proxyConfig, err
 	}
 
 	return targetRequest, &proxyConfig, nil
 }
 
-// forwardLLMRequest 转发LLM请求到目标服务器，并应用响应格式转换
-func (h *LLMProxyHandler) forwardLLMRequest(c *gin.Context, target *services.TargetRequest, proxyConfig *models.ProxyConfig) error {
-	// 构建目标URL
-	targetURL, err := url.Parse(target.URL)
-	if err != nil {
-		return fmt.Errorf("invalid target URL: %w", err)
+// applyModelAlias 按配置的别名表替换请求中的模型名，返回实际发送给上游的模型名
+// 模型名优先从请求体的 model 字段读取，没有时从 Gemini 风格的路径 "models/<model>:<method>" 读取
+func (h *LLMProxyHandler) applyModelAlias(c *gin.Context, proxyConfig *models.ProxyConfig, body *[]byte, action *string) (string, error) {
+	resolve := func(model string) (string, error) {
+		return services.ResolveModelAlias(proxyConfig, model)
+	}
+
+	requested := extractModelFromBody(*body)
+	upstream := requested
+	if requested != "" {
+		resolved, err := resolve(requested)
+		if err != nil {
+			return "", err
+		}
+		upstream = resolved
+		if upstream != requested {
+			*body = services.ReplaceRequestModel(*body, upstream)
+		}
+	} else {
+		newAction, pathModel, err := services.ReplaceActionModel(*action, resolve)
+		if err != nil {
+			return "", err
+		}
+		requested = pathModel
+		upstream = extractModelFromAction(newAction)
+		*action = newAction
 	}
 
-	// 添加查询参数
-	if len(target.Params) > 0 {
-		query := targetURL.Query()
-		for key, value := range target.Params {
-			query.Set(key, value)
+	if requested != upstream {
+		logger.Infof("Model alias applied: %s -> %s", requested, upstream)
+		c.Set(services.ModelMappingContextKey, &services.ModelMapping{Requested: requested, Upstream: upstream})
+	}
+	return upstream, nil
+}
+
+// translateProxyKey 将客户端按其格式传递的代理密钥移到上游格式适配器读取的请求头，
+// 并移除其他位置的密钥，避免代理密钥被转发给上游
+func translateProxyKey(c *gin.Context, clientFormat, apiFormat string) {
+	header := c.Request.Header
+	proxyKey := ""
+	switch converters.NormalizeFormat(clientFormat) {
+	case "anthropic":
+		proxyKey = header.Get("x-api-key")
+		if proxyKey == "" {
+			proxyKey = header.Get("x-anthropic-api-key")
+		}
+	case "gemini":
+		proxyKey = header.Get("x-goog-api-key")
+		if proxyKey == "" {
+			proxyKey = c.Request.URL.Query().Get("key")
 		}
-		targetURL.RawQuery = query.Encode()
+	}
+	if proxyKey == "" && strings.HasPrefix(header.Get("Authorization"), "Bearer ") {
+		proxyKey = strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+	}
+	if proxyKey == "" {
+		return
 	}
 
-	// 创建HTTP请求
-	req, err := http.NewRequest(target.Method, targetURL.String(), bytes.NewReader(target.Body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	for _, name := range []string{"Authorization", "x-api-key", "x-anthropic-api-key", "x-goog-api-key"} {
+		header.Del(name)
+	}
+	query := c.Request.URL.Query()
+	if query.Has("key") {
+		query.Del("key")
+		c.Request.URL.RawQuery = query.Encode()
 	}
 
-	// 设置请求头
-	for key, value := range target.Headers {
-		req.Header.Set(key, value)
+	switch converters.NormalizeFormat(apiFormat) {
+	case "anthropic":
+		header.Set("x-api-key", proxyKey)
+	case "gemini":
+		header.Set("x-goog-api-key", proxyKey)
+	default:
+		header.Set("Authorization", "Bearer "+proxyKey)
 	}
+}
 
-	logger.Infof("Forwarding request to: %s %s", req.Method, req.URL.String())
+// modelRestorer 返回将响应中的模型名还原为客户端请求名称的函数，未使用别名时返回 nil
+func modelRestorer(c *gin.Context) func([]byte) []byte {
+	value, _ := c.Get(services.ModelMappingContextKey)
+	mapping, ok := value.(*services.ModelMapping)
+	if !ok || mapping == nil {
+		return nil
+	}
+	return func(payload []byte) []byte {
+		return services.RestoreResponseModel(payload, mapping.Requested)
+	}
+}
+
+// forwardLLMRequest 转发LLM请求到目标服务器，并应用响应格式转换
+func (h *LLMProxyHandler) forwardLLMRequest(c *gin.Context, target *services.TargetRequest, proxyConfig *models.ProxyConfig) error {
+	logger.Infof("Forwarding request to: %s %s", target.Method, target.URL)
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// 发送请求，使用共享的连接池；客户端断开时上游请求随之取消
+	resp, err := services.SendUpstream(c.Request.Context(), target)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	logger.Infof("Received response from target with status code: %d", resp.StatusCode)
+	setProviderHeaders(c, proxyConfig, 0)
+	return h.writeLLMResponse(c, resp, target, proxyConfig)
+}
+
+// forwardWithFallback 依次尝试主服务和回退列表中的服务，连接失败、上游返回 5xx 或密钥耗尽时切换到下一个服务
+// 回退服务按客户端的原始请求格式重新转换请求，响应再转换回客户端格式；所有服务都失败时返回最后一个服务的结果
+func (h *LLMProxyHandler) forwardWithFallback(c *gin.Context, original *capturedLLMRequest, action string, primary *models.ProxyConfig, target *services.TargetRequest, prepareErr error) error {
+	clientFormat := llmClientFormat(primary)
+	candidates := append([]string{primary.Slug}, primary.FallbackSlugs...)
+
+	proxyConfig, lastErr := primary, prepareErr
+	var lastResp *http.Response
+	var lastTarget *services.TargetRequest
+	var lastConfig *models.ProxyConfig
+	lastAttempt := 0
+	for i, slug := range candidates {
+		if i > 0 {
+			// 恢复原始请求，清除上一个服务的模型映射后重新准备请求
+			original.restore(c)
+			c.Set(services.ModelMappingContextKey, nil)
+			target, proxyConfig, lastErr = h.prepareLLMRequest(c, slug, action, clientFormat)
+		}
+		if lastErr != nil {
+			logger.Warningf("LLM provider '%s' is unavailable, trying next fallback: %v", slug, lastErr)
+			continue
+		}
+
+		logger.Infof("Forwarding request to provider '%s': %s %s", slug, target.Method, target.URL)
+		resp, err := services.SendUpstream(c.Request.Context(), target)
+		if err != nil {
+			if c.Request.Context().Err() != nil {
+				return fmt.Errorf("failed to send request: %w", err)
+			}
+			logger.Warningf("LLM provider '%s' request failed: %v", slug, err)
+			lastErr = fmt.Errorf("failed to send request: %w", err)
+			continue
+		}
+
+		if services.ShouldFallBack(resp.StatusCode) && i < len(candidates)-1 {
+			// 保留错误响应，所有服务都失败时返回给客户端
+			logger.Warningf("LLM provider '%s' returned status %d, trying next fallback", slug, resp.StatusCode)
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			lastResp, lastTarget, lastConfig, lastAttempt, lastErr = resp, target, proxyConfig, i, nil
+			continue
+		}
+
+		defer resp.Body.Close()
+		logger.Infof("Received response from provider '%s' with status code: %d", slug, resp.StatusCode)
+		setProviderHeaders(c, proxyConfig, i)
+		return h.writeLLMResponse(c, resp, target, proxyConfig)
+	}
+
+	if lastResp != nil {
+		setProviderHeaders(c, lastConfig, lastAttempt)
+		return h.writeLLMResponse(c, lastResp, lastTarget, lastConfig)
+	}
+	return lastErr
+}
+
+// setProviderHeaders 在响应头中标明实际应答的服务及此前失败的服务数量
+func setProviderHeaders(c *gin.Context, proxyConfig *models.ProxyConfig, attempt int) {
+	apiFormat := "openai_compatible"
+	if proxyConfig.APIFormat != nil {
+		apiFormat = *proxyConfig.APIFormat
+	}
+	c.Header("X-Provider-Slug", proxyConfig.Slug)
+	c.Header("X-Provider-Format", apiFormat)
+	c.Header("X-Fallback-Attempts", strconv.Itoa(attempt))
+}
+
+// llmClientFormat 返回客户端的请求格式: 配置了输出格式时为输出格式，否则与上游API格式一致
+func llmClientFormat(proxyConfig *models.ProxyConfig) string {
+	if proxyConfig.OutputFormat != nil && *proxyConfig.OutputFormat != "none" && *proxyConfig.OutputFormat != "" {
+		return *proxyConfig.OutputFormat
+	}
+	if proxyConfig.APIFormat != nil {
+		return converters.NormalizeFormat(*proxyConfig.APIFormat)
+	}
+	return "openai"
+}
+
+// capturedLLMRequest 保存客户端的原始请求，切换回退服务时恢复
+// 准备请求时会改写请求头（代理密钥转换）并消费请求体
+type capturedLLMRequest struct {
+	header   http.Header
+	rawQuery string
+	body     *bytes.Buffer
+}
+
+// captureLLMRequest 记录当前请求头和查询参数，并在读取请求体时保存一份副本
+func captureLLMRequest(c *gin.Context) *capturedLLMRequest {
+	captured := &capturedLLMRequest{
+		header:   c.Request.Header.Clone(),
+		rawQuery: c.Request.URL.RawQuery,
+		body:     &bytes.Buffer{},
+	}
+	c.Request.Body = io.NopCloser(io.TeeReader(c.Request.Body, captured.body))
+	return captured
+}
+
+// restore 将请求恢复为客户端发送的原始请求
+func (r *capturedLLMRequest) restore(c *gin.Context) {
+	c.Request.Header = r.header.Clone()
+	c.Request.URL.RawQuery = r.rawQuery
+	c.Request.Body = io.NopCloser(bytes.NewReader(r.body.Bytes()))
+}
+
+// writeLLMResponse 将上游响应写回客户端，按需转换响应格式并还原模型名
+func (h *LLMProxyHandler) writeLLMResponse(c *gin.Context, resp *http.Response, target *services.TargetRequest, proxyConfig *models.ProxyConfig) error {
+	var err error
 
 	// 获取格式配置，创建转换器
 	apiFormat := "openai_compatible"
@@ -203,6 +538,7 @@ func (h *LLMProxyHandler) forwardLLMRequest(c *gin.Context, target *services.Tar
 	}
 
 	needConversion := converters.NeedsConversion(clientFormat, apiFormat)
+	restoreModel := modelRestorer(c)
 	var converter *converters.Converter
 	if needConversion {
 		converter, err = converters.NewConverter(apiFormat, clientFormat)
@@ -214,34 +550,42 @@ func (h *LLMProxyHandler) forwardLLMRequest(c *gin.Context, target *services.Tar
 		}
 	}
 
-	// 过滤响应头
-	filteredHeaders := utils.FilterResponseHeaders(resp.Header)
+	// 客户端请求的方式与上游不同时聚合或合成响应，格式相同时使用同格式的转换器
+	bridge := c.GetString(services.StreamBridgeContextKey)
+	bridgeConverter := converter
+	if bridge != "" && bridgeConverter == nil {
+		if bridgeConverter, err = converters.NewConverter(apiFormat, apiFormat); err != nil {
+			logger.Errorf("Failed to create stream bridge converter: %v", err)
+			bridge = ""
+		}
+	}
 
-	// 设置响应头
-	for key, value := range filteredHeaders {
-		c.Header(key, value)
+	// 统计用量的转换器: 转换时由转换器统计，否则按上游格式只解析用量
+	usageTracker := bridgeConverter
+	if usageTracker == nil {
+		if usageTracker, err = converters.NewConverter(apiFormat, apiFormat); err != nil {
+			logger.Errorf("Failed to create usage tracker: %v", err)
+		}
+	}
+
+	// 设置响应头，保留每个头部的全部值
+	for key, values := range utils.FilterResponseHeaders(resp.Header) {
+		c.Writer.Header()[key] = values
+	}
+	if target.RewriteResponseHeaders != nil {
+		target.RewriteResponseHeaders(c.Writer.Header())
 	}
 
 	// 检查是否为流式响应
 	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "text/event-stream") {
-		// 流式响应处理
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-		c.Status(resp.StatusCode)
-
-		if needConversion && converter != nil {
-			// 带转换的流式响应
-			return h.forwardStreamWithConversion(c, resp.Body, converter)
-		} else {
-			// 直接透传流式响应
-			return h.forwardStreamDirect(c, resp.Body)
-		}
-	} else {
-		// 普通响应处理
-		var bodyReader io.Reader = resp.Body
-
+	sseStream := strings.Contains(contentType, "text/event-stream")
+	// Gemini 的 streamGenerateContent 未指定 alt=sse 时以 JSON 数组的形式流式返回
+	arrayStream := !sseStream && resp.StatusCode < 300 && converters.IsStreamAction(target.URL)
+	// 客户端以同样的方式请求 JSON 数组形式的流，转换后的事件也按数组写出
+	arrayOutput := converters.IsStreamAction(c.Param("action")) && c.Query("alt") != "sse"
+
+	var bodyReader io.Reader = resp.Body
+	if !sseStream {
 		// 检查是否是gzip压缩的响应
 		if resp.Header.Get("Content-Encoding") == "gzip" {
 			gzReader, err := gzip.NewReader(resp.Body)
@@ -250,9 +594,43 @@ func (h *LLMProxyHandler) forwardLLMRequest(c *gin.Context, target *services.Tar
 			}
 			defer gzReader.Close()
 			bodyReader = gzReader
+			c.Writer.Header().Del("Content-Encoding")
 			logger.Infof("Response is gzip compressed, decompressing...")
 		}
+	}
+
+	if sseStream || arrayStream {
+		var reader streamEventReader = utils.NewSSEReader(bodyReader)
+		if arrayStream {
+			reader = &jsonArrayEventReader{reader: utils.NewJSONArrayReader(bodyReader)}
+		}
 
+		// 客户端未请求流式响应: 聚合为完整响应
+		if bridge == services.StreamBridgeAggregate {
+			err = h.writeAggregatedStream(c, resp.StatusCode, reader, bridgeConverter, restoreModel)
+			h.recordLLMUsage(c, proxyConfig, bridgeConverter.StreamUsage())
+			return err
+		}
+
+		// 流式响应处理
+		setStreamHeaders(c, arrayOutput)
+		c.Status(resp.StatusCode)
+
+		// 代理注入了 include_usage 而客户端未请求时，需要按事件去掉上游额外返回的用量数据块
+		stripUsage := converter == nil && c.GetBool(services.StreamUsageInjectedContextKey)
+		if needConversion && converter != nil || restoreModel != nil || arrayStream != arrayOutput || stripUsage {
+			// 带转换（或模型名还原、流形式转换）的流式响应，按事件逐个处理
+			err = h.forwardStreamWithConversion(c, reader, converter, restoreModel, arrayOutput, usageTracker)
+		} else {
+			// 直接透传流式响应
+			err = h.forwardStreamDirect(c, bodyReader, arrayStream, usageTracker)
+		}
+		if usageTracker != nil {
+			h.recordLLMUsage(c, proxyConfig, usageTracker.StreamUsage())
+		}
+		return err
+	} else {
+		// 普通响应处理
 		body, err := io.ReadAll(bodyReader)
 		if err != nil {
 			return fmt.Errorf("failed to read response body: %w", err)
@@ -263,6 +641,24 @@ func (h *LLMProxyHandler) forwardLLMRequest(c *gin.Context, target *services.Tar
 			logger.Errorf("Target server returned error %d: %s", resp.StatusCode, string(body))
 		}
 
+		// 客户端请求了流式响应: 将完整响应合成为流式响应
+		if bridge == services.StreamBridgeSynthesize && resp.StatusCode < 300 {
+			payloads, err := bridgeConverter.SynthesizeStream(body)
+			if err == nil {
+				setStreamHeaders(c, arrayOutput)
+				c.Status(resp.StatusCode)
+				reader := &payloadEventReader{payloads: payloads, converter: bridgeConverter}
+				err = h.forwardStreamWithConversion(c, reader, nil, restoreModel, arrayOutput, nil)
+				h.recordLLMUsage(c, proxyConfig, bridgeConverter.StreamUsage())
+				return err
+			}
+			logger.Errorf("Failed to synthesize stream from response: %v", err)
+		}
+
+		if resp.StatusCode < 300 && usageTracker != nil {
+			h.recordLLMUsage(c, proxyConfig, usageTracker.ResponseUsage(body))
+		}
+
 		if needConversion && converter != nil {
 			// 转换响应格式
 			convertedBody, err := converter.ConvertResponse(body)
@@ -272,94 +668,295 @@ func (h *LLMProxyHandler) forwardLLMRequest(c *gin.Context, target *services.Tar
 				c.Data(resp.StatusCode, contentType, body)
 				return nil
 			}
-			c.Data(resp.StatusCode, "application/json", convertedBody)
-		} else {
-			c.Data(resp.StatusCode, contentType, body)
+			body, contentType = convertedBody, "application/json"
+		}
+		if restoreModel != nil {
+			body = restoreModel(body)
+		}
+		c.Data(resp.StatusCode, contentType, body)
+	}
+
+	return nil
+}
+
+// setStreamHeaders 设置流式响应的响应头，JSON 数组形式的流（Gemini 客户端未指定 alt=sse）使用 JSON 类型
+func setStreamHeaders(c *gin.Context, arrayOutput bool) {
+	if arrayOutput {
+		c.Header("Content-Type", "application/json")
+	} else {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Connection", "keep-alive")
+	}
+	c.Header("Cache-Control", "no-cache")
+}
+
+// writeAggregatedStream 将上游的流式响应聚合为客户端格式的完整响应
+func (h *LLMProxyHandler) writeAggregatedStream(c *gin.Context, status int, reader streamEventReader, converter *converters.Converter, restoreModel func([]byte) []byte) error {
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read upstream stream: %w", err)
+		}
+		if event.IsComment || !event.HasData || event.Data == "[DONE]" {
+			continue
+		}
+		if err := converter.AggregateStreamChunk([]byte(event.Data)); err != nil {
+			logger.Errorf("Failed to aggregate stream chunk: %v", err)
 		}
 	}
 
+	body, err := converter.AggregatedResponse()
+	if err != nil {
+		return fmt.Errorf("failed to aggregate stream response: %w", err)
+	}
+	if restoreModel != nil {
+		body = restoreModel(body)
+	}
+	c.Header("Content-Type", "application/json")
+	c.Data(status, "application/json", body)
 	return nil
 }
 
-// forwardStreamDirect 直接透传流式响应
-func (h *LLMProxyHandler) forwardStreamDirect(c *gin.Context, body io.Reader) error {
+// forwardStreamDirect 直接透传流式响应，同时按事件解析上游返回的用量交给 usageTracker
+// arrayStream 为 true 时上游的流为 JSON 数组形式；usageTracker 为空时不解析用量
+func (h *LLMProxyHandler) forwardStreamDirect(c *gin.Context, body io.Reader, arrayStream bool, usageTracker *converters.Converter) error {
+	if usageTracker != nil {
+		pipeReader, pipeWriter := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var reader streamEventReader = utils.NewSSEReader(pipeReader)
+			if arrayStream {
+				reader = &jsonArrayEventReader{reader: utils.NewJSONArrayReader(pipeReader)}
+			}
+			trackStreamUsage(reader, usageTracker)
+			// 解析出错后继续读取，避免阻塞透传
+			io.Copy(io.Discard, pipeReader)
+		}()
+		defer func() {
+			pipeWriter.Close()
+			<-done
+		}()
+		body = io.TeeReader(body, pipeWriter)
+	}
+
 	c.Stream(func(w io.Writer) bool {
 		buffer := make([]byte, 1024)
 		n, err := body.Read(buffer)
+		// 最后一次读取可能同时返回数据和 io.EOF，先写出已读到的数据
+		if n > 0 {
+			if _, writeErr := w.Write(buffer[:n]); writeErr != nil {
+				return false
+			}
+		}
 		if err != nil {
 			if err != io.EOF {
 				logger.Errorf("Error reading stream: %v", err)
 			}
 			return false
 		}
-		_, err = w.Write(buffer[:n])
-		return err == nil
+		return true
 	})
 	return nil
 }
 
-// forwardStreamWithConversion 带格式转换的流式响应
-func (h *LLMProxyHandler) forwardStreamWithConversion(c *gin.Context, body io.Reader, converter *converters.Converter) error {
-	scanner := bufio.NewScanner(body)
-	// 增加缓冲区大小以处理大的SSE消息
-	buf := make([]byte, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
+// trackStreamUsage 读取上游流中的全部数据事件，将其中的用量交给 usageTracker
+func trackStreamUsage(reader streamEventReader, usageTracker *converters.Converter) {
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			return
+		}
+		if event.IsComment || !event.HasData || event.Data == "[DONE]" {
+			continue
+		}
+		usageTracker.TrackStreamUsage([]byte(event.Data))
+	}
+}
+
+// streamEventReader 逐个读取上游流中的事件，JSON 数组形式的流也按事件读取
+type streamEventReader interface {
+	Next() (*utils.SSEEvent, error)
+}
+
+// jsonArrayEventReader 将 JSON 数组流中的每个元素作为一个数据事件返回
+type jsonArrayEventReader struct {
+	reader *utils.JSONArrayReader
+}
+
+func (r *jsonArrayEventReader) Next() (*utils.SSEEvent, error) {
+	element, err := r.reader.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &utils.SSEEvent{Data: string(element), HasData: true}, nil
+}
+
+// payloadEventReader 依次返回已生成的目标格式事件，用于将完整响应合成为流式响应
+type payloadEventReader struct {
+	payloads  [][]byte
+	converter *converters.Converter
+}
+
+func (r *payloadEventReader) Next() (*utils.SSEEvent, error) {
+	if len(r.payloads) == 0 {
+		return nil, io.EOF
+	}
+	payload := r.payloads[0]
+	r.payloads = r.payloads[1:]
+	return &utils.SSEEvent{Event: r.converter.StreamEventName(payload), Data: string(payload), HasData: true}, nil
+}
+
+// forwardStreamWithConversion 带格式转换的流式响应，按完整的事件读取和写出
+// converter 为空时只做模型名还原，用量交给 usageTracker 解析（为空时不解析）；restoreModel 为空时不还原模型名
+// arrayOutput 为 true 时按 JSON 数组写出（Gemini 客户端未指定 alt=sse），只保留事件数据
+func (h *LLMProxyHandler) forwardStreamWithConversion(c *gin.Context, reader streamEventReader, converter *converters.Converter, restoreModel func([]byte) []byte, arrayOutput bool, usageTracker *converters.Converter) error {
+	// 客户端未请求用量时，去掉代理注入 include_usage 后上游额外返回的用量数据块
+	stripUsage := converter == nil && c.GetBool(services.StreamUsageInjectedContextKey)
+
+	writeEvent := func(w io.Writer, event *utils.SSEEvent) {
+		utils.WriteSSEEvent(w, event)
+	}
+	array := &utils.JSONArrayWriter{}
+	if arrayOutput {
+		// JSON 数组中没有注释、事件名和 [DONE]，只写出事件数据
+		writeEvent = func(w io.Writer, event *utils.SSEEvent) {
+			if event.HasData && !event.IsComment && event.Data != "[DONE]" {
+				array.WriteElement(w, []byte(event.Data))
+			}
+		}
+	}
+
+	// writeConverted 写出转换后的事件，事件名由目标格式决定
+	// 上游事件的 retry 放在第一个事件上，id 放在最后一个事件上，客户端按 id 重连时不会漏掉内容
+	writeConverted := func(w io.Writer, payloads [][]byte, source *utils.SSEEvent) {
+		for i, payload := range payloads {
+			if restoreModel != nil {
+				payload = restoreModel(payload)
+			}
+			event := &utils.SSEEvent{
+				Event:   converter.StreamEventName(payload),
+				Data:    string(payload),
+				HasData: true,
+			}
+			if source != nil && i == 0 {
+				event.Retry = source.Retry
+			}
+			if source != nil && i == len(payloads)-1 {
+				event.ID, event.HasID = source.ID, source.HasID
+			}
+			writeEvent(w, event)
+		}
+	}
 
 	c.Stream(func(w io.Writer) bool {
-		if !scanner.Scan() {
-			if err := scanner.Err(); err != nil {
-				logger.Errorf("Error scanning stream: %v", err)
+		event, err := reader.Next()
+		if err != nil {
+			if err != io.EOF {
+				logger.Errorf("Error reading stream: %v", err)
+			}
+			// 上游流结束，补发目标格式的结束事件（已发送过时为空）
+			if converter != nil {
+				writeConverted(w, converter.GetStreamEndEvents(), nil)
+			}
+			if arrayOutput {
+				array.Close(w)
 			}
 			return false
 		}
 
-		line := scanner.Text()
-
-		// 跳过空行
-		if strings.TrimSpace(line) == "" {
-			w.Write([]byte("\n"))
+		// 注释（包括心跳）和不含数据的事件（只有 id、retry 等字段）原样透传
+		if event.IsComment || !event.HasData {
+			writeEvent(w, event)
 			return true
 		}
 
-		// 处理SSE数据行
-		if strings.HasPrefix(line, "data: ") {
-			payload := strings.TrimPrefix(line, "data: ")
-
-			// 处理 [DONE] 信号
-			if payload == "[DONE]" {
-				w.Write([]byte("data: [DONE]\n\n"))
+		if converter == nil {
+			if usageTracker != nil && event.Data != "[DONE]" {
+				usageTracker.TrackStreamUsage([]byte(event.Data))
+			}
+			if stripUsage && services.IsStreamUsageChunk([]byte(event.Data)) {
 				return true
 			}
+			if restoreModel != nil {
+				event.Data = string(restoreModel([]byte(event.Data)))
+			}
+			writeEvent(w, event)
+			return true
+		}
 
-			// 转换JSON payload
-			convertedPayload, err := converter.ConvertStreamChunk([]byte(payload))
+		// 处理 [DONE] 信号，转换时替换为目标格式的结束事件
+		var payloads [][]byte
+		if event.Data == "[DONE]" {
+			payloads = converter.GetStreamEndEvents()
+		} else {
+			// 一个上游事件可能转换为零个或多个事件
+			payloads, err = converter.ConvertStreamChunk([]byte(event.Data))
 			if err != nil {
 				logger.Errorf("Failed to convert stream chunk: %v", err)
 				// 转换失败时透传原始数据
-				w.Write([]byte(line + "\n"))
+				writeEvent(w, event)
 				return true
 			}
+		}
 
-			// 如果转换结果为nil，跳过这个chunk
-			if convertedPayload == nil {
-				return true
+		if len(payloads) == 0 {
+			switch {
+			case event.Event == "ping":
+				// 上游的心跳事件（如 Anthropic 的 ping）在目标格式中没有对应事件，改为注释保持连接活跃
+				writeEvent(w, &utils.SSEEvent{IsComment: true, Comment: " ping"})
+			case event.HasID || event.Retry != "":
+				writeEvent(w, &utils.SSEEvent{ID: event.ID, HasID: event.HasID, Retry: event.Retry})
 			}
-
-			// 写入转换后的数据
-			w.Write([]byte("data: "))
-			w.Write(convertedPayload)
-			w.Write([]byte("\n\n"))
 			return true
 		}
-
-		// 其他行（如event:, id:等）直接透传
-		w.Write([]byte(line + "\n"))
+		writeConverted(w, payloads, event)
 		return true
 	})
 
 	return nil
 }
 
+// recordLLMUsage 记录上游返回的 token 用量: 保存到请求上下文，并累加配置的用量统计
+func (h *LLMProxyHandler) recordLLMUsage(c *gin.Context, proxyConfig *models.ProxyConfig, usage *formats.UniversalUsage) {
+	if usage == nil {
+		return
+	}
+	c.Set(services.LLMUsageContextKey, *usage)
+	logger.Infof("LLM usage for service '%s': input_tokens=%d output_tokens=%d total_tokens=%d",
+		proxyConfig.Slug, usage.InputTokens, usage.OutputTokens, usage.TotalTokens)
+	services.RecordLLMUsage(context.Background(), h.cacheClient, proxyConfig.ID,
+		usage.InputTokens, usage.OutputTokens, usage.TotalTokens)
+}
+
+// writeLLMError 以客户端期望的格式返回代理自身产生的错误
+// 客户端格式为配置的输出格式，未配置时与上游API格式一致
+func writeLLMError(c *gin.Context, proxyConfig *models.ProxyConfig, status int, message string) {
+	clientFormat := "none"
+	if proxyConfig.OutputFormat != nil {
+		clientFormat = *proxyConfig.OutputFormat
+	}
+	if clientFormat == "none" || clientFormat == "" {
+		clientFormat = "openai_compatible"
+		if proxyConfig.APIFormat != nil {
+			clientFormat = *proxyConfig.APIFormat
+		}
+	}
+
+	c.Data(status, "application/json", converters.BuildErrorResponse(clientFormat, status, message))
+}
+
+// extractModelFromAction extracts the model name from a Gemini style path "models/<model>:<method>"
+func extractModelFromAction(action string) string {
+	_, model, _ := services.ReplaceActionModel(action, func(model string) (string, error) {
+		return model, nil
+	})
+	return model
+}
+
 // extractModelFromBody extracts the model name from a request body
 func extractModelFromBody(body []byte) string {
 	var req map[string]interface{}
@@ -1,29 +1,36 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"api-key-rotator/backend/internal/config"
 	"api-key-rotator/backend/internal/dto"
+	"api-key-rotator/backend/internal/infrastructure/cache"
 	"api-key-rotator/backend/internal/infrastructure/database"
 	"api-key-rotator/backend/internal/logger"
 	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/services"
+	"api-key-rotator/backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 // ManagementHandler 管理API处理器 - 使用接口抽象架构
 type ManagementHandler struct {
-	cfg    *config.Config
-	dbRepo database.Repository
+	cfg         *config.Config
+	dbRepo      database.Repository
+	cacheClient cache.CacheInterface
 }
 
 // NewManagementHandler 创建管理处理器实例
-func NewManagementHandler(cfg *config.Config, dbRepo database.Repository) *ManagementHandler {
+func NewManagementHandler(cfg *config.Config, dbRepo database.Repository, cacheClient cache.CacheInterface) *ManagementHandler {
 	return &ManagementHandler{
-		cfg:    cfg,
-		dbRepo: dbRepo,
+		cfg:         cfg,
+		dbRepo:      dbRepo,
+		cacheClient: cacheClient,
 	}
 }
 
@@ -73,18 +80,56 @@ func (h *ManagementHandler) CreateConfig(c *gin.Context) {
 
 	// 创建代理配置
 	config := &models.ProxyConfig{
-		Name:           req.Name,
-		Slug:           req.Slug,
-		ConfigType:     req.ConfigType,
-		APIKeyLocation: req.APIKeyLocation,
-		APIKeyName:     req.APIKeyName,
-		IsActive:       req.IsActive,
-		Method:         req.Method,
-		TargetURL:      req.TargetURL,
-		TargetBaseURL:  req.TargetBaseURL,
-		APIFormat:      req.APIFormat,
-		OutputFormat:   req.OutputFormat,
+		Name:                  req.Name,
+		Slug:                  req.Slug,
+		ConfigType:            req.ConfigType,
+		APIKeyLocation:        req.APIKeyLocation,
+		APIKeyName:            req.APIKeyName,
+		APIKeyTemplate:        req.APIKeyTemplate,
+		IsActive:              req.IsActive,
+		Method:                req.Method,
+		MaxBodySizeMB:         req.MaxBodySizeMB,
+		ConnectTimeout:        req.ConnectTimeout,
+		TLSHandshakeTimeout:   req.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: req.ResponseHeaderTimeout,
+		TotalTimeout:          req.TotalTimeout,
+		StreamIdleTimeout:     req.StreamIdleTimeout,
+		EgressProxyURL:        req.EgressProxyURL,
+		RouteRules:            services.NormalizeRouteRules(req.RouteRules),
+		ResponseCache:         req.ResponseCache,
+		RewriteRules:          services.NormalizeRewriteRules(req.RewriteRules),
+		TargetURL:             req.TargetURL,
+		TargetBaseURL:         req.TargetBaseURL,
+		APIFormat:             req.APIFormat,
+		OutputFormat:          req.OutputFormat,
+		ModelAliases:          services.NormalizeModelAliases(req.ModelAliases),
+		RejectUnknownModels:   req.RejectUnknownModels,
+		InlineRemoteMedia:     req.InlineRemoteMedia,
+		StreamMode:            req.StreamMode,
+		IncludeStreamUsage:    req.IncludeStreamUsage,
+		FallbackSlugs:         services.NormalizeFallbackSlugs(req.FallbackSlugs),
+		OAuthTokenURL:         req.OAuthTokenURL,
+		OAuthScope:            req.OAuthScope,
+		IPAllowlist:           req.IPAllowlist,
+		IPDenylist:            req.IPDenylist,
+	}
+
+	if err := validateProxyConfig(config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateFallbackSlugs(h.dbRepo.GetDB(), config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验TLS设置并加密客户端私钥
+	tlsSettings, err := services.PrepareTLSSettings(h.cfg, req.TLS, nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	config.TLS = tlsSettings
 
 	if err := h.dbRepo.CreateProxyConfig(config); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -161,18 +206,62 @@ func (h *ManagementHandler) UpdateConfig(c *gin.Context) {
 	config.ConfigType = req.ConfigType
 	config.APIKeyLocation = req.APIKeyLocation
 	config.APIKeyName = req.APIKeyName
+	config.APIKeyTemplate = req.APIKeyTemplate
 	config.IsActive = req.IsActive
 	config.Method = req.Method
+	config.MaxBodySizeMB = req.MaxBodySizeMB
+	config.ConnectTimeout = req.ConnectTimeout
+	config.TLSHandshakeTimeout = req.TLSHandshakeTimeout
+	config.ResponseHeaderTimeout = req.ResponseHeaderTimeout
+	config.TotalTimeout = req.TotalTimeout
+	config.StreamIdleTimeout = req.StreamIdleTimeout
+	config.EgressProxyURL = req.EgressProxyURL
+	config.RouteRules = services.NormalizeRouteRules(req.RouteRules)
+	config.ResponseCache = req.ResponseCache
+	config.RewriteRules = services.NormalizeRewriteRules(req.RewriteRules)
 	config.TargetURL = req.TargetURL
 	config.TargetBaseURL = req.TargetBaseURL
 	config.APIFormat = req.APIFormat
 	config.OutputFormat = req.OutputFormat
+	config.ModelAliases = services.NormalizeModelAliases(req.ModelAliases)
+	config.RejectUnknownModels = req.RejectUnknownModels
+	config.InlineRemoteMedia = req.InlineRemoteMedia
+	config.StreamMode = req.StreamMode
+	config.IncludeStreamUsage = req.IncludeStreamUsage
+	config.FallbackSlugs = services.NormalizeFallbackSlugs(req.FallbackSlugs)
+	config.OAuthTokenURL = req.OAuthTokenURL
+	config.OAuthScope = req.OAuthScope
+	config.IPAllowlist = req.IPAllowlist
+	config.IPDenylist = req.IPDenylist
+
+	if err := validateProxyConfig(config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateFallbackSlugs(h.dbRepo.GetDB(), config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验TLS设置并加密客户端私钥，未提供新私钥时沿用已保存的私钥
+	tlsSettings, err := services.PrepareTLSSettings(h.cfg, req.TLS, config.TLS)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	config.TLS = tlsSettings
 
 	if err := h.dbRepo.UpdateProxyConfig(config); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 配置变更后已缓存的响应可能失效，一并清除；旧的连接池按新参数重建
+	if err := services.PurgeResponseCache(context.Background(), h.cacheClient, config.ID); err != nil {
+		logger.Errorf("Failed to purge response cache for config %d: %v", config.ID, err)
+	}
+	services.EvictUpstreamTransports(config.ID, 0)
+
 	response := dto.ToProxyConfigResponse(config)
 	c.JSON(http.StatusOK, response)
 }
@@ -207,6 +296,7 @@ func (h *ManagementHandler) UpdateConfigStatus(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	services.EvictUpstreamTransports(config.ID, 0)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Status updated successfully"})
 }
@@ -225,6 +315,7 @@ func (h *ManagementHandler) DeleteConfig(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	services.EvictUpstreamTransports(int32(id), 0)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Config deleted successfully"})
 }
@@ -247,7 +338,7 @@ func (h *ManagementHandler) GetKeysForConfig(c *gin.Context) {
 	}
 
 	// 获取配置关联的API密钥
-	var apiKeys []*models.APIKey
+	var apiKeys []models.APIKey
 	err = h.dbRepo.GetDB().Where("proxy_config_id = ?", id).Find(&apiKeys).Error
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -255,7 +346,7 @@ func (h *ManagementHandler) GetKeysForConfig(c *gin.Context) {
 	}
 
 	// 返回API密钥列表
-	c.JSON(http.StatusOK, apiKeys)
+	c.JSON(http.StatusOK, dto.ToAPIKeyResponses(apiKeys))
 }
 
 // CreateAPIKeyForConfig 为配置创建API密钥
@@ -275,7 +366,7 @@ func (h *ManagementHandler) CreateAPIKeyForConfig(c *gin.Context) {
 	}
 
 	// 检查配置是否存在
-	_, err = h.dbRepo.GetProxyConfigByID(uint(configID))
+	config, err := h.dbRepo.GetProxyConfigByID(uint(configID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
 		return
@@ -283,17 +374,34 @@ func (h *ManagementHandler) CreateAPIKeyForConfig(c *gin.Context) {
 
 	// 创建API密钥
 	apiKey := &models.APIKey{
-		KeyValue:      req.KeyValue,
-		IsActive:      req.IsActive,
-		ProxyConfigID: int32(configID64),
+		KeyValue:       req.KeyValue,
+		IsActive:       req.IsActive,
+		ProxyConfigID:  int32(configID64),
+		Label:          req.Label,
+		EgressProxyURL: req.EgressProxyURL,
+		KeyType:        req.KeyType,
+		Credentials:    req.Credentials,
+	}
+
+	if err := services.ValidateAPIKey(apiKey, config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验通过后加密结构化凭证中的密钥字段
+	credentials, err := services.PrepareKeyCredentials(h.cfg, apiKey.Credentials)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	apiKey.Credentials = credentials
 
 	if err := h.dbRepo.CreateAPIKey(apiKey); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, apiKey)
+	c.JSON(http.StatusCreated, dto.ToAPIKeyResponse(apiKey))
 }
 
 // UpdateAPIKeyStatus 更新API密钥状态
@@ -326,6 +434,7 @@ func (h *ManagementHandler) UpdateAPIKeyStatus(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	services.EvictUpstreamTransports(apiKey.ProxyConfigID, apiKey.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "API key status updated successfully"})
 }
@@ -340,10 +449,18 @@ func (h *ManagementHandler) DeleteAPIKey(c *gin.Context) {
 	}
 	keyID := uint(keyID64)
 
+	apiKey, err := h.dbRepo.GetAPIKeyByID(keyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
 	if err := h.dbRepo.DeleteAPIKey(uint(keyID)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// 回收该密钥独立出口代理的连接池
+	services.EvictUpstreamTransports(apiKey.ProxyConfigID, apiKey.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
 }
@@ -436,6 +553,7 @@ func (h *ManagementHandler) ClearAllAPIKeys(c *gin.Context) {
 	}
 
 	logger.Infof("Successfully deleted %d API keys for config %d (%s)", count, id, config.Name)
+	services.EvictUpstreamTransports(config.ID, 0)
 
 	response := dto.ClearAllAPIKeysResponse{
 		DeletedCount: int(count),
@@ -444,6 +562,183 @@ func (h *ManagementHandler) ClearAllAPIKeys(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// PurgeResponseCache 清除指定配置的全部缓存响应
+func (h *ManagementHandler) PurgeResponseCache(c *gin.Context) {
+	id, err := h.parseID(c)
+	if err != nil {
+		return
+	}
+
+	config, err := h.dbRepo.GetProxyConfigByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
+		return
+	}
+
+	if err := services.PurgeResponseCache(context.Background(), h.cacheClient, config.ID); err != nil {
+		logger.Errorf("Failed to purge response cache for config %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge response cache"})
+		return
+	}
+
+	logger.Infof("Purged response cache for config %d (%s)", id, config.Name)
+	c.JSON(http.StatusOK, gin.H{"message": "Response cache purged successfully"})
+}
+
+// GetIPRejectionStats 获取IP访问控制的拒绝次数统计
+func (h *ManagementHandler) GetIPRejectionStats(c *gin.Context) {
+	ctx := context.Background()
+
+	response := dto.IPRejectionStatsResponse{
+		Global:  h.readCounter(ctx, services.GlobalIPRejectedCounterKey),
+		Configs: make(map[int32]int64),
+	}
+
+	configs, err := h.dbRepo.ListProxyConfigs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, config := range configs {
+		if count := h.readCounter(ctx, services.IPRejectedCounterKey(config.ID)); count > 0 {
+			response.Configs[config.ID] = count
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetWebSocketStats 获取各配置的WebSocket连接统计，只返回有过连接的配置
+func (h *ManagementHandler) GetWebSocketStats(c *gin.Context) {
+	ctx := context.Background()
+
+	configs, err := h.dbRepo.ListProxyConfigs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make(map[int32]dto.WebSocketStatsResponse)
+	for _, config := range configs {
+		total := h.readCounter(ctx, services.WebSocketCounterKey(config.ID, "total"))
+		if total == 0 {
+			continue
+		}
+		response[config.ID] = dto.WebSocketStatsResponse{
+			Active:      h.readCounter(ctx, services.WebSocketCounterKey(config.ID, "active")),
+			Total:       total,
+			BytesIn:     h.readCounter(ctx, services.WebSocketCounterKey(config.ID, "bytes_in")),
+			BytesOut:    h.readCounter(ctx, services.WebSocketCounterKey(config.ID, "bytes_out")),
+			MessagesIn:  h.readCounter(ctx, services.WebSocketCounterKey(config.ID, "messages_in")),
+			MessagesOut: h.readCounter(ctx, services.WebSocketCounterKey(config.ID, "messages_out")),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLLMUsageStats 获取各LLM配置的 token 用量统计，只返回有过用量的配置
+func (h *ManagementHandler) GetLLMUsageStats(c *gin.Context) {
+	ctx := context.Background()
+
+	configs, err := h.dbRepo.ListProxyConfigs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make(map[int32]dto.LLMUsageStatsResponse)
+	for _, config := range configs {
+		requests := h.readCounter(ctx, services.LLMUsageCounterKey(config.ID, "requests"))
+		if requests == 0 {
+			continue
+		}
+		response[config.ID] = dto.LLMUsageStatsResponse{
+			Requests:     requests,
+			InputTokens:  h.readCounter(ctx, services.LLMUsageCounterKey(config.ID, "input_tokens")),
+			OutputTokens: h.readCounter(ctx, services.LLMUsageCounterKey(config.ID, "output_tokens")),
+			TotalTokens:  h.readCounter(ctx, services.LLMUsageCounterKey(config.ID, "total_tokens")),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// readCounter 读取缓存中的计数器，不存在时返回0
+func (h *ManagementHandler) readCounter(ctx context.Context, key string) int64 {
+	value, err := h.cacheClient.Get(ctx, key)
+	if err != nil {
+		return 0
+	}
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// validateProxyConfig 在保存前校验代理配置
+func validateProxyConfig(config *models.ProxyConfig) error {
+	if _, err := utils.NewIPFilter(config.IPAllowlist, config.IPDenylist); err != nil {
+		return fmt.Errorf("invalid IP access rules: %w", err)
+	}
+	if err := services.ValidateCredentialInjection(config); err != nil {
+		return err
+	}
+	if config.MaxBodySizeMB != nil && *config.MaxBodySizeMB < 0 {
+		return fmt.Errorf("max_body_size_mb must not be negative")
+	}
+	if err := services.ValidateUpstreamTimeouts(config); err != nil {
+		return err
+	}
+	if err := services.ValidateEgressProxyURL(config.EgressProxyURL); err != nil {
+		return err
+	}
+	if err := services.ValidateRouteRules(config); err != nil {
+		return err
+	}
+	if err := services.ValidateRewriteRules(config); err != nil {
+		return err
+	}
+	if err := services.ValidateResponseCacheSettings(config); err != nil {
+		return err
+	}
+	if err := validateProxyConfigDestinations(config); err != nil {
+		return err
+	}
+	if err := services.ValidateModelAliases(config); err != nil {
+		return err
+	}
+	if err := services.ValidateLLMSlug(config); err != nil {
+		return err
+	}
+	if err := services.ValidateStreamMode(config); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateProxyConfigDestinations 按出站策略校验配置中的上游地址
+func validateProxyConfigDestinations(config *models.ProxyConfig) error {
+	destinations := []struct {
+		field string
+		value *string
+	}{
+		{"target_url", config.TargetURL},
+		{"target_base_url", config.TargetBaseURL},
+		{"oauth_token_url", config.OAuthTokenURL},
+	}
+	for _, destination := range destinations {
+		if destination.value == nil {
+			continue
+		}
+		if err := services.ValidateDestination(destination.field, *destination.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // parseID 是一个辅助函数，用于从URL参数解析ID
 func (h *ManagementHandler) parseID(c *gin.Context) (int32, error) {
 	id64, err := strconv.ParseInt(c.Param("id"), 10, 32)
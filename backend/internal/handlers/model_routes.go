@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"api-key-rotator/backend/internal/dto"
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetModelRoutes 按匹配顺序获取统一入口的模型路由
+func (h *ManagementHandler) GetModelRoutes(c *gin.Context) {
+	routes, err := h.dbRepo.ListModelRoutes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, routes)
+}
+
+// CreateModelRoute 创建模型路由
+func (h *ManagementHandler) CreateModelRoute(c *gin.Context) {
+	var req dto.ModelRouteCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	route := &models.ModelRoute{
+		Pattern:  strings.TrimSpace(req.Pattern),
+		Slug:     req.Slug,
+		Priority: req.Priority,
+		IsActive: req.IsActive,
+	}
+	if err := services.ValidateModelRoute(h.dbRepo.GetDB(), route); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.dbRepo.CreateModelRoute(route); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, route)
+}
+
+// UpdateModelRoute 更新模型路由
+func (h *ManagementHandler) UpdateModelRoute(c *gin.Context) {
+	id, err := h.parseID(c)
+	if err != nil {
+		return
+	}
+
+	var req dto.ModelRouteCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	route, err := h.dbRepo.GetModelRouteByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model route not found"})
+		return
+	}
+
+	route.Pattern = strings.TrimSpace(req.Pattern)
+	route.Slug = req.Slug
+	route.Priority = req.Priority
+	route.IsActive = req.IsActive
+	if err := services.ValidateModelRoute(h.dbRepo.GetDB(), route); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.dbRepo.UpdateModelRoute(route); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, route)
+}
+
+// DeleteModelRoute 删除模型路由
+func (h *ManagementHandler) DeleteModelRoute(c *gin.Context) {
+	id, err := h.parseID(c)
+	if err != nil {
+		return
+	}
+
+	if err := h.dbRepo.DeleteModelRoute(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Model route deleted successfully"})
+}
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"api-key-rotator/backend/internal/infrastructure/cache"
 	"api-key-rotator/backend/internal/config"
@@ -49,28 +51,96 @@ func (h *ProxyHandler) HandleGenericProxy(c *gin.Context) {
 		return
 	}
 
+	// 提取除了服务标识符之外的路径部分
+	subPath := ""
+	if len(parts) > 1 {
+		subPath = parts[1]
+	}
+
+	// 规范化子路径: 路由匹配、响应缓存和转发都使用同一个路径
+	if err := services.CheckEscapedPath(c.Request.URL.EscapedPath()); err != nil {
+		writeGenericPrepareError(c, serviceSlug, err)
+		return
+	}
+	subPath, err := services.CleanRoutePath(subPath)
+	if err != nil {
+		writeGenericPrepareError(c, serviceSlug, err)
+		return
+	}
+
 	handler := services.NewBaseProxyHandler(h.cfg, h.db, h.cacheClient, c, serviceSlug, "")
 	
-	targetRequest, err := h.prepareGenericRequest(handler)
+	proxyConfig, route, err := h.authorizeGenericRequest(handler, subPath)
 	if err != nil {
-		logger.Warningf("Bad Request for slug '%s': %v", serviceSlug, err)
-		c.JSON(http.StatusBadRequest, gin.H{"detail": err.Error()})
+		writeGenericPrepareError(c, serviceSlug, err)
+		return
+	}
+
+	// 响应缓存命中时直接返回，不轮询密钥也不请求上游
+	responseCache := services.NewResponseCache(c.Request.Context(), h.cacheClient, proxyConfig, c.Request, subPath)
+	if responseCache != nil {
+		if cached, ok := responseCache.Lookup(c.Request.Context()); ok {
+			logger.Infof("Serving cached response for slug '%s'", serviceSlug)
+			writeCachedResponse(c, proxyConfig, cached)
+			return
+		}
+	}
+
+	targetRequest, err := h.prepareGenericRequest(handler, proxyConfig, route)
+	if err != nil {
+		writeGenericPrepareError(c, serviceSlug, err)
 		return
 	}
-	
-	// 将完整的路径传递给转发函数
-	c.Set("fullPath", slug)
 
-	// 转发请求
-	if err := h.forwardRequest(c, targetRequest); err != nil {
+	// 转发请求；WebSocket 升级请求建立双向连接
+	forward := func() error { return h.forwardRequest(c, targetRequest, responseCache) }
+	if services.IsWebSocketUpgrade(c.Request) {
+		forward = func() error { return services.ProxyWebSocket(c, h.cacheClient, proxyConfig, targetRequest) }
+	}
+	if err := forward(); err != nil {
+		switch {
+		case c.Request.Context().Err() != nil:
+			// 客户端已断开，上游请求已随上下文取消
+			logger.Warningf("Client disconnected before upstream responded for slug '%s': %v", serviceSlug, err)
+			c.Abort()
+			return
+		case services.IsRequestBodyTooLarge(err):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"detail": services.ErrRequestBodyTooLarge.Error()})
+			return
+		case services.IsDestinationForbidden(err):
+			logger.Warningf("Blocked upstream request for slug '%s': %v", serviceSlug, err)
+			c.JSON(http.StatusForbidden, gin.H{"detail": services.ErrDestinationForbidden.Error()})
+			return
+		case services.IsUpstreamTimeout(err):
+			logger.Errorf("Upstream timed out for slug '%s': %v", serviceSlug, err)
+			c.JSON(http.StatusGatewayTimeout, gin.H{"detail": "Gateway Timeout"})
+			return
+		}
 		logger.Errorf("An unexpected error occurred in GenericApiProxyHandler for slug '%s': %v", serviceSlug, err)
 		c.JSON(http.StatusBadGateway, gin.H{"detail": "Bad Gateway"})
 		return
 	}
 }
 
-// prepareGenericRequest 准备通用代理请求
-func (h *ProxyHandler) prepareGenericRequest(handler *services.BaseProxyHandler) (*services.TargetRequest, error) {
+// writeGenericPrepareError 将准备请求阶段的错误映射为响应状态码
+func writeGenericPrepareError(c *gin.Context, serviceSlug string, err error) {
+	switch {
+	case errors.Is(err, services.ErrClientIPForbidden), errors.Is(err, services.ErrRouteDenied):
+		c.JSON(http.StatusForbidden, gin.H{"detail": err.Error()})
+		return
+	case errors.Is(err, services.ErrMethodNotAllowed):
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"detail": err.Error()})
+		return
+	case services.IsRequestBodyTooLarge(err):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"detail": services.ErrRequestBodyTooLarge.Error()})
+		return
+	}
+	logger.Warningf("Bad Request for slug '%s': %v", serviceSlug, err)
+	c.JSON(http.StatusBadRequest, gin.H{"detail": err.Error()})
+}
+
+// authorizeGenericRequest 校验代理密钥、加载配置并完成IP和路由校验
+func (h *ProxyHandler) authorizeGenericRequest(handler *services.BaseProxyHandler, subPath string) (*models.ProxyConfig, *services.RouteMatch, error) {
 	// 1. 认证 (只支持Header)
 	proxyKeyHeader := handler.C.GetHeader("X-Proxy-Key")
 	validKeys := h.cfg.GetGlobalProxyKeys()
@@ -82,117 +152,111 @@ func (h *ProxyHandler) prepareGenericRequest(handler *services.BaseProxyHandler)
 		}
 	}
 	if !isValidKey {
-		return nil, fmt.Errorf("invalid or missing X-Proxy-Key header")
+		return nil, nil, fmt.Errorf("invalid or missing X-Proxy-Key header")
 	}
 
 	// 2. 加载配置
 	var proxyConfig models.ProxyConfig
 	if err := h.db.Preload("APIKeys").Where("slug = ? AND is_active = ? AND config_type = ?", handler.Slug, true, "GENERIC").First(&proxyConfig).Error; err != nil {
-		return nil, fmt.Errorf("generic service configuration with slug '%s' not found or inactive", handler.Slug)
+		return nil, nil, fmt.Errorf("generic service configuration with slug '%s' not found or inactive", handler.Slug)
 	}
 
-	// 3. 方法校验
-	if proxyConfig.Method == nil || strings.ToUpper(handler.C.Request.Method) != strings.ToUpper(*proxyConfig.Method) {
-		return nil, fmt.Errorf("method Not Allowed. This path only accepts %s, but received %s",
-			strings.ToUpper(*proxyConfig.Method), strings.ToUpper(handler.C.Request.Method))
+	// 访问控制: 校验客户端IP
+	if err := handler.CheckIPAccess(&proxyConfig); err != nil {
+		return nil, nil, err
 	}
 
-	// 4. 轮询并注入密钥
-	apiKey, err := handler.RotateAPIKey(&proxyConfig)
+	// 3. 路由校验: 按路由规则匹配方法和路径，未命中时回退到配置的单一方法
+	route, err := services.MatchRoute(&proxyConfig, handler.C.Request.Method, subPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// 5. 处理请求头
-	headers := utils.FilterRequestHeaders(handler.C.Request.Header, []string{"x-proxy-key"})
-
-	// 6. 处理查询参数
-	params := make(map[string]string)
-	for key, values := range handler.C.Request.URL.Query() {
-		if len(values) > 0 {
-			params[key] = values[0]
-		}
-	}
-
-	// 7. 根据配置注入API密钥
-	if proxyConfig.APIKeyLocation != nil && proxyConfig.APIKeyName != nil {
-		location := strings.ToLower(*proxyConfig.APIKeyLocation)
-		keyName := *proxyConfig.APIKeyName
-		if location == "header" {
-			headers[keyName] = apiKey
-		} else if location == "query" {
-			params[keyName] = apiKey
-		}
-	}
+	return &proxyConfig, route, nil
+}
 
-	// 8. 读取请求体
-	body, err := io.ReadAll(handler.C.Request.Body)
+// prepareGenericRequest 准备通用代理请求
+func (h *ProxyHandler) prepareGenericRequest(handler *services.BaseProxyHandler, proxyConfig *models.ProxyConfig, route *services.RouteMatch) (*services.TargetRequest, error) {
+	// 4. 轮询密钥
+	apiKey, err := handler.RotateAPIKeyRecord(proxyConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read request body: %w", err)
+		return nil, err
 	}
 
-	return &services.TargetRequest{
+	// 5. 处理请求头，保留每个头部的全部值
+	headers := utils.FilterRequestHeaders(handler.C.Request.Header, []string{"x-proxy-key"})
+
+	targetRequest := &services.TargetRequest{
 		Method:  handler.C.Request.Method,
-		URL:     *proxyConfig.TargetURL,
+		URL:     buildGenericTargetURL(*proxyConfig.TargetURL, route.SubPath),
 		Headers: headers,
-		Params:  params,
-		Body:    body,
-	}, nil
-}
+		Params:  handler.C.Request.URL.Query(), // 6. 查询参数，保留重复参数的全部值
+		Trailer: handler.C.Request.Trailer,
+	}
 
-// forwardRequest 转发请求到目标服务器
-func (h *ProxyHandler) forwardRequest(c *gin.Context, target *services.TargetRequest) error {
-	// 构建目标URL
-	targetURL, err := url.Parse(target.URL)
+	// 连接参数: 超时、出口代理和TLS设置
+	upstream, err := services.ResolveUpstreamOptions(h.cfg, proxyConfig, apiKey)
 	if err != nil {
-		return fmt.Errorf("invalid target URL: %w", err)
+		return nil, err
 	}
+	targetRequest.Upstream = upstream
 
-	// 获取完整路径并处理
-	fullPath, _ := c.Get("fullPath")
-	requestPath := fullPath.(string)
-	
-	// 提取除了服务标识符之外的路径部分
-	parts := strings.SplitN(requestPath, "/", 2)
-	if len(parts) > 1 {
-		requestPath = parts[1]
+	// 7. 请求体: 默认以流的方式转发；只有需要写入JSON字段或对请求体签名时才读入内存
+	if err := services.LimitRequestBody(handler.C, services.MaxBodyBytes(h.cfg, proxyConfig)); err != nil {
+		return nil, err
+	}
+	injection := services.ResolveCredentialInjection(proxyConfig, services.CredentialInjection{})
+	if services.NeedsBufferedBody(apiKey, injection) {
+		body, err := io.ReadAll(handler.C.Request.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		targetRequest.Body = body
 	} else {
-		requestPath = ""
+		targetRequest.BodyStream = handler.C.Request.Body
+		targetRequest.ContentLength = handler.C.Request.ContentLength
 	}
 
-	// 如果目标URL没有以"/"结尾且请求路径不为空，则添加"/"
-	if requestPath != "" {
-		if !strings.HasSuffix(targetURL.Path, "/") && !strings.HasPrefix(requestPath, "/") {
-			targetURL.Path += "/"
-		}
-		targetURL.Path += requestPath
+	// 8. 根据密钥类型配置上游认证: 普通密钥按规则注入 (请求头、查询参数、Basic认证、JSON字段或路径)，
+	// OAuth2客户端凭证先换取访问令牌再注入，AWS密钥对则在发送前对最终请求签名
+	if err := handler.ApplyUpstreamAuth(targetRequest, proxyConfig, apiKey, injection); err != nil {
+		return nil, err
 	}
 
-	// 添加查询参数
-	if len(target.Params) > 0 {
-		query := targetURL.Query()
-		for key, value := range target.Params {
-			query.Set(key, value)
-		}
-		targetURL.RawQuery = query.Encode()
+	// 9. 执行配置的请求头、查询参数和响应头改写规则
+	rewriteCtx := services.NewRewriteContext(handler.C, apiKey)
+	services.ApplyRequestRewrites(targetRequest, proxyConfig.RewriteRules, rewriteCtx)
+	targetRequest.RewriteResponseHeaders = services.ResponseHeaderRewriter(proxyConfig.RewriteRules, rewriteCtx)
+
+	return targetRequest, nil
+}
+
+// buildGenericTargetURL 将请求中服务标识符之后的路径拼接到目标URL
+func buildGenericTargetURL(targetURL, subPath string) string {
+	if subPath == "" {
+		return targetURL
 	}
 
-	// 创建HTTP请求
-	req, err := http.NewRequest(target.Method, targetURL.String(), bytes.NewReader(target.Body))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	base := targetURL
+	query := ""
+	if idx := strings.Index(base, "?"); idx >= 0 {
+		base, query = base[:idx], base[idx:]
 	}
 
-	// 设置请求头
-	for key, value := range target.Headers {
-		req.Header.Set(key, value)
+	// 如果目标URL没有以"/"结尾且请求路径不以"/"开头，则添加"/"
+	if !strings.HasSuffix(base, "/") && !strings.HasPrefix(subPath, "/") {
+		base += "/"
 	}
+	return base + subPath + query
+}
 
-	logger.Infof("Forwarding request to: %s %s", req.Method, req.URL.String())
+// forwardRequest 转发请求到目标服务器，请求体和响应体均以流的方式传输
+// responseCache 不为空时，在转发的同时保存可缓存的响应
+func (h *ProxyHandler) forwardRequest(c *gin.Context, target *services.TargetRequest, responseCache *services.ResponseCache) error {
+	logger.Infof("Forwarding request to: %s %s", target.Method, target.URL)
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// 发送请求，使用共享的连接池；客户端断开时上游请求随之取消
+	resp, err := services.SendUpstream(c.Request.Context(), target)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -200,41 +264,114 @@ func (h *ProxyHandler) forwardRequest(c *gin.Context, target *services.TargetReq
 
 	logger.Infof("Received response from target with status code: %d", resp.StatusCode)
 
-	// 过滤响应头
-	filteredHeaders := utils.FilterResponseHeaders(resp.Header)
-	
-	// 设置响应头
-	for key, value := range filteredHeaders {
-		c.Header(key, value)
+	// 设置响应头，保留每个头部的全部值（如多个 Set-Cookie）
+	upstreamHeader := utils.FilterResponseHeaders(resp.Header)
+	responseHeader := c.Writer.Header()
+	for key, values := range upstreamHeader {
+		responseHeader[key] = values
+	}
+	if resp.ContentLength >= 0 && len(resp.Trailer) == 0 {
+		responseHeader.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	if target.RewriteResponseHeaders != nil {
+		target.RewriteResponseHeaders(responseHeader)
+	}
+
+	// 可缓存的响应在写回客户端的同时记录响应体，超过大小上限时放弃缓存
+	var body io.Reader = resp.Body
+	var capture *responseCapture
+	var cacheTTL time.Duration
+	if responseCache != nil {
+		responseHeader.Set("X-Cache", "MISS")
+		if cacheTTL = responseCache.TTLFor(resp); cacheTTL > 0 {
+			capture = &responseCapture{limit: responseCache.MaxEntryBytes()}
+			body = io.TeeReader(resp.Body, capture)
+		}
 	}
 
 	// 设置状态码
 	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
 
-	// 检查是否为流式响应
-	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "text/event-stream") {
-		// 流式响应
-		c.Stream(func(w io.Writer) bool {
-			buffer := make([]byte, 1024)
-			n, err := resp.Body.Read(buffer)
-			if err != nil {
-				if err != io.EOF {
-					logger.Errorf("Error reading stream: %v", err)
-				}
-				return false
-			}
-			_, err = w.Write(buffer[:n])
-			return err == nil
-		})
-	} else {
-		// 普通响应
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
+	// 流式响应每次写入后立即刷新，其他响应直接按块复制
+	flush := strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream")
+	if err := copyResponseBody(c.Writer, body, flush); err != nil {
+		// 响应头已发出，只能记录错误并中断连接
+		logger.Errorf("Error copying response body: %v", err)
+		return nil
+	}
+
+	// 转发上游的 trailer
+	for key, values := range resp.Trailer {
+		for _, value := range values {
+			responseHeader.Add(http.TrailerPrefix+key, value)
 		}
-		c.Data(resp.StatusCode, contentType, body)
+	}
+
+	if capture != nil && !capture.overflow && len(resp.Trailer) == 0 {
+		responseCache.Store(c.Request.Context(), resp.StatusCode, upstreamHeader, capture.buf.Bytes(), cacheTTL)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// writeCachedResponse 将缓存的响应写回客户端，响应头改写规则按当前请求重新执行
+func writeCachedResponse(c *gin.Context, proxyConfig *models.ProxyConfig, cached *services.CachedResponse) {
+	responseHeader := c.Writer.Header()
+	for key, values := range cached.Header {
+		responseHeader[key] = values
+	}
+	if c.Request.Method != http.MethodHead {
+		responseHeader.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+	}
+	if rewrite := services.ResponseHeaderRewriter(proxyConfig.RewriteRules, services.NewRewriteContext(c, nil)); rewrite != nil {
+		rewrite(responseHeader)
+	}
+	responseHeader.Set("X-Cache", "HIT")
+	responseHeader.Set("Age", strconv.Itoa(int(time.Since(cached.StoredAt).Seconds())))
+
+	c.Status(cached.StatusCode)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Write(cached.Body)
+}
+
+// responseCapture 记录写入的数据，超过上限后停止记录并标记溢出
+type responseCapture struct {
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (r *responseCapture) Write(p []byte) (int, error) {
+	if !r.overflow {
+		if int64(r.buf.Len()+len(p)) > r.limit {
+			r.overflow = true
+			r.buf.Reset()
+		} else {
+			r.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// copyResponseBody 将上游响应体按块写回客户端
+func copyResponseBody(w gin.ResponseWriter, body io.Reader, flush bool) error {
+	buffer := make([]byte, 32*1024)
+	for {
+		n, readErr := body.Read(buffer)
+		if n > 0 {
+			if _, err := w.Write(buffer[:n]); err != nil {
+				return err
+			}
+			if flush {
+				w.Flush()
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
@@ -26,6 +26,13 @@ type Repository interface {
 	DeleteAPIKey(id uint) error
 	ListAPIKeys() ([]*models.APIKey, error)
 
+	// 统一入口的模型路由管理
+	CreateModelRoute(route *models.ModelRoute) error
+	GetModelRouteByID(id uint) (*models.ModelRoute, error)
+	UpdateModelRoute(route *models.ModelRoute) error
+	DeleteModelRoute(id uint) error
+	ListModelRoutes() ([]*models.ModelRoute, error)
+
 	// 统计和查询
 	GetAPIKeyCountByService(serviceSlug string) (int64, error)
 
@@ -112,6 +112,38 @@ func (r *Repository) ListAPIKeys() ([]*models.APIKey, error) {
 	return keys, err
 }
 
+// CreateModelRoute 创建模型路由
+func (r *Repository) CreateModelRoute(route *models.ModelRoute) error {
+	return r.db.Create(route).Error
+}
+
+// GetModelRouteByID 根据ID获取模型路由
+func (r *Repository) GetModelRouteByID(id uint) (*models.ModelRoute, error) {
+	var route models.ModelRoute
+	err := r.db.First(&route, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
+
+// UpdateModelRoute 更新模型路由
+func (r *Repository) UpdateModelRoute(route *models.ModelRoute) error {
+	return r.db.Save(route).Error
+}
+
+// DeleteModelRoute 删除模型路由
+func (r *Repository) DeleteModelRoute(id uint) error {
+	return r.db.Delete(&models.ModelRoute{}, id).Error
+}
+
+// ListModelRoutes 按匹配顺序列出所有模型路由
+func (r *Repository) ListModelRoutes() ([]*models.ModelRoute, error) {
+	var routes []*models.ModelRoute
+	err := r.db.Order("priority ASC, id ASC").Find(&routes).Error
+	return routes, err
+}
+
 // GetAPIKeyCountByService 获取指定服务的API密钥数量
 func (r *Repository) GetAPIKeyCountByService(serviceSlug string) (int64, error) {
 	var count int64
@@ -124,6 +156,7 @@ func (r *Repository) Migrate() error {
 	return r.db.AutoMigrate(
 		&models.ProxyConfig{},
 		&models.APIKey{},
+		&models.ModelRoute{},
 	)
 }
 
@@ -131,6 +164,7 @@ func (r *Repository) Migrate() error {
 func (r *Repository) Reset() error {
 	// 按依赖顺序删除表
 	tables := []interface{}{
+		&models.ModelRoute{},
 		&models.APIKey{},
 		&models.ProxyConfig{},
 	}
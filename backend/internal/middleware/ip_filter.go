@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"api-key-rotator/backend/internal/infrastructure/cache"
+	"api-key-rotator/backend/internal/logger"
+	"api-key-rotator/backend/internal/services"
+	"api-key-rotator/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPFilter 全局IP访问控制中间件
+func IPFilter(filter *utils.IPFilter, cacheClient cache.CacheInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if filter == nil || filter.IsEmpty() {
+			c.Next()
+			return
+		}
+
+		clientIP := c.ClientIP()
+		if !filter.Allows(clientIP) {
+			logger.Warningf("Rejected client IP %s by global access rules: %s %s", clientIP, c.Request.Method, c.Request.URL.Path)
+			services.RecordIPRejection(cacheClient, services.GlobalIPRejectedCounterKey)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"detail": services.ErrClientIPForbidden.Error()})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"api-key-rotator/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader 请求ID使用的请求头/响应头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID 为每个请求分配请求ID，优先沿用客户端传入的 X-Request-ID
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+		c.Set(services.RequestIDContextKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
@@ -12,6 +12,7 @@ type ProxyConfig struct {
 	ConfigType     string    `json:"config_type" gorm:"size:50;not null;index"` // "generic" or "llm"
 	APIKeyLocation *string   `json:"api_key_location,omitempty" gorm:"size:50"`
 	APIKeyName     *string   `json:"api_key_name,omitempty" gorm:"size:100"`
+	APIKeyTemplate *string   `json:"api_key_template,omitempty" gorm:"size:255"` // 如 "Bearer {key}"
 	IsActive       bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
@@ -20,22 +21,152 @@ type ProxyConfig struct {
 	Method    *string `json:"method,omitempty" gorm:"size:10"`
 	TargetURL *string `json:"target_url,omitempty" gorm:"size:255"`
 
+	// 请求体大小上限（MB），为空时使用全局 PROXY_MAX_BODY_SIZE_MB，0 表示不限制
+	MaxBodySizeMB *int `json:"max_body_size_mb,omitempty"`
+
+	// 上游超时（秒），为空时使用全局 PROXY_TIMEOUT（响应头超时默认不单独限制），0 表示不限制
+	ConnectTimeout        *int `json:"connect_timeout,omitempty"`
+	TLSHandshakeTimeout   *int `json:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout *int `json:"response_header_timeout,omitempty"`
+	TotalTimeout          *int `json:"total_timeout,omitempty"`
+	StreamIdleTimeout     *int `json:"stream_idle_timeout,omitempty"`
+
+	// 出口代理 (http/https/socks5)，为空时使用环境变量中的代理设置
+	EgressProxyURL *string `json:"egress_proxy_url,omitempty" gorm:"size:255"`
+
+	// 上游连接的TLS设置 (JSON)
+	TLS *TLSSettings `json:"tls,omitempty" gorm:"serializer:json;type:text"`
+
+	// 按方法和路径匹配的路由规则 (JSON 数组)，按顺序匹配，未命中时回退到 Method
+	RouteRules []RouteRule `json:"route_rules,omitempty" gorm:"serializer:json;type:text"`
+
+	// 通用代理的响应缓存设置 (JSON)，为空或未启用时不缓存
+	ResponseCache *ResponseCacheSettings `json:"response_cache,omitempty" gorm:"serializer:json;type:text"`
+
 	// Fields from LLMAPIConfig (nullable)
 	TargetBaseURL *string `json:"target_base_url,omitempty" gorm:"size:255"`
 	APIFormat     *string `json:"api_format,omitempty" gorm:"size:50;default:openai_compatible"`
 	OutputFormat  *string `json:"output_format,omitempty" gorm:"size:50;default:none"`
 
+	// 模型别名表 (JSON 数组)，先精确匹配再按顺序通配符匹配；开启 RejectUnknownModels 时拒绝未命中的模型
+	ModelAliases        []ModelAlias `json:"model_aliases,omitempty" gorm:"serializer:json;type:text"`
+	RejectUnknownModels bool         `json:"reject_unknown_models"`
+
+	// 格式转换时，目标格式不支持通过URL引用的图片、文档、音频是否下载后以 base64 内联
+	InlineRemoteMedia bool `json:"inline_remote_media"`
+
+	// 上游流式模式: 为空时按客户端的请求转发，"stream" 表示上游只支持流式，"non_stream" 表示上游不支持流式
+	// 与客户端请求的方式不同时，上游的流式响应聚合为完整响应，或将完整响应合成为流式响应
+	StreamMode *string `json:"stream_mode,omitempty" gorm:"size:20"`
+
+	// OpenAI 兼容上游的流式请求是否注入 stream_options.include_usage，使流的最后返回用量
+	IncludeStreamUsage bool `json:"include_stream_usage"`
+
+	// 回退服务列表 (JSON 数组)，按顺序填写其他LLM配置的服务标识；连接失败、上游 5xx 或密钥耗尽时依次切换
+	FallbackSlugs []string `json:"fallback_slugs,omitempty" gorm:"serializer:json;type:text"`
+
+	// OAuth2 client_credentials 密钥使用的令牌端点和 scope
+	OAuthTokenURL *string `json:"oauth_token_url,omitempty" gorm:"size:255"`
+	OAuthScope    *string `json:"oauth_scope,omitempty" gorm:"size:255"`
+
+	// 请求头、响应头和查询参数的改写规则 (JSON 数组)，按顺序执行
+	RewriteRules []RewriteRule `json:"rewrite_rules,omitempty" gorm:"serializer:json;type:text"`
+
+	// 访问控制: IP/CIDR 白名单与黑名单 (JSON 数组)
+	IPAllowlist []string `json:"ip_allowlist,omitempty" gorm:"serializer:json;type:text"`
+	IPDenylist  []string `json:"ip_denylist,omitempty" gorm:"serializer:json;type:text"`
+
 	// 关系: 一个配置可以有多个 API Key
 	APIKeys []APIKey `json:"api_keys" gorm:"foreignKey:ProxyConfigID;constraint:OnDelete:CASCADE"`
 }
 
+// TLSSettings 上游连接的TLS设置
+type TLSSettings struct {
+	CABundle           string `json:"ca_bundle,omitempty"`            // PEM 格式的CA证书，追加到系统根证书之后
+	ClientCert         string `json:"client_cert,omitempty"`          // PEM 格式的客户端证书，用于双向TLS
+	ClientKey          string `json:"client_key,omitempty"`           // PEM 格式的客户端私钥，加密存储
+	MinVersion         string `json:"min_version,omitempty"`          // 最低TLS版本: "1.0"、"1.1"、"1.2"、"1.3"
+	ServerName         string `json:"server_name,omitempty"`          // 覆盖SNI和证书校验使用的主机名
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // 跳过证书校验，仅用于开发环境
+}
+
+// ResponseCacheSettings 通用代理的响应缓存设置，只缓存 GET/HEAD 请求
+type ResponseCacheSettings struct {
+	Enabled      bool     `json:"enabled"`
+	TTL          int      `json:"ttl,omitempty"`            // 缓存时间（秒），大于0时覆盖上游 Cache-Control 的 max-age
+	QueryParams  []string `json:"query_params,omitempty"`   // 参与缓存键的查询参数，为空表示全部参数
+	VaryHeaders  []string `json:"vary_headers,omitempty"`   // 参与缓存键的请求头
+	MaxEntrySize int      `json:"max_entry_size,omitempty"` // 单个响应体的大小上限（KB），为空时为 1024
+}
+
+// ModelAlias LLM代理的模型别名
+type ModelAlias struct {
+	Alias string `json:"alias"` // 客户端请求的模型名，支持 "*" 和 "?" 通配符，如 "gpt-4*"
+	Model string `json:"model"` // 实际发送给上游的模型名
+}
+
+// ModelRoute 统一入口的模型路由规则，按请求的模型名将请求分发到LLM配置
+type ModelRoute struct {
+	ID        int32     `json:"id" gorm:"primaryKey"`
+	Pattern   string    `json:"pattern" gorm:"size:255;not null"` // 模型名，支持 "*" 和 "?" 通配符
+	Slug      string    `json:"slug" gorm:"size:100;not null"`    // 目标LLM配置的服务标识
+	Priority  int       `json:"priority" gorm:"default:0"`        // 数值越小越先匹配，相同时按ID
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// RouteRule 通用代理的路由规则
+type RouteRule struct {
+	Methods    []string `json:"methods,omitempty"`     // 允许匹配的方法，为空表示任意方法
+	Path       string   `json:"path"`                  // 服务标识符之后的路径通配符，如 "/users/*"、"/files/**"
+	Action     string   `json:"action,omitempty"`      // "allow"(默认) 或 "deny"
+	TargetPath string   `json:"target_path,omitempty"` // 覆盖拼接到目标URL的路径，{path} 代表原始路径
+}
+
+// RewriteRule 声明式的请求/响应改写规则
+// Value 支持模板变量 {client_ip}、{request_id}、{key_label}
+type RewriteRule struct {
+	Scope   string `json:"scope"`              // "request_header"、"response_header" 或 "query"
+	Action  string `json:"action"`             // "set"、"append"、"remove" 或 "rename"
+	Name    string `json:"name"`               // 头部名或查询参数名
+	Value   string `json:"value,omitempty"`    // set/append 使用的值模板
+	NewName string `json:"new_name,omitempty"` // rename 的目标名称
+}
+
 // APIKey API密钥模型
 type APIKey struct {
-	ID            int32        `json:"id" gorm:"primaryKey"`
-	KeyValue      string       `json:"key_value" gorm:"size:255;not null"`
-	IsActive      bool         `json:"is_active" gorm:"default:true"`
-	ProxyConfigID int32        `json:"proxy_config_id"`
-	ProxyConfig   *ProxyConfig `json:"-" gorm:"foreignKey:ProxyConfigID"`
+	ID       int32  `json:"id" gorm:"primaryKey"`
+	KeyValue string `json:"key_value" gorm:"size:255;not null"`
+	Label    string `json:"label,omitempty" gorm:"size:100"` // 可选的密钥标签，用于日志和改写模板
+
+	// 覆盖配置上的出口代理，使该密钥的请求走独立的出口IP
+	EgressProxyURL *string      `json:"egress_proxy_url,omitempty" gorm:"size:255"`
+	IsActive       bool         `json:"is_active" gorm:"default:true"`
+	ProxyConfigID  int32        `json:"proxy_config_id"`
+	ProxyConfig    *ProxyConfig `json:"-" gorm:"foreignKey:ProxyConfigID"`
+
+	// 密钥类型: "api_key"(默认，直接注入)、"aws_sigv4"(使用结构化凭证签名请求)
+	// 或 "oauth2_client_credentials"(使用客户端凭证换取访问令牌)
+	KeyType     string          `json:"key_type" gorm:"size:50;default:api_key"`
+	Credentials *KeyCredentials `json:"credentials,omitempty" gorm:"serializer:json;type:text"`
+}
+
+// KeyCredentials 结构化的上游凭证，用于需要签名请求或换取令牌的密钥类型
+type KeyCredentials struct {
+	// AWS SigV4
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	SessionToken    string `json:"session_token,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Service         string `json:"service,omitempty"`
+
+	// OAuth2 client_credentials
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	Scope        string `json:"scope,omitempty"`       // 覆盖配置上的 scope
+	TokenURL     string `json:"token_url,omitempty"`   // 覆盖配置上的令牌地址
+	ClientAuth   string `json:"client_auth,omitempty"` // "basic"(默认) 或 "body"
 }
 
 // TableName 设置ProxyConfig表名
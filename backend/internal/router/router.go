@@ -5,9 +5,12 @@ import (
 
 	"api-key-rotator/backend/internal/config"
 	"api-key-rotator/backend/internal/handlers"
+	"api-key-rotator/backend/internal/logger"
 	"api-key-rotator/backend/internal/middleware"
 	"api-key-rotator/backend/internal/infrastructure/database"
 	"api-key-rotator/backend/internal/infrastructure/cache"
+	"api-key-rotator/backend/internal/services"
+	"api-key-rotator/backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +22,24 @@ func Setup(cfg *config.Config, dbRepo database.Repository, cacheInterface cache.
 
 	r := gin.New()
 
+	// 配置可信代理与客户端IP转发头，c.ClientIP() 只信任这些来源
+	if err := r.SetTrustedProxies(cfg.GetTrustedProxies()); err != nil {
+		logger.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.RemoteIPHeaders = cfg.GetTrustedIPHeaders()
+	r.ForwardedByClientIP = len(r.RemoteIPHeaders) > 0
+
+	// 全局IP访问控制规则，启动时解析
+	globalIPFilter, err := utils.NewIPFilter(cfg.GetIPAllowlist(), cfg.GetIPDenylist())
+	if err != nil {
+		logger.Fatalf("Invalid global IP access rules: %v", err)
+	}
+
+	// 出站请求策略（SSRF防护），启动时解析
+	if err := services.ConfigureOutboundPolicy(cfg); err != nil {
+		logger.Fatalf("Invalid outbound request policy: %v", err)
+	}
+
 	// 添加中间件
 	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
@@ -63,7 +84,7 @@ func Setup(cfg *config.Config, dbRepo database.Repository, cacheInterface cache.
 	})
 
 	// 创建处理器实例，使用完整版本
-	managementHandler := handlers.NewManagementHandler(cfg, dbRepo)
+	managementHandler := handlers.NewManagementHandler(cfg, dbRepo, cacheInterface)
 	proxyHandler := handlers.NewProxyHandler(cfg, dbRepo.GetDB(), cacheInterface)
 	llmProxyHandler := handlers.NewLLMProxyHandler(cfg, dbRepo.GetDB(), cacheInterface)
 
@@ -88,6 +109,7 @@ func Setup(cfg *config.Config, dbRepo database.Repository, cacheInterface cache.
 		adminAPI.PUT("/proxy-configs/:id", managementHandler.UpdateConfig)
 		adminAPI.PUT("/proxy-configs/:id/status", managementHandler.UpdateConfigStatus)
 		adminAPI.DELETE("/proxy-configs/:id", managementHandler.DeleteConfig)
+		adminAPI.DELETE("/proxy-configs/:id/cache", managementHandler.PurgeResponseCache)
 
 		// API密钥管理
 		adminAPI.GET("/proxy-configs/:id/keys", managementHandler.GetKeysForConfig)
@@ -96,14 +118,27 @@ func Setup(cfg *config.Config, dbRepo database.Repository, cacheInterface cache.
 		adminAPI.DELETE("/proxy-configs/:id/keys", managementHandler.ClearAllAPIKeys)
 		adminAPI.PATCH("/keys/:keyID", managementHandler.UpdateAPIKeyStatus)
 		adminAPI.DELETE("/keys/:keyID", managementHandler.DeleteAPIKey)
+
+		// 统一入口的模型路由
+		adminAPI.GET("/model-routes", managementHandler.GetModelRoutes)
+		adminAPI.POST("/model-routes", managementHandler.CreateModelRoute)
+		adminAPI.PUT("/model-routes/:id", managementHandler.UpdateModelRoute)
+		adminAPI.DELETE("/model-routes/:id", managementHandler.DeleteModelRoute)
+
+		// 访问控制、连接和用量统计
+		adminAPI.GET("/stats/ip-rejections", managementHandler.GetIPRejectionStats)
+		adminAPI.GET("/stats/websockets", managementHandler.GetWebSocketStats)
+		adminAPI.GET("/stats/llm-usage", managementHandler.GetLLMUsageStats)
 	}
 
 	// 通用代理路由组 - 公开API接口
 	proxyGroup := r.Group("/proxy")
+	proxyGroup.Use(middleware.RequestID(), middleware.IPFilter(globalIPFilter, cacheInterface))
 	proxyGroup.Any("/*slug", proxyHandler.HandleGenericProxy)
 
-	// LLM代理路由组 - 公开API接口
+	// LLM代理路由组 - 公开API接口，/llm/v1/... 和 /llm/v1beta/... 为按模型路由的统一入口
 	llmGroup := r.Group("/llm")
+	llmGroup.Use(middleware.RequestID(), middleware.IPFilter(globalIPFilter, cacheInterface))
 	llmGroup.Any("/:slug/*action", llmProxyHandler.HandleLLMProxy)
 
 	return r
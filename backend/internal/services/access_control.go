@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"api-key-rotator/backend/internal/infrastructure/cache"
+	"api-key-rotator/backend/internal/logger"
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+)
+
+// ErrClientIPForbidden 客户端IP被访问控制规则拒绝
+var ErrClientIPForbidden = errors.New("client IP is not allowed to access this service")
+
+// GlobalIPRejectedCounterKey 全局IP拒绝次数的缓存键
+const GlobalIPRejectedCounterKey = "ip_filter:global:rejected"
+
+// IPRejectedCounterKey 返回指定配置的IP拒绝次数缓存键
+func IPRejectedCounterKey(configID int32) string {
+	return fmt.Sprintf("proxy_config:%d:ip_rejected", configID)
+}
+
+// CheckIPAccess 按配置的IP白名单/黑名单校验当前客户端IP
+func (h *BaseProxyHandler) CheckIPAccess(serviceConfig *models.ProxyConfig) error {
+	return CheckConfigIPAccess(h.cacheClient, serviceConfig, h.C.ClientIP())
+}
+
+// CheckConfigIPAccess 按配置的IP白名单/黑名单校验客户端IP，拒绝时记录日志并计数
+func CheckConfigIPAccess(cacheClient cache.CacheInterface, serviceConfig *models.ProxyConfig, clientIP string) error {
+	if len(serviceConfig.IPAllowlist) == 0 && len(serviceConfig.IPDenylist) == 0 {
+		return nil
+	}
+
+	filter, err := utils.NewIPFilter(serviceConfig.IPAllowlist, serviceConfig.IPDenylist)
+	if err != nil {
+		// 配置已损坏时拒绝访问，避免访问控制失效
+		logger.Errorf("Invalid IP access rules for service '%s': %v", serviceConfig.Slug, err)
+		return ErrClientIPForbidden
+	}
+
+	if filter.Allows(clientIP) {
+		return nil
+	}
+
+	logger.Warningf("Rejected client IP %s for service '%s' by access rules", clientIP, serviceConfig.Slug)
+	RecordIPRejection(cacheClient, IPRejectedCounterKey(serviceConfig.ID))
+	return ErrClientIPForbidden
+}
+
+// RecordIPRejection 累加IP拒绝计数，计数失败只记录日志
+func RecordIPRejection(cacheClient cache.CacheInterface, counterKey string) {
+	if _, err := cacheClient.Incr(context.Background(), counterKey); err != nil {
+		logger.Errorf("Failed to record IP rejection for '%s': %v", counterKey, err)
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"api-key-rotator/backend/internal/infrastructure/cache/memory"
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestCheckConfigIPAccess(t *testing.T) {
+	tests := []struct {
+		allowlist    []string
+		denylist     []string
+		wantRejected bool
+		wantCounted  bool // 规则损坏时拒绝访问但不计入拒绝次数
+	}{
+		{nil, nil, false, false},
+		{[]string{"10.0.0.0/8"}, nil, false, false},
+		{[]string{"192.168.0.0/16"}, nil, true, true},
+		{nil, []string{"10.0.0.1"}, true, true},
+		{[]string{"bogus"}, nil, true, false},
+	}
+	for _, tt := range tests {
+		cacheClient := memory.NewMemoryCache()
+		config := &models.ProxyConfig{ID: 1, Slug: "svc", IPAllowlist: tt.allowlist, IPDenylist: tt.denylist}
+		err := CheckConfigIPAccess(cacheClient, config, "10.0.0.1")
+		if rejected := errors.Is(err, ErrClientIPForbidden); rejected != tt.wantRejected {
+			t.Errorf("CheckConfigIPAccess(allow %v, deny %v) error = %v, want rejected %v", tt.allowlist, tt.denylist, err, tt.wantRejected)
+		}
+		_, err = cacheClient.Get(context.Background(), IPRejectedCounterKey(config.ID))
+		if counted := err == nil; counted != tt.wantCounted {
+			t.Errorf("CheckConfigIPAccess(allow %v, deny %v) counted = %v, want %v", tt.allowlist, tt.denylist, counted, tt.wantCounted)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"api-key-rotator/backend/internal/logger"
+	"api-key-rotator/backend/internal/models"
+)
+
+// 上游密钥注入位置
+const (
+	KeyLocationHeader = "header" // 写入请求头
+	KeyLocationQuery  = "query"  // 写入查询参数
+	KeyLocationBasic  = "basic"  // 作为 HTTP Basic 认证，模板渲染结果为 "user:password"
+	KeyLocationBody   = "body"   // 写入JSON请求体字段，支持 a.b.c 形式的嵌套路径
+	KeyLocationPath   = "path"   // 替换目标URL中的 {key} 占位符
+)
+
+// KeyPlaceholder 模板中代表上游密钥的占位符
+const KeyPlaceholder = "{key}"
+
+// CredentialInjection 描述上游密钥注入到请求中的方式
+type CredentialInjection struct {
+	Location string // 注入位置，见 KeyLocation* 常量
+	Name     string // 请求头名、查询参数名或JSON字段路径
+	Template string // 值模板，如 "Bearer {key}"、"Token token={key}"
+}
+
+// ResolveCredentialInjection 合并配置中的注入规则和调用方提供的默认值
+// 只有位置与默认位置一致时才沿用默认模板，否则使用该位置的通用模板
+func ResolveCredentialInjection(proxyConfig *models.ProxyConfig, defaults CredentialInjection) CredentialInjection {
+	injection := defaults
+
+	if proxyConfig.APIKeyLocation != nil && *proxyConfig.APIKeyLocation != "" {
+		injection.Location = strings.ToLower(*proxyConfig.APIKeyLocation)
+	}
+	if proxyConfig.APIKeyName != nil && *proxyConfig.APIKeyName != "" {
+		injection.Name = *proxyConfig.APIKeyName
+	}
+
+	switch {
+	case proxyConfig.APIKeyTemplate != nil && *proxyConfig.APIKeyTemplate != "":
+		injection.Template = *proxyConfig.APIKeyTemplate
+	case injection.Location != defaults.Location || injection.Template == "":
+		injection.Template = defaultKeyTemplate(injection.Location)
+	}
+
+	return injection
+}
+
+// ValidateCredentialInjection 校验配置中的注入规则，供保存配置时使用
+func ValidateCredentialInjection(proxyConfig *models.ProxyConfig) error {
+	if proxyConfig.APIKeyLocation == nil || *proxyConfig.APIKeyLocation == "" {
+		return nil
+	}
+
+	location := strings.ToLower(*proxyConfig.APIKeyLocation)
+	name := ""
+	if proxyConfig.APIKeyName != nil {
+		name = *proxyConfig.APIKeyName
+	}
+
+	switch location {
+	case KeyLocationHeader, KeyLocationQuery, KeyLocationBody:
+		if name == "" && strings.ToUpper(proxyConfig.ConfigType) != "LLM" {
+			return fmt.Errorf("api_key_name is required for api_key_location '%s'", location)
+		}
+	case KeyLocationBasic, KeyLocationPath:
+	default:
+		return fmt.Errorf("unsupported api_key_location '%s'", location)
+	}
+
+	if proxyConfig.APIKeyTemplate != nil && *proxyConfig.APIKeyTemplate != "" &&
+		!strings.Contains(*proxyConfig.APIKeyTemplate, KeyPlaceholder) {
+		return fmt.Errorf("api_key_template must contain the %s placeholder", KeyPlaceholder)
+	}
+
+	if location == KeyLocationPath {
+		targetURL := proxyConfig.TargetURL
+		if strings.ToUpper(proxyConfig.ConfigType) == "LLM" {
+			targetURL = proxyConfig.TargetBaseURL
+		}
+		if targetURL == nil || !strings.Contains(*targetURL, KeyPlaceholder) {
+			return fmt.Errorf("target URL must contain the %s placeholder when api_key_location is 'path'", KeyPlaceholder)
+		}
+	}
+
+	return nil
+}
+
+// RenderKeyTemplate 用密钥替换模板中的 {key} 占位符
+func RenderKeyTemplate(template, key string) string {
+	if template == "" {
+		return key
+	}
+	return strings.ReplaceAll(template, KeyPlaceholder, key)
+}
+
+// Apply 将上游密钥按注入规则写入目标请求，未配置注入位置时不做任何处理
+// 早期保存的配置可能只有位置没有名称，此时与旧版本一样跳过注入，名称只在保存配置时强制要求
+func (i CredentialInjection) Apply(target *TargetRequest, key string) error {
+	value := RenderKeyTemplate(i.Template, key)
+
+	switch i.Location {
+	case "":
+		return nil
+	case KeyLocationHeader, KeyLocationQuery, KeyLocationBody:
+		if i.Name == "" {
+			logger.Warningf("api_key_location '%s' has no api_key_name, skipping API key injection", i.Location)
+			return nil
+		}
+	}
+
+	switch i.Location {
+	case KeyLocationHeader:
+		target.Headers.Set(i.Name, value)
+	case KeyLocationQuery:
+		target.Params.Set(i.Name, value)
+	case KeyLocationBasic:
+		target.Headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+	case KeyLocationBody:
+		body, err := setJSONField(target.Body, i.Name, value)
+		if err != nil {
+			return fmt.Errorf("failed to inject API key into request body: %w", err)
+		}
+		target.Body = body
+	case KeyLocationPath:
+		if !strings.Contains(target.URL, KeyPlaceholder) {
+			return fmt.Errorf("target URL has no %s placeholder for the API key", KeyPlaceholder)
+		}
+		target.URL = strings.ReplaceAll(target.URL, KeyPlaceholder, url.PathEscape(value))
+	default:
+		return fmt.Errorf("unsupported API key location '%s'", i.Location)
+	}
+
+	return nil
+}
+
+// Reapply 将已注入的 oldKey 替换为 newKey；路径中的占位符在首次注入时已被替换，
+// 因此按转义后的旧值查找
+func (i CredentialInjection) Reapply(target *TargetRequest, oldKey, newKey string) error {
+	if i.Location != KeyLocationPath {
+		return i.Apply(target, newKey)
+	}
+	oldValue := url.PathEscape(RenderKeyTemplate(i.Template, oldKey))
+	target.URL = strings.ReplaceAll(target.URL, oldValue, url.PathEscape(RenderKeyTemplate(i.Template, newKey)))
+	return nil
+}
+
+// defaultKeyTemplate 返回各注入位置的默认模板
+func defaultKeyTemplate(location string) string {
+	if location == KeyLocationBasic {
+		// 默认把密钥作为用户名、密码留空
+		return KeyPlaceholder + ":"
+	}
+	return KeyPlaceholder
+}
+
+// setJSONField 在JSON对象中按 a.b.c 路径设置字符串字段，中间对象不存在时自动创建
+// 直接修改原始字节，其余字段的顺序、格式和数字精度保持不变
+func setJSONField(body []byte, path, value string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("JSON field name is required")
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	segments := strings.Split(path, ".")
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nestedJSONField(segments, encoded), nil
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("request body is not a JSON object")
+	}
+	return patchJSONObject(body, segments, encoded)
+}
+
+// patchJSONObject 在 object 中把 segments 指向的字段设为 value，object 必须是合法的JSON
+// 字段已存在时替换其值（重复的键以最后一个为准），否则追加到最后一个字段之后
+func patchJSONObject(object []byte, segments []string, value []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("request body is not a JSON object")
+	}
+
+	insertAt := int(decoder.InputOffset())
+	separator := ""
+	start, end := -1, -1
+	var existing json.RawMessage
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		offset := int(decoder.InputOffset())
+		if token == segments[0] {
+			start, end, existing = offset-len(raw), offset, raw
+		}
+		insertAt, separator = offset, ","
+	}
+
+	var replacement []byte
+	switch {
+	case start < 0:
+		key, _ := json.Marshal(segments[0])
+		field := append(append([]byte(separator), key...), ':')
+		field = append(field, nestedJSONField(segments[1:], value)...)
+		return splice(object, insertAt, insertAt, field), nil
+	case len(segments) > 1 && bytes.HasPrefix(existing, []byte("{")):
+		patched, err := patchJSONObject(existing, segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		replacement = patched
+	default:
+		replacement = nestedJSONField(segments[1:], value)
+	}
+	return splice(object, start, end, replacement), nil
+}
+
+// nestedJSONField 按剩余路径构造嵌套对象，路径为空时返回值本身
+func nestedJSONField(segments []string, value []byte) []byte {
+	for i := len(segments) - 1; i >= 0; i-- {
+		key, _ := json.Marshal(segments[i])
+		field := append([]byte("{"), key...)
+		field = append(field, ':')
+		field = append(field, value...)
+		value = append(field, '}')
+	}
+	return value
+}
+
+// splice 用 replacement 替换 data[start:end]，返回新的切片
+func splice(data []byte, start, end int, replacement []byte) []byte {
+	result := make([]byte, 0, len(data)-(end-start)+len(replacement))
+	result = append(result, data[:start]...)
+	result = append(result, replacement...)
+	return append(result, data[end:]...)
+}
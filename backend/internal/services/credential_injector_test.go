@@ -0,0 +1,99 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestCredentialInjectionApply(t *testing.T) {
+	tests := []struct {
+		injection CredentialInjection
+		url       string
+		body      string
+		want      string // 注入后的请求，按位置取头部、查询参数、请求体或URL
+		wantErr   bool
+	}{
+		{CredentialInjection{Location: KeyLocationHeader, Name: "Authorization", Template: "Token token={key}"}, "", "", "Token token=sk-1", false},
+		{CredentialInjection{Location: KeyLocationQuery, Name: "api_key", Template: KeyPlaceholder}, "", "", "sk-1", false},
+		{CredentialInjection{Location: KeyLocationBasic, Template: defaultKeyTemplate(KeyLocationBasic)}, "", "", "Basic c2stMTo=", false},
+		{CredentialInjection{Location: KeyLocationBody, Name: "auth.token", Template: KeyPlaceholder}, "", `{"auth":{"user":"u"},"q":1}`, `{"auth":{"user":"u","token":"sk-1"},"q":1}`, false},
+		{CredentialInjection{Location: KeyLocationBody, Name: "key", Template: KeyPlaceholder}, "", "{\n  \"id\": 12345678901234567890,\n  \"key\": \"old\"\n}", "{\n  \"id\": 12345678901234567890,\n  \"key\": \"sk-1\"\n}", false},
+		{CredentialInjection{Location: KeyLocationBody, Name: "a.b", Template: KeyPlaceholder}, "", `{"z":1.50}`, `{"z":1.50,"a":{"b":"sk-1"}}`, false},
+		{CredentialInjection{Location: KeyLocationBody, Name: "a.b", Template: KeyPlaceholder}, "", `{}`, `{"a":{"b":"sk-1"}}`, false},
+		{CredentialInjection{Location: KeyLocationPath, Template: "{key}/x"}, "https://example.com/bot{key}/send", "", "https://example.com/botsk-1%2Fx/send", false},
+		{CredentialInjection{Location: KeyLocationHeader}, "", "", "", false}, // 早期配置缺少名称时跳过注入
+		{CredentialInjection{Location: KeyLocationBody, Name: "key"}, "", `[1]`, "", true},
+		{CredentialInjection{Location: KeyLocationPath}, "https://example.com/v1", "", "", true},
+	}
+	for _, tt := range tests {
+		target := &TargetRequest{URL: tt.url, Headers: http.Header{}, Params: url.Values{}, Body: []byte(tt.body)}
+		err := tt.injection.Apply(target, "sk-1")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Apply(%+v) error = %v, wantErr %v", tt.injection, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+
+		var got string
+		switch tt.injection.Location {
+		case KeyLocationHeader, KeyLocationBasic:
+			got = target.Headers.Get("Authorization")
+		case KeyLocationQuery:
+			got = target.Params.Get(tt.injection.Name)
+		case KeyLocationBody:
+			got = string(target.Body)
+		case KeyLocationPath:
+			got = target.URL
+		}
+		if got != tt.want {
+			t.Errorf("Apply(%+v) = %q, want %q", tt.injection, got, tt.want)
+		}
+	}
+}
+
+func TestResolveCredentialInjection(t *testing.T) {
+	defaults := CredentialInjection{Location: KeyLocationHeader, Name: "Authorization", Template: "Bearer {key}"}
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		config models.ProxyConfig
+		want   CredentialInjection
+	}{
+		{models.ProxyConfig{}, defaults},
+		{models.ProxyConfig{APIKeyName: str("X-Auth")}, CredentialInjection{Location: KeyLocationHeader, Name: "X-Auth", Template: "Bearer {key}"}},
+		{models.ProxyConfig{APIKeyLocation: str("QUERY"), APIKeyName: str("key")}, CredentialInjection{Location: KeyLocationQuery, Name: "key", Template: KeyPlaceholder}},
+		{models.ProxyConfig{APIKeyLocation: str("basic"), APIKeyTemplate: str("user:{key}")}, CredentialInjection{Location: KeyLocationBasic, Name: "Authorization", Template: "user:{key}"}},
+	}
+	for _, tt := range tests {
+		if got := ResolveCredentialInjection(&tt.config, defaults); got != tt.want {
+			t.Errorf("ResolveCredentialInjection(%+v) = %+v, want %+v", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCredentialInjection(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		config  models.ProxyConfig
+		wantErr bool
+	}{
+		{models.ProxyConfig{}, false},
+		{models.ProxyConfig{ConfigType: "generic", APIKeyLocation: str("header")}, true},
+		{models.ProxyConfig{ConfigType: "LLM", APIKeyLocation: str("header")}, false},
+		{models.ProxyConfig{APIKeyLocation: str("cookie")}, true},
+		{models.ProxyConfig{APIKeyLocation: str("basic"), APIKeyTemplate: str("user:pass")}, true},
+		{models.ProxyConfig{APIKeyLocation: str("path"), TargetURL: str("https://example.com")}, true},
+		{models.ProxyConfig{APIKeyLocation: str("path"), TargetURL: str("https://example.com/{key}")}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateCredentialInjection(&tt.config); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateCredentialInjection(%+v) error = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+)
+
+// credentialSecrets 返回结构化凭证中需要加密存储的字段
+func credentialSecrets(creds *models.KeyCredentials) []*string {
+	return []*string{&creds.SecretAccessKey, &creds.SessionToken, &creds.ClientSecret}
+}
+
+// PrepareKeyCredentials 加密将要保存的结构化凭证中的密钥字段，已加密的字段保持不变
+// 调用前应先用明文完成 ValidateAPIKey 校验
+func PrepareKeyCredentials(cfg *config.Config, creds *models.KeyCredentials) (*models.KeyCredentials, error) {
+	if creds == nil {
+		return nil, nil
+	}
+
+	prepared := *creds
+	for _, field := range credentialSecrets(&prepared) {
+		if *field == "" || utils.IsEncrypted(*field) {
+			continue
+		}
+		encrypted, err := utils.EncryptSecret(*field, cfg.GetEncryptionKey())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt key credentials: %w", err)
+		}
+		*field = encrypted
+	}
+	return &prepared, nil
+}
+
+// decryptKeyCredentials 返回密钥字段已解密的结构化凭证副本，兼容加密前保存的明文凭证
+func decryptKeyCredentials(cfg *config.Config, creds *models.KeyCredentials) (*models.KeyCredentials, error) {
+	if creds == nil {
+		return nil, nil
+	}
+
+	decrypted := *creds
+	for _, field := range credentialSecrets(&decrypted) {
+		if !utils.IsEncrypted(*field) {
+			continue
+		}
+		plain, err := utils.DecryptSecret(*field, cfg.GetEncryptionKey())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key credentials: %w", err)
+		}
+		*field = plain
+	}
+	return &decrypted, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+)
+
+func TestKeyCredentialsEncryption(t *testing.T) {
+	cfg := &config.Config{EncryptionKey: "test-encryption-key"}
+	creds := &models.KeyCredentials{
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "aws-secret",
+		SessionToken:    "aws-session",
+		ClientID:        "client",
+		ClientSecret:    "client-secret",
+	}
+
+	prepared, err := PrepareKeyCredentials(cfg, creds)
+	if err != nil {
+		t.Fatalf("PrepareKeyCredentials() error = %v", err)
+	}
+	if creds.SecretAccessKey != "aws-secret" {
+		t.Error("PrepareKeyCredentials() modified its input")
+	}
+	for name, value := range map[string]string{
+		"secret_access_key": prepared.SecretAccessKey,
+		"session_token":     prepared.SessionToken,
+		"client_secret":     prepared.ClientSecret,
+	} {
+		if !utils.IsEncrypted(value) {
+			t.Errorf("%s was stored in plaintext: %q", name, value)
+		}
+	}
+	if prepared.AccessKeyID != "AKID" || prepared.ClientID != "client" {
+		t.Error("identifiers should stay in plaintext")
+	}
+
+	// 再次保存时不重复加密
+	again, err := PrepareKeyCredentials(cfg, prepared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ClientSecret != prepared.ClientSecret {
+		t.Error("encrypted fields were encrypted twice")
+	}
+
+	decrypted, err := decryptKeyCredentials(cfg, again)
+	if err != nil {
+		t.Fatalf("decryptKeyCredentials() error = %v", err)
+	}
+	if *decrypted != *creds {
+		t.Errorf("decryptKeyCredentials() = %+v, want %+v", *decrypted, *creds)
+	}
+
+	// 加密前保存的明文凭证仍然可用
+	plain, err := decryptKeyCredentials(cfg, creds)
+	if err != nil || *plain != *creds {
+		t.Errorf("decryptKeyCredentials(plaintext) = %+v, %v", plain, err)
+	}
+
+	if _, err := decryptKeyCredentials(&config.Config{EncryptionKey: "other-key"}, prepared); err == nil {
+		t.Error("decryptKeyCredentials() with a different key should fail")
+	}
+}
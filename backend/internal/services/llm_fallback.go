@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"api-key-rotator/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ShouldFallBack 判断上游响应状态码是否应切换到下一个回退服务
+func ShouldFallBack(statusCode int) bool {
+	return statusCode >= 500
+}
+
+// ValidateFallbackSlugs 校验回退服务列表: 只有LLM配置可以设置，目标必须是其他已存在的LLM配置且不能重复
+func ValidateFallbackSlugs(db *gorm.DB, proxyConfig *models.ProxyConfig) error {
+	if len(proxyConfig.FallbackSlugs) == 0 {
+		return nil
+	}
+	if !strings.EqualFold(proxyConfig.ConfigType, "LLM") {
+		return fmt.Errorf("fallback_slugs is only supported for LLM configurations")
+	}
+
+	seen := make(map[string]bool)
+	for i, slug := range proxyConfig.FallbackSlugs {
+		if slug == "" {
+			return fmt.Errorf("fallback_slugs[%d]: slug is required", i)
+		}
+		if slug == proxyConfig.Slug {
+			return fmt.Errorf("fallback_slugs[%d]: a configuration cannot fall back to itself", i)
+		}
+		if seen[slug] {
+			return fmt.Errorf("fallback_slugs[%d]: duplicate slug '%s'", i, slug)
+		}
+		seen[slug] = true
+
+		var count int64
+		if err := db.Model(&models.ProxyConfig{}).Where("slug = ? AND config_type = ?", slug, "LLM").Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("fallback_slugs[%d]: LLM service configuration with slug '%s' not found", i, slug)
+		}
+	}
+	return nil
+}
+
+// NormalizeFallbackSlugs 去除回退服务列表中的首尾空白
+func NormalizeFallbackSlugs(slugs []string) []string {
+	for i := range slugs {
+		slugs[i] = strings.TrimSpace(slugs[i])
+	}
+	return slugs
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+
+	"api-key-rotator/backend/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.ProxyConfig{}, &models.APIKey{}, &models.ModelRoute{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestValidateFallbackSlugs(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&models.ProxyConfig{Name: "openai", Slug: "openai", ConfigType: "LLM", IsActive: true})
+	db.Create(&models.ProxyConfig{Name: "anthropic", Slug: "anthropic", ConfigType: "LLM", IsActive: true})
+	db.Create(&models.ProxyConfig{Name: "generic", Slug: "generic", ConfigType: "GENERIC", IsActive: true})
+
+	tests := []struct {
+		configType string
+		slugs      []string
+		wantErr    bool
+	}{
+		{"GENERIC", nil, false},
+		{"LLM", []string{"anthropic", "openai"}, false},
+		{"GENERIC", []string{"anthropic"}, true},
+		{"LLM", []string{""}, true},
+		{"LLM", []string{"primary"}, true}, // 不能回退到自身
+		{"LLM", []string{"anthropic", "anthropic"}, true},
+		{"LLM", []string{"missing"}, true},
+		{"LLM", []string{"generic"}, true},
+	}
+	for _, tt := range tests {
+		cfg := &models.ProxyConfig{Slug: "primary", ConfigType: tt.configType, FallbackSlugs: tt.slugs}
+		if err := ValidateFallbackSlugs(db, cfg); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateFallbackSlugs(%s %v) error = %v, wantErr %v", tt.configType, tt.slugs, err, tt.wantErr)
+		}
+	}
+}
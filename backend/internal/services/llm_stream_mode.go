@@ -0,0 +1,110 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+// 上游流式模式
+const (
+	StreamModeStream    = "stream"     // 上游只支持流式，非流式请求也以流式发送
+	StreamModeNonStream = "non_stream" // 上游不支持流式，流式请求以非流式发送
+)
+
+// StreamBridgeContextKey gin 上下文中保存流式桥接方式的键
+const StreamBridgeContextKey = "stream_bridge"
+
+// 客户端请求的方式与上游不同时的桥接方式
+const (
+	StreamBridgeAggregate  = "aggregate"  // 上游的流式响应聚合为完整响应
+	StreamBridgeSynthesize = "synthesize" // 上游的完整响应合成为流式响应
+)
+
+// UpstreamStreamMode 返回配置要求的上游流式模式，ok 为 false 时按客户端的请求转发
+func UpstreamStreamMode(proxyConfig *models.ProxyConfig) (stream bool, ok bool) {
+	if proxyConfig.StreamMode == nil {
+		return false, false
+	}
+	switch *proxyConfig.StreamMode {
+	case StreamModeStream:
+		return true, true
+	case StreamModeNonStream:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// ValidateStreamMode 校验上游流式模式: 只有LLM配置可以设置
+func ValidateStreamMode(proxyConfig *models.ProxyConfig) error {
+	if proxyConfig.StreamMode == nil || *proxyConfig.StreamMode == "" {
+		return nil
+	}
+	if !strings.EqualFold(proxyConfig.ConfigType, "LLM") {
+		return fmt.Errorf("stream_mode is only supported for LLM configurations")
+	}
+	switch *proxyConfig.StreamMode {
+	case StreamModeStream, StreamModeNonStream:
+		return nil
+	default:
+		return fmt.Errorf("stream_mode must be '%s' or '%s'", StreamModeStream, StreamModeNonStream)
+	}
+}
+
+// StreamUsageInjectedContextKey gin 上下文中标记代理为流式请求注入了 include_usage 的键，
+// 客户端未请求用量时，上游额外返回的用量数据块不发给客户端
+const StreamUsageInjectedContextKey = "stream_usage_injected"
+
+// InjectStreamUsage 为 OpenAI 格式的流式 chat/completions 请求设置 stream_options.include_usage，
+// 上游在流的最后返回一个带用量的数据块；injected 表示客户端原本没有请求用量
+// 其他接口、非流式请求和无法解析的请求体原样返回
+func InjectStreamUsage(action string, body []byte) (result []byte, injected bool) {
+	if !strings.HasSuffix(strings.TrimSuffix(action, "/"), "chat/completions") {
+		return body, false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return body, false
+	}
+	var stream bool
+	if err := json.Unmarshal(fields["stream"], &stream); err != nil || !stream {
+		return body, false
+	}
+
+	options := make(map[string]json.RawMessage)
+	if raw, ok := fields["stream_options"]; ok {
+		if err := json.Unmarshal(raw, &options); err != nil || options == nil {
+			options = make(map[string]json.RawMessage)
+		}
+	}
+	var includeUsage bool
+	if err := json.Unmarshal(options["include_usage"], &includeUsage); err == nil && includeUsage {
+		return body, false
+	}
+	options["include_usage"] = json.RawMessage("true")
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return body, false
+	}
+	fields["stream_options"] = encoded
+
+	if injected, err := json.Marshal(fields); err == nil {
+		return injected, true
+	}
+	return body, false
+}
+
+// IsStreamUsageChunk 判断 OpenAI 格式的流式数据块是否为 include_usage 产生的用量数据块（choices 为空，只有用量）
+func IsStreamUsageChunk(data []byte) bool {
+	var chunk struct {
+		Choices []json.RawMessage `json:"choices"`
+		Usage   json.RawMessage   `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return false
+	}
+	return len(chunk.Choices) == 0 && len(chunk.Usage) > 0 && string(chunk.Usage) != "null"
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestValidateStreamMode(t *testing.T) {
+	mode := func(s string) *string { return &s }
+	tests := []struct {
+		configType string
+		mode       *string
+		wantStream bool
+		wantOK     bool
+		wantErr    bool
+	}{
+		{"LLM", nil, false, false, false},
+		{"LLM", mode(StreamModeStream), true, true, false},
+		{"llm", mode(StreamModeNonStream), false, true, false},
+		{"GENERIC", mode(StreamModeStream), true, true, true},
+		{"LLM", mode("sse"), false, false, true},
+	}
+	for _, tt := range tests {
+		proxyConfig := &models.ProxyConfig{ConfigType: tt.configType, StreamMode: tt.mode}
+		if err := ValidateStreamMode(proxyConfig); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateStreamMode(%s %v) error = %v, wantErr %v", tt.configType, tt.mode, err, tt.wantErr)
+		}
+		if stream, ok := UpstreamStreamMode(proxyConfig); stream != tt.wantStream || ok != tt.wantOK {
+			t.Errorf("UpstreamStreamMode(%v) = %v, %v, want %v, %v", tt.mode, stream, ok, tt.wantStream, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"api-key-rotator/backend/internal/infrastructure/cache"
+	"api-key-rotator/backend/internal/logger"
+)
+
+// LLMUsageContextKey gin 上下文中保存本次请求上游返回的 token 用量的键
+const LLMUsageContextKey = "llm_usage"
+
+// LLMUsageCounterKey 返回指定配置的 token 用量统计缓存键，field 为 requests、input_tokens、output_tokens 或 total_tokens
+func LLMUsageCounterKey(configID int32, field string) string {
+	return fmt.Sprintf("proxy_config:%d:llm_usage:%s", configID, field)
+}
+
+// RecordLLMUsage 累加配置的 token 用量统计，requests 为返回了用量的请求数；计数失败只记录日志
+func RecordLLMUsage(ctx context.Context, cacheClient cache.CacheInterface, configID int32, inputTokens, outputTokens, totalTokens int) {
+	counters := []struct {
+		field string
+		delta int64
+	}{
+		{"requests", 1},
+		{"input_tokens", int64(inputTokens)},
+		{"output_tokens", int64(outputTokens)},
+		{"total_tokens", int64(totalTokens)},
+	}
+	for _, counter := range counters {
+		if counter.delta == 0 {
+			continue
+		}
+		key := LLMUsageCounterKey(configID, counter.field)
+		if _, err := cacheClient.IncrBy(ctx, key, counter.delta); err != nil {
+			logger.Errorf("Failed to record LLM usage counter %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"api-key-rotator/backend/internal/infrastructure/cache/memory"
+)
+
+func TestInjectStreamUsage(t *testing.T) {
+	tests := []struct {
+		name         string
+		action       string
+		body         string
+		want         string // 结果必须包含的内容，为空时请求体应原样返回
+		wantInjected bool
+	}{
+		{
+			name:         "streaming chat completion",
+			action:       "v1/chat/completions",
+			body:         `{"model":"m","stream":true}`,
+			want:         `"stream_options":{"include_usage":true}`,
+			wantInjected: true,
+		},
+		{
+			name:         "other stream options are kept",
+			action:       "chat/completions/",
+			body:         `{"model":"m","stream":true,"stream_options":{"include_obfuscation":false}}`,
+			want:         `"include_usage":true`,
+			wantInjected: true,
+		},
+		{
+			name:   "client already asked for usage",
+			action: "v1/chat/completions",
+			body:   `{"model":"m","stream":true,"stream_options":{"include_usage":true}}`,
+		},
+		{
+			name:   "non-streaming request",
+			action: "v1/chat/completions",
+			body:   `{"model":"m","stream":false}`,
+		},
+		{
+			name:   "other endpoints are left alone",
+			action: "v1/completions",
+			body:   `{"model":"m","stream":true}`,
+		},
+		{
+			name:   "invalid body",
+			action: "v1/chat/completions",
+			body:   `not json`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, injected := InjectStreamUsage(tt.action, []byte(tt.body))
+			if injected != tt.wantInjected {
+				t.Errorf("InjectStreamUsage() injected = %v, want %v", injected, tt.wantInjected)
+			}
+			if tt.want == "" {
+				if string(got) != tt.body {
+					t.Errorf("InjectStreamUsage() = %s, want the body unchanged", got)
+				}
+				return
+			}
+			if !strings.Contains(string(got), tt.want) {
+				t.Errorf("InjectStreamUsage() = %s, want it to contain %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsStreamUsageChunk(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{`{"id":"c","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":5,"total_tokens":8}}`, true},
+		{`{"id":"c","choices":[{"index":0,"delta":{"content":"hi"}}],"usage":null}`, false},
+		{`{"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"total_tokens":8}}`, false},
+		{`{"id":"c","choices":[]}`, false},
+		{`[DONE]`, false},
+	}
+	for _, tt := range tests {
+		if got := IsStreamUsageChunk([]byte(tt.data)); got != tt.want {
+			t.Errorf("IsStreamUsageChunk(%s) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestRecordLLMUsage(t *testing.T) {
+	ctx := context.Background()
+	cacheClient := memory.NewMemoryCache()
+
+	RecordLLMUsage(ctx, cacheClient, 7, 10, 20, 30)
+	RecordLLMUsage(ctx, cacheClient, 7, 1, 0, 1)
+
+	want := map[string]string{"requests": "2", "input_tokens": "11", "output_tokens": "20", "total_tokens": "31"}
+	for field, value := range want {
+		got, err := cacheClient.Get(ctx, LLMUsageCounterKey(7, field))
+		if err != nil || got != value {
+			t.Errorf("%s = %q (err %v), want %s", field, got, err, value)
+		}
+	}
+	if _, err := cacheClient.Get(ctx, LLMUsageCounterKey(8, "requests")); err == nil {
+		t.Error("usage of another config should not be recorded")
+	}
+}
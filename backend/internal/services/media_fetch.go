@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+)
+
+// FetchRemoteMedia 下载格式转换时需要内联的远程媒体（图片、文档、音频），返回媒体类型和内容
+// 请求遵循出站策略并沿用配置的超时和出口代理，超过 MEDIA_INLINE_MAX_SIZE_MB 时返回错误
+func FetchRemoteMedia(ctx context.Context, cfg *config.Config, proxyConfig *models.ProxyConfig, rawURL string) (string, []byte, error) {
+	options, err := ResolveUpstreamOptions(cfg, proxyConfig, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	// 媒体地址不是上游服务，不使用上游的TLS设置
+	options.TLS = nil
+	if options.TotalTimeout == 0 {
+		options.TotalTimeout = time.Duration(cfg.ProxyTimeout) * time.Second
+	}
+
+	resp, err := SendUpstream(ctx, &TargetRequest{Method: http.MethodGet, URL: rawURL, Upstream: options})
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("remote media returned status %d", resp.StatusCode)
+	}
+
+	maxBytes := int64(cfg.MediaInlineMaxSizeMB) << 20
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read remote media: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return "", nil, fmt.Errorf("remote media exceeds %d MB", cfg.MediaInlineMaxSizeMB)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+	return mediaType, data, nil
+}
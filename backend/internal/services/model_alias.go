@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+// ErrUnknownModel 请求的模型不在配置的别名表中
+var ErrUnknownModel = errors.New("model is not available")
+
+// ModelMappingContextKey gin 上下文中保存模型映射的键
+const ModelMappingContextKey = "model_mapping"
+
+// ModelMapping 一次请求中客户端请求的模型名与实际发送给上游的模型名
+type ModelMapping struct {
+	Requested string
+	Upstream  string
+}
+
+// ResolveModelAlias 按别名表解析上游模型名: 先精确匹配，再按顺序匹配通配符
+// 未命中时原样返回；配置了拒绝未知模型时，只允许别名表中出现过的模型
+func ResolveModelAlias(proxyConfig *models.ProxyConfig, model string) (string, error) {
+	for _, alias := range proxyConfig.ModelAliases {
+		if alias.Alias == model {
+			return alias.Model, nil
+		}
+	}
+	for _, alias := range proxyConfig.ModelAliases {
+		if strings.ContainsAny(alias.Alias, "*?") && matchModelPattern(alias.Alias, model) {
+			return alias.Model, nil
+		}
+	}
+
+	if proxyConfig.RejectUnknownModels {
+		for _, alias := range proxyConfig.ModelAliases {
+			if alias.Model == model {
+				return model, nil
+			}
+		}
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownModel, model)
+	}
+	return model, nil
+}
+
+// matchModelPattern 通配符匹配模型名，"*" 匹配任意字符串（包括 "/"），"?" 匹配单个字符
+func matchModelPattern(pattern, model string) bool {
+	if pattern == "" {
+		return model == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(model); i++ {
+			if matchModelPattern(pattern[1:], model[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return model != "" && matchModelPattern(pattern[1:], model[1:])
+	default:
+		return model != "" && pattern[0] == model[0] && matchModelPattern(pattern[1:], model[1:])
+	}
+}
+
+// ReplaceRequestModel 替换JSON请求体中的 model 字段，请求体不是JSON对象时原样返回
+func ReplaceRequestModel(body []byte, model string) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	if _, ok := fields["model"]; !ok {
+		return body
+	}
+	encoded, _ := json.Marshal(model)
+	fields["model"] = encoded
+	if replaced, err := json.Marshal(fields); err == nil {
+		return replaced
+	}
+	return body
+}
+
+// RestoreResponseModel 将响应或流式数据块中的模型名还原为客户端请求的名称
+// 覆盖 OpenAI/Anthropic 的 model、Gemini 的 modelVersion，以及 Anthropic message_start 和
+// Responses API 事件中嵌套的 message/response 对象；JSON 数组（Gemini 非SSE流）逐个处理
+func RestoreResponseModel(payload []byte, model string) []byte {
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "[") {
+		var items []json.RawMessage
+		if err := json.Unmarshal(payload, &items); err != nil {
+			return payload
+		}
+		for i, item := range items {
+			items[i] = RestoreResponseModel(item, model)
+		}
+		if restored, err := json.Marshal(items); err == nil {
+			return restored
+		}
+		return payload
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+	if !restoreModelFields(fields, model) {
+		return payload
+	}
+	if restored, err := json.Marshal(fields); err == nil {
+		return restored
+	}
+	return payload
+}
+
+// restoreModelFields 替换对象及其 message/response 子对象中的模型字段，返回是否有修改
+func restoreModelFields(fields map[string]json.RawMessage, model string) bool {
+	encoded, _ := json.Marshal(model)
+	changed := false
+	for _, key := range []string{"model", "modelVersion"} {
+		if value, ok := fields[key]; ok && len(value) > 0 && value[0] == '"' {
+			fields[key] = encoded
+			changed = true
+		}
+	}
+	for _, key := range []string{"message", "response"} {
+		var nested map[string]json.RawMessage
+		if err := json.Unmarshal(fields[key], &nested); err != nil || nested == nil {
+			continue
+		}
+		if restoreModelFields(nested, model) {
+			if value, err := json.Marshal(nested); err == nil {
+				fields[key] = value
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// ReplaceActionModel 替换 Gemini 风格路径 "models/<model>:<method>" 中的模型名，返回新路径和原模型名
+func ReplaceActionModel(action string, resolve func(string) (string, error)) (string, string, error) {
+	start := strings.Index(action, "models/")
+	if start < 0 {
+		return action, "", nil
+	}
+	start += len("models/")
+	end := strings.Index(action[start:], ":")
+	if end < 0 {
+		return action, "", nil
+	}
+	end += start
+
+	requested := action[start:end]
+	upstream, err := resolve(requested)
+	if err != nil {
+		return "", requested, err
+	}
+	return action[:start] + upstream + action[end:], requested, nil
+}
+
+// ValidateModelAliases 校验模型别名表，供保存配置时使用
+func ValidateModelAliases(proxyConfig *models.ProxyConfig) error {
+	for i, alias := range proxyConfig.ModelAliases {
+		if alias.Alias == "" || alias.Model == "" {
+			return fmt.Errorf("model_aliases[%d]: alias and model are required", i)
+		}
+	}
+	if proxyConfig.RejectUnknownModels && len(proxyConfig.ModelAliases) == 0 {
+		return fmt.Errorf("reject_unknown_models requires at least one model alias")
+	}
+	return nil
+}
+
+// NormalizeModelAliases 去除别名表中的首尾空白
+func NormalizeModelAliases(aliases []models.ModelAlias) []models.ModelAlias {
+	for i := range aliases {
+		aliases[i].Alias = strings.TrimSpace(aliases[i].Alias)
+		aliases[i].Model = strings.TrimSpace(aliases[i].Model)
+	}
+	return aliases
+}
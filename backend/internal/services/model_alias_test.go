@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestResolveModelAlias(t *testing.T) {
+	aliases := []models.ModelAlias{
+		{Alias: "gpt-4*", Model: "wildcard-model"},
+		{Alias: "gpt-4o", Model: "exact-model"},
+		{Alias: "claude-?", Model: "claude-3-5-sonnet"},
+	}
+
+	tests := []struct {
+		reject  bool
+		model   string
+		want    string
+		wantErr error
+	}{
+		{false, "gpt-4o", "exact-model", nil}, // 精确匹配优先于前面的通配符
+		{false, "gpt-4-turbo", "wildcard-model", nil},
+		{false, "claude-x", "claude-3-5-sonnet", nil},
+		{false, "claude-xy", "claude-xy", nil},
+		{false, "mistral", "mistral", nil},
+		{true, "mistral", "", ErrUnknownModel},
+		{true, "exact-model", "exact-model", nil}, // 上游模型名本身不会被拒绝
+	}
+	for _, tt := range tests {
+		proxyConfig := &models.ProxyConfig{ModelAliases: aliases, RejectUnknownModels: tt.reject}
+		got, err := ResolveModelAlias(proxyConfig, tt.model)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("ResolveModelAlias(%q, reject %v) = %q, %v, want %q, %v", tt.model, tt.reject, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRestoreResponseModel(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"id":"c","model":"upstream"}`, `{"id":"c","model":"alias"}`},
+		{`{"candidates":[],"modelVersion":"upstream"}`, `{"candidates":[],"modelVersion":"alias"}`},
+		{`{"message":{"model":"upstream"},"type":"message_start"}`, `{"message":{"model":"alias"},"type":"message_start"}`},
+		{`{"model":null}`, `{"model":null}`},
+		{`[DONE]`, `[DONE]`},
+	}
+	for _, tt := range tests {
+		if got := string(RestoreResponseModel([]byte(tt.payload), "alias")); got != tt.want {
+			t.Errorf("RestoreResponseModel(%s) = %s, want %s", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceActionModel(t *testing.T) {
+	resolve := func(model string) (string, error) {
+		if model == "blocked" {
+			return "", ErrUnknownModel
+		}
+		return "upstream-" + model, nil
+	}
+
+	tests := []struct {
+		action        string
+		wantAction    string
+		wantRequested string
+		wantErr       bool
+	}{
+		{"v1beta/models/fast:generateContent", "v1beta/models/upstream-fast:generateContent", "fast", false},
+		{"v1/chat/completions", "v1/chat/completions", "", false},
+		{"v1beta/models/blocked:streamGenerateContent", "", "blocked", true},
+	}
+	for _, tt := range tests {
+		action, requested, err := ReplaceActionModel(tt.action, resolve)
+		if (err != nil) != tt.wantErr || action != tt.wantAction || requested != tt.wantRequested {
+			t.Errorf("ReplaceActionModel(%q) = %q, %q, %v, want %q, %q, error %v",
+				tt.action, action, requested, err, tt.wantAction, tt.wantRequested, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"api-key-rotator/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoModelRoute 统一入口中没有路由规则匹配请求的模型
+var ErrNoModelRoute = errors.New("no LLM service is configured for model")
+
+// unifiedLLMPrefixes 统一入口使用的路径前缀，LLM配置不能使用这些服务标识
+var unifiedLLMPrefixes = map[string]bool{
+	"v1":     true,
+	"v1beta": true,
+}
+
+// IsUnifiedLLMPrefix 判断 /llm/ 之后的第一段路径是否为统一入口，如 /llm/v1/chat/completions
+func IsUnifiedLLMPrefix(slug string) bool {
+	return unifiedLLMPrefixes[slug]
+}
+
+// ResolveModelRoute 按优先级匹配启用的模型路由，返回目标LLM配置的服务标识
+// 精确匹配优先于通配符匹配
+func ResolveModelRoute(db *gorm.DB, model string) (string, error) {
+	if model == "" {
+		return "", fmt.Errorf("request does not specify a model")
+	}
+
+	var routes []models.ModelRoute
+	if err := db.Where("is_active = ?", true).Order("priority ASC, id ASC").Find(&routes).Error; err != nil {
+		return "", fmt.Errorf("failed to load model routes: %w", err)
+	}
+
+	for _, route := range routes {
+		if route.Pattern == model {
+			return route.Slug, nil
+		}
+	}
+	for _, route := range routes {
+		if strings.ContainsAny(route.Pattern, "*?") && matchModelPattern(route.Pattern, model) {
+			return route.Slug, nil
+		}
+	}
+	return "", fmt.Errorf("%w '%s'", ErrNoModelRoute, model)
+}
+
+// ValidateModelRoute 校验模型路由，目标必须是已存在的LLM配置
+func ValidateModelRoute(db *gorm.DB, route *models.ModelRoute) error {
+	if strings.TrimSpace(route.Pattern) == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	var count int64
+	if err := db.Model(&models.ProxyConfig{}).Where("slug = ? AND config_type = ?", route.Slug, "LLM").Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("LLM service configuration with slug '%s' not found", route.Slug)
+	}
+	return nil
+}
+
+// ValidateLLMSlug 校验LLM配置的服务标识没有占用统一入口的路径前缀
+// 通用配置挂在 /proxy/ 下，不与统一入口冲突
+func ValidateLLMSlug(proxyConfig *models.ProxyConfig) error {
+	if strings.EqualFold(proxyConfig.ConfigType, "LLM") && IsUnifiedLLMPrefix(proxyConfig.Slug) {
+		return fmt.Errorf("slug '%s' is reserved for the unified LLM endpoint", proxyConfig.Slug)
+	}
+	return nil
+}
+
+// HasActiveLLMConfig 判断是否存在使用该服务标识的启用的LLM配置
+// 校验加入之前保存的配置可能占用了统一入口的前缀，这类配置优先于统一入口，避免已有的调用方失效
+func HasActiveLLMConfig(db *gorm.DB, slug string) bool {
+	var count int64
+	err := db.Model(&models.ProxyConfig{}).
+		Where("slug = ? AND is_active = ? AND config_type = ?", slug, true, "LLM").
+		Count(&count).Error
+	return err == nil && count > 0
+}
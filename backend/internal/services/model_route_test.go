@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestValidateLLMSlug(t *testing.T) {
+	tests := []struct {
+		configType string
+		slug       string
+		wantErr    bool
+	}{
+		{"LLM", "v1", true},
+		{"LLM", "v1beta", true},
+		{"llm", "v1", true},
+		{"LLM", "openai", false},
+		{"GENERIC", "v1", false}, // 通用配置挂在 /proxy/ 下
+	}
+	for _, tt := range tests {
+		err := ValidateLLMSlug(&models.ProxyConfig{ConfigType: tt.configType, Slug: tt.slug})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateLLMSlug(%s %q) error = %v, wantErr %v", tt.configType, tt.slug, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHasActiveLLMConfig(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&models.ProxyConfig{Name: "legacy", Slug: "v1", ConfigType: "LLM", IsActive: true})
+	db.Create(&models.ProxyConfig{Name: "generic", Slug: "v1beta", ConfigType: "GENERIC", IsActive: true})
+	disabled := &models.ProxyConfig{Name: "disabled", Slug: "old", ConfigType: "LLM", IsActive: true}
+	db.Create(disabled)
+	db.Model(disabled).Update("is_active", false)
+
+	tests := []struct {
+		slug string
+		want bool
+	}{
+		{"v1", true},
+		{"v1beta", false},
+		{"old", false},
+		{"missing", false},
+	}
+	for _, tt := range tests {
+		if got := HasActiveLLMConfig(db, tt.slug); got != tt.want {
+			t.Errorf("HasActiveLLMConfig(%q) = %v, want %v", tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestResolveModelRoute(t *testing.T) {
+	db := newTestDB(t)
+	routes := []models.ModelRoute{
+		{Pattern: "gpt-*", Slug: "openai", Priority: 10, IsActive: true},
+		{Pattern: "gpt-4o", Slug: "azure", Priority: 20, IsActive: true},
+		{Pattern: "claude-*", Slug: "anthropic-backup", Priority: 5, IsActive: true},
+		{Pattern: "claude-*", Slug: "anthropic", Priority: 1, IsActive: true},
+		{Pattern: "gemini-?.?-pro", Slug: "gemini", IsActive: true},
+	}
+	for i := range routes {
+		db.Create(&routes[i])
+	}
+
+	tests := []struct {
+		model   string
+		want    string
+		wantErr error
+	}{
+		{"gpt-4o", "azure", nil}, // 精确匹配优先于通配符
+		{"gpt-4.1", "openai", nil},
+		{"claude-sonnet-4", "anthropic", nil}, // 优先级数值小的先匹配
+		{"gemini-2.5-pro", "gemini", nil},
+		{"llama-3", "", ErrNoModelRoute},
+	}
+	for _, tt := range tests {
+		got, err := ResolveModelRoute(db, tt.model)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ResolveModelRoute(%q) error = %v, want %v", tt.model, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveModelRoute(%q) = %q, %v, want %q", tt.model, got, err, tt.want)
+		}
+	}
+
+	if _, err := ResolveModelRoute(db, ""); err == nil {
+		t.Error("ResolveModelRoute() without a model should fail")
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-key-rotator/backend/internal/logger"
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// oauth2TokenExpirySkew 令牌在真正过期前提前刷新的时间
+	oauth2TokenExpirySkew = 60 * time.Second
+	// oauth2DefaultTokenTTL 令牌端点未返回 expires_in 时的缓存时间
+	oauth2DefaultTokenTTL = 5 * time.Minute
+)
+
+// oauth2TokenGroup 合并同一凭证的并发刷新请求
+var oauth2TokenGroup singleflight.Group
+
+// oauth2TokenResponse 令牌端点的响应
+type oauth2TokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	TokenType   string      `json:"token_type"`
+	ExpiresIn   interface{} `json:"expires_in"` // 部分服务返回字符串
+}
+
+// OAuth2TokenCacheKey 返回指定密钥的访问令牌缓存键
+func OAuth2TokenCacheKey(apiKeyID int32) string {
+	return fmt.Sprintf("oauth2:api_key:%d:access_token", apiKeyID)
+}
+
+// GetOAuth2AccessToken 获取客户端凭证对应的访问令牌
+// 优先读取缓存；缓存失效时通过 singleflight 保证同一凭证只会有一个请求去令牌端点刷新
+func (h *BaseProxyHandler) GetOAuth2AccessToken(proxyConfig *models.ProxyConfig, apiKey *models.APIKey) (string, error) {
+	ctx := context.Background()
+	cacheKey := OAuth2TokenCacheKey(apiKey.ID)
+
+	if token, err := h.cacheClient.Get(ctx, cacheKey); err == nil && token != "" {
+		return token, nil
+	}
+
+	result, err, _ := oauth2TokenGroup.Do(cacheKey, func() (interface{}, error) {
+		// 等待期间可能已被其他请求刷新
+		if token, err := h.cacheClient.Get(ctx, cacheKey); err == nil && token != "" {
+			return token, nil
+		}
+
+		token, ttl, err := h.fetchOAuth2Token(proxyConfig, apiKey)
+		if err != nil {
+			return "", err
+		}
+
+		if err := h.cacheClient.Set(ctx, cacheKey, token, ttl); err != nil {
+			logger.Errorf("%s: Failed to cache OAuth2 access token: %v", h.logPrefix, err)
+		}
+		return token, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result.(string), nil
+}
+
+// InvalidateOAuth2AccessToken 丢弃缓存的访问令牌，下次请求时重新换取
+func (h *BaseProxyHandler) InvalidateOAuth2AccessToken(apiKey *models.APIKey) {
+	if _, err := h.cacheClient.Del(context.Background(), OAuth2TokenCacheKey(apiKey.ID)); err != nil {
+		logger.Errorf("%s: Failed to evict OAuth2 access token: %v", h.logPrefix, err)
+	}
+}
+
+// fetchOAuth2Token 使用 client_credentials 授权向令牌端点换取访问令牌
+func (h *BaseProxyHandler) fetchOAuth2Token(proxyConfig *models.ProxyConfig, apiKey *models.APIKey) (string, time.Duration, error) {
+	creds, err := decryptKeyCredentials(h.cfg, apiKey.Credentials)
+	if err != nil {
+		return "", 0, err
+	}
+	if creds == nil || creds.ClientID == "" || creds.ClientSecret == "" {
+		return "", 0, fmt.Errorf("OAuth2 key requires client_id and client_secret")
+	}
+
+	tokenURL := resolveOAuth2TokenURL(proxyConfig, creds)
+	if tokenURL == "" {
+		return "", 0, fmt.Errorf("no OAuth2 token URL configured for service '%s'", proxyConfig.Slug)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if scope := resolveOAuth2Scope(proxyConfig, creds); scope != "" {
+		form.Set("scope", scope)
+	}
+	if creds.ClientAuth == OAuth2ClientAuthBody {
+		form.Set("client_id", creds.ClientID)
+		form.Set("client_secret", creds.ClientSecret)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create OAuth2 token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if creds.ClientAuth != OAuth2ClientAuthBody {
+		req.SetBasicAuth(url.QueryEscape(creds.ClientID), url.QueryEscape(creds.ClientSecret))
+	}
+
+	logger.Infof("%s: Requesting OAuth2 access token for client %s", h.logPrefix, utils.MaskAPIKeyDefault(creds.ClientID))
+
+	// 令牌请求与该密钥的上游请求使用相同的出口代理
+	options, err := ResolveUpstreamOptions(h.cfg, proxyConfig, apiKey)
+	if err != nil {
+		return "", 0, err
+	}
+	client, err := options.Client()
+	if err != nil {
+		return "", 0, err
+	}
+	client.Timeout = time.Duration(h.cfg.ProxyTimeout) * time.Second
+	if err := CurrentOutboundPolicy().CheckURL(req.URL); err != nil {
+		return "", 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to request OAuth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read OAuth2 token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		logger.Errorf("%s: OAuth2 token endpoint returned %d: %s", h.logPrefix, resp.StatusCode, string(body))
+		return "", 0, fmt.Errorf("OAuth2 token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var tokenResp oauth2TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", 0, fmt.Errorf("failed to parse OAuth2 token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("OAuth2 token response has no access_token")
+	}
+
+	return tokenResp.AccessToken, oauth2CacheTTL(tokenResp.ExpiresIn), nil
+}
+
+// oauth2CacheTTL 根据 expires_in 计算缓存时间，在过期前预留刷新余量
+func oauth2CacheTTL(expiresIn interface{}) time.Duration {
+	var seconds int64
+	switch v := expiresIn.(type) {
+	case float64:
+		seconds = int64(v)
+	case string:
+		seconds, _ = strconv.ParseInt(v, 10, 64)
+	}
+	if seconds <= 0 {
+		return oauth2DefaultTokenTTL
+	}
+
+	lifetime := time.Duration(seconds) * time.Second
+	if lifetime > 2*oauth2TokenExpirySkew {
+		return lifetime - oauth2TokenExpirySkew
+	}
+	// 有效期很短时只缓存一半时间
+	return lifetime / 2
+}
+
+// resolveOAuth2TokenURL 密钥上的令牌地址优先于配置上的令牌地址
+func resolveOAuth2TokenURL(proxyConfig *models.ProxyConfig, creds *models.KeyCredentials) string {
+	if creds.TokenURL != "" {
+		return creds.TokenURL
+	}
+	if proxyConfig.OAuthTokenURL != nil {
+		return *proxyConfig.OAuthTokenURL
+	}
+	return ""
+}
+
+// resolveOAuth2Scope 密钥上的 scope 优先于配置上的 scope
+func resolveOAuth2Scope(proxyConfig *models.ProxyConfig, creds *models.KeyCredentials) string {
+	if creds.Scope != "" {
+		return creds.Scope
+	}
+	if proxyConfig.OAuthScope != nil {
+		return *proxyConfig.OAuthScope
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/infrastructure/cache/memory"
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestOAuth2CacheTTL(t *testing.T) {
+	tests := []struct {
+		expiresIn interface{}
+		want      time.Duration
+	}{
+		{float64(3600), 3600*time.Second - oauth2TokenExpirySkew},
+		{"3600", 3600*time.Second - oauth2TokenExpirySkew},
+		{float64(60), 30 * time.Second},
+		{nil, oauth2DefaultTokenTTL},
+		{"soon", oauth2DefaultTokenTTL},
+		{float64(-5), oauth2DefaultTokenTTL},
+	}
+	for _, tt := range tests {
+		if got := oauth2CacheTTL(tt.expiresIn); got != tt.want {
+			t.Errorf("oauth2CacheTTL(%v) = %v, want %v", tt.expiresIn, got, tt.want)
+		}
+	}
+}
+
+func TestOAuth2RetryOnUnauthorized(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptedToken  string // 上游接受的令牌，为空表示始终返回 401
+		streamBody     bool
+		wantStatus     int
+		wantTokenCalls int32
+		wantUpstream   int32
+	}{
+		{"revoked token is refreshed", "token-2", false, http.StatusOK, 2, 2},
+		{"retries only once", "", false, http.StatusUnauthorized, 2, 2},
+		{"streamed body is not replayed", "token-2", true, http.StatusUnauthorized, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokenCalls, upstreamCalls atomic.Int32
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := tokenCalls.Add(1)
+				fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, n)
+			}))
+			defer tokenServer.Close()
+
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreamCalls.Add(1)
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"q":1}` {
+					t.Errorf("upstream received body %q", body)
+				}
+				if tt.acceptedToken == "" || r.Header.Get("Authorization") != "Bearer "+tt.acceptedToken {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer upstream.Close()
+
+			cfg := &config.Config{ProxyTimeout: 5}
+			handler := NewBaseProxyHandler(cfg, nil, memory.NewMemoryCache(), nil, "svc", "")
+			proxyConfig := &models.ProxyConfig{Slug: "svc", OAuthTokenURL: &tokenServer.URL}
+			apiKey := &models.APIKey{
+				ID:      7,
+				KeyType: KeyTypeOAuth2ClientCredentials,
+				Credentials: &models.KeyCredentials{
+					ClientID:     "client",
+					ClientSecret: "secret",
+				},
+			}
+
+			target := &TargetRequest{
+				Method:  http.MethodPost,
+				URL:     upstream.URL,
+				Headers: http.Header{},
+				Params:  url.Values{},
+				Body:    []byte(`{"q":1}`),
+			}
+			if tt.streamBody {
+				target.BodyStream = strings.NewReader(`{"q":1}`)
+				target.ContentLength = -1
+			}
+			if err := handler.ApplyUpstreamAuth(target, proxyConfig, apiKey, CredentialInjection{}); err != nil {
+				t.Fatalf("ApplyUpstreamAuth() error = %v", err)
+			}
+			options, err := ResolveUpstreamOptions(cfg, proxyConfig, apiKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target.Upstream = options
+
+			resp, err := SendUpstream(context.Background(), target)
+			if err != nil {
+				t.Fatalf("SendUpstream() error = %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := tokenCalls.Load(); got != tt.wantTokenCalls {
+				t.Errorf("token endpoint called %d times, want %d", got, tt.wantTokenCalls)
+			}
+			if got := upstreamCalls.Load(); got != tt.wantUpstream {
+				t.Errorf("upstream called %d times, want %d", got, tt.wantUpstream)
+			}
+		})
+	}
+}
+
+func TestCredentialInjectionReapply(t *testing.T) {
+	tests := []struct {
+		name      string
+		injection CredentialInjection
+		check     func(target *TargetRequest) string
+		want      string
+	}{
+		{
+			name:      "header",
+			injection: CredentialInjection{Location: KeyLocationHeader, Name: "Authorization", Template: "Bearer " + KeyPlaceholder},
+			check:     func(target *TargetRequest) string { return target.Headers.Get("Authorization") },
+			want:      "Bearer new/token",
+		},
+		{
+			name:      "query",
+			injection: CredentialInjection{Location: KeyLocationQuery, Name: "access_token", Template: KeyPlaceholder},
+			check:     func(target *TargetRequest) string { return target.Params.Get("access_token") },
+			want:      "new/token",
+		},
+		{
+			name:      "path",
+			injection: CredentialInjection{Location: KeyLocationPath, Template: KeyPlaceholder},
+			check:     func(target *TargetRequest) string { return target.URL },
+			want:      "https://api.example.com/t/new%2Ftoken/items",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &TargetRequest{
+				URL:     "https://api.example.com/t/" + KeyPlaceholder + "/items",
+				Headers: http.Header{},
+				Params:  url.Values{},
+			}
+			if err := tt.injection.Apply(target, "old/token"); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.injection.Reapply(target, "old/token", "new/token"); err != nil {
+				t.Fatalf("Reapply() error = %v", err)
+			}
+			if got := tt.check(target); got != tt.want {
+				t.Errorf("after Reapply got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/utils"
+)
+
+// ErrDestinationForbidden 上游地址不在出站策略允许的范围内
+var ErrDestinationForbidden = errors.New("upstream destination is not allowed")
+
+// deniedNetworks 默认禁止访问的地址段，回环、私有和链路本地地址由 net.IP 的方法判断
+var deniedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // 本网络
+	"100.64.0.0/10", // 运营商级NAT
+	"192.0.0.0/24",  // IETF协议分配
+	"198.18.0.0/15", // 基准测试
+	"240.0.0.0/4",   // 保留地址及广播
+	"64:ff9b::/96",  // NAT64，可映射到任意IPv4内网地址
+)
+
+// OutboundPolicy 出站请求的目标地址策略，用于防止SSRF
+// 保存配置时校验目标URL，建立连接时再次校验实际连接的IP，以防DNS重绑定
+type OutboundPolicy struct {
+	enabled      bool
+	allowedNets  []*net.IPNet
+	allowedHosts []string
+	schemes      map[string]bool
+	ports        map[string]bool // 为空表示不限制端口
+}
+
+var outboundPolicy atomic.Pointer[OutboundPolicy]
+
+// ConfigureOutboundPolicy 根据全局配置设置出站策略，启动时调用
+func ConfigureOutboundPolicy(cfg *config.Config) error {
+	policy := &OutboundPolicy{
+		enabled:      cfg.SSRFProtection,
+		allowedHosts: cfg.GetOutboundAllowedHosts(),
+		schemes:      make(map[string]bool),
+		ports:        make(map[string]bool),
+	}
+
+	allowedNets, err := utils.ParseCIDRList(cfg.GetOutboundAllowedCIDRs())
+	if err != nil {
+		return fmt.Errorf("invalid OUTBOUND_ALLOWED_CIDRS: %w", err)
+	}
+	policy.allowedNets = allowedNets
+
+	for _, scheme := range cfg.GetOutboundAllowedSchemes() {
+		policy.schemes[strings.ToLower(scheme)] = true
+	}
+	for _, port := range cfg.GetOutboundAllowedPorts() {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("invalid port '%s' in OUTBOUND_ALLOWED_PORTS", port)
+		}
+		policy.ports[port] = true
+	}
+
+	outboundPolicy.Store(policy)
+	return nil
+}
+
+// CurrentOutboundPolicy 返回当前的出站策略，未配置时不做任何限制
+func CurrentOutboundPolicy() *OutboundPolicy {
+	if policy := outboundPolicy.Load(); policy != nil {
+		return policy
+	}
+	return &OutboundPolicy{}
+}
+
+// CheckURL 校验URL的协议、端口和主机，主机为域名时只校验白名单，解析后的IP在建立连接时校验
+func (p *OutboundPolicy) CheckURL(target *url.URL) error {
+	if !p.enabled {
+		return nil
+	}
+
+	scheme := strings.ToLower(target.Scheme)
+	if len(p.schemes) > 0 && !p.schemes[scheme] {
+		return fmt.Errorf("%w: scheme '%s' is not allowed", ErrDestinationForbidden, target.Scheme)
+	}
+
+	port := target.Port()
+	if port == "" {
+		switch scheme {
+		case "https", "wss":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	if len(p.ports) > 0 && !p.ports[port] {
+		return fmt.Errorf("%w: port %s is not allowed", ErrDestinationForbidden, port)
+	}
+
+	host := target.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrDestinationForbidden)
+	}
+	if p.hostAllowed(host) {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.CheckIP(ip)
+	}
+	return nil
+}
+
+// CheckIP 校验IP是否允许访问: 白名单中的地址段始终允许，否则拒绝回环、私有、链路本地和保留地址
+func (p *OutboundPolicy) CheckIP(ip net.IP) error {
+	if !p.enabled {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range p.allowedNets {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	denied := ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+	for _, network := range deniedNetworks {
+		if denied {
+			break
+		}
+		denied = network.Contains(ip)
+	}
+	if denied {
+		return fmt.Errorf("%w: address %s is in a restricted range", ErrDestinationForbidden, ip)
+	}
+	return nil
+}
+
+// hostAllowed 判断主机名是否在白名单中，支持 "*.example.com" 形式的后缀匹配
+func (p *OutboundPolicy) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, allowed := range p.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckHost 校验主机名解析出的全部IP，白名单中的主机名不做校验，无法解析的主机名视为不允许
+func (p *OutboundPolicy) CheckHost(ctx context.Context, host string) error {
+	if !p.enabled || p.hostAllowed(host) {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.CheckIP(ip)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve host '%s': %v", ErrDestinationForbidden, host, err)
+	}
+	for _, addr := range addrs {
+		if err := p.CheckIP(addr.IP); err != nil {
+			return fmt.Errorf("host '%s' resolves to a restricted address: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// ProxyFunc 包装代理选择函数: 经代理发出的请求由代理解析目标主机，拨号时看不到目标IP，
+// 因此在交给代理前先解析并校验目标主机
+func (p *OutboundPolicy) ProxyFunc(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if !p.enabled {
+		return proxy
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		if err := p.CheckHost(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		return proxyURL, nil
+	}
+}
+
+// DialContext 包装拨号器，在建立连接前校验实际连接的IP；白名单中的主机名不做IP校验
+// exempt 为管理员配置的代理地址 (host:port)，连接代理本身不受出站策略限制
+func (p *OutboundPolicy) DialContext(dialer *net.Dialer, exempt ...string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	if !p.enabled {
+		return dialer.DialContext
+	}
+
+	guarded := *dialer
+	guarded.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("%w: unresolved address %s", ErrDestinationForbidden, address)
+		}
+		return p.CheckIP(ip)
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		for _, proxyAddress := range exempt {
+			if strings.EqualFold(address, proxyAddress) {
+				return dialer.DialContext(ctx, network, address)
+			}
+		}
+		if host, _, err := net.SplitHostPort(address); err == nil && p.hostAllowed(host) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return guarded.DialContext(ctx, network, address)
+	}
+}
+
+// proxyRouting 返回连接使用的代理选择函数和拨号函数，proxyURL 为空时使用环境变量中的代理
+// 直连时拨号校验实际IP；经代理时在选择代理时校验目标主机，代理自身的地址不做校验
+func (p *OutboundPolicy) proxyRouting(proxyURL *url.URL, dialer *net.Dialer) (func(*http.Request) (*url.URL, error), func(ctx context.Context, network, address string) (net.Conn, error)) {
+	if proxyURL == nil {
+		return p.ProxyFunc(http.ProxyFromEnvironment), p.DialContext(dialer, environmentProxyAddresses()...)
+	}
+	return p.ProxyFunc(http.ProxyURL(proxyURL)), p.DialContext(dialer, proxyAddress(proxyURL))
+}
+
+// proxyAddress 返回连接代理时拨号的地址，端口缺省时按协议补全
+func proxyAddress(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch strings.ToLower(proxyURL.Scheme) {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// environmentProxyAddresses 返回 HTTP_PROXY、HTTPS_PROXY 环境变量中代理的地址
+func environmentProxyAddresses() []string {
+	var addresses []string
+	for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy"} {
+		value := strings.TrimSpace(os.Getenv(name))
+		if value == "" {
+			continue
+		}
+		proxyURL, err := url.Parse(value)
+		if err != nil || proxyURL.Host == "" {
+			// 与 http.ProxyFromEnvironment 一致，没有协议时按 http 处理
+			if proxyURL, err = url.Parse("http://" + value); err != nil {
+				continue
+			}
+		}
+		addresses = append(addresses, proxyAddress(proxyURL))
+	}
+	return addresses
+}
+
+// ValidateDestination 保存配置时校验上游URL，域名会被解析并逐个校验解析结果
+// 解析失败时不阻止保存，连接时仍会校验实际IP
+func ValidateDestination(field, rawURL string) error {
+	policy := CurrentOutboundPolicy()
+	if !policy.enabled || rawURL == "" {
+		return nil
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", field, err)
+	}
+	if err := policy.CheckURL(target); err != nil {
+		return fmt.Errorf("invalid %s: %w", field, err)
+	}
+
+	host := target.Hostname()
+	if policy.hostAllowed(host) || net.ParseIP(host) != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if err := policy.CheckIP(addr.IP); err != nil {
+			return fmt.Errorf("invalid %s: host '%s' resolves to a restricted address: %w", field, host, err)
+		}
+	}
+	return nil
+}
+
+// IsDestinationForbidden 判断错误是否由出站策略拒绝导致
+func IsDestinationForbidden(err error) bool {
+	return errors.Is(err, ErrDestinationForbidden)
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks, err := utils.ParseCIDRList(cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
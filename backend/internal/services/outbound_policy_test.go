@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+)
+
+func newTestOutboundPolicy(t *testing.T, allowedCIDRs ...string) *OutboundPolicy {
+	t.Helper()
+	policy := &OutboundPolicy{enabled: true, allowedHosts: []string{"*.internal.example"}}
+	for _, cidr := range allowedCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy.allowedNets = append(policy.allowedNets, network)
+	}
+	return policy
+}
+
+// useOutboundPolicy 在测试期间替换全局出站策略
+func useOutboundPolicy(t *testing.T, policy *OutboundPolicy) {
+	t.Helper()
+	previous := outboundPolicy.Load()
+	outboundPolicy.Store(policy)
+	t.Cleanup(func() { outboundPolicy.Store(previous) })
+}
+
+func TestOutboundPolicyCheckIP(t *testing.T) {
+	policy := newTestOutboundPolicy(t, "10.1.0.0/16")
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"10.1.2.3", true}, // 白名单地址段
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // 云厂商元数据地址
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		err := policy.CheckIP(net.ParseIP(tt.ip))
+		if (err == nil) != tt.allowed {
+			t.Errorf("CheckIP(%s) error = %v, want allowed %v", tt.ip, err, tt.allowed)
+		}
+		if err != nil && !IsDestinationForbidden(err) {
+			t.Errorf("CheckIP(%s) error should wrap ErrDestinationForbidden: %v", tt.ip, err)
+		}
+	}
+
+	if err := (&OutboundPolicy{}).CheckIP(net.ParseIP("127.0.0.1")); err != nil {
+		t.Errorf("disabled policy should allow everything: %v", err)
+	}
+}
+
+func TestOutboundPolicyCheckURL(t *testing.T) {
+	policy := newTestOutboundPolicy(t)
+	policy.schemes = map[string]bool{"https": true}
+	policy.ports = map[string]bool{"443": true}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://api.example.com/v1", true},
+		{"http://api.example.com/v1", false},
+		{"https://api.example.com:8443/v1", false},
+		{"https://127.0.0.1/", false},
+		{"https://[::1]/", false},
+		{"https://svc.internal.example/", true},
+	}
+	for _, tt := range tests {
+		target, _ := url.Parse(tt.url)
+		if err := policy.CheckURL(target); (err == nil) != tt.allowed {
+			t.Errorf("CheckURL(%s) error = %v, want allowed %v", tt.url, err, tt.allowed)
+		}
+	}
+}
+
+func TestOutboundPolicyDialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	address := listener.Addr().String()
+
+	tests := []struct {
+		name    string
+		policy  *OutboundPolicy
+		exempt  []string
+		address string
+		allowed bool
+	}{
+		{"loopback is blocked", newTestOutboundPolicy(t), nil, address, false},
+		{"hostname resolving to loopback is blocked", newTestOutboundPolicy(t), nil, "localhost:" + portOf(address), false},
+		{"allowed CIDR", newTestOutboundPolicy(t, "127.0.0.0/8"), nil, address, true},
+		{"configured proxy address is exempt", newTestOutboundPolicy(t), []string{address}, address, true},
+		{"exemption is per address", newTestOutboundPolicy(t), []string{"127.0.0.1:1"}, address, false},
+		{"disabled policy", &OutboundPolicy{}, nil, address, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dial := tt.policy.DialContext(&net.Dialer{}, tt.exempt...)
+			conn, err := dial(context.Background(), "tcp", tt.address)
+			if conn != nil {
+				conn.Close()
+			}
+			if (err == nil) != tt.allowed {
+				t.Fatalf("dial %s error = %v, want allowed %v", tt.address, err, tt.allowed)
+			}
+			if err != nil && !IsDestinationForbidden(err) {
+				t.Errorf("dial error should wrap ErrDestinationForbidden: %v", err)
+			}
+		})
+	}
+}
+
+func TestOutboundPolicyProxyFunc(t *testing.T) {
+	policy := newTestOutboundPolicy(t)
+	proxyURL, _ := url.Parse("http://proxy.example:3128")
+
+	tests := []struct {
+		name    string
+		target  string
+		proxy   func(*http.Request) (*url.URL, error)
+		allowed bool
+	}{
+		{"public address through proxy", "http://93.184.216.34/", http.ProxyURL(proxyURL), true},
+		{"loopback through proxy", "http://127.0.0.1/", http.ProxyURL(proxyURL), false},
+		{"hostname resolving to loopback through proxy", "http://localhost/", http.ProxyURL(proxyURL), false},
+		{"metadata address through proxy", "http://169.254.169.254/latest/meta-data", http.ProxyURL(proxyURL), false},
+		{"allowlisted host through proxy", "http://svc.internal.example/", http.ProxyURL(proxyURL), true},
+		{"direct connection is left to the dialer", "http://127.0.0.1/", func(*http.Request) (*url.URL, error) { return nil, nil }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			_, err := policy.ProxyFunc(tt.proxy)(req)
+			if (err == nil) != tt.allowed {
+				t.Errorf("ProxyFunc() error = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
+
+// 经显式出口代理的请求: 代理本身在内网也能连接，但目标主机解析到内网地址时被拒绝
+func TestSendUpstreamThroughEgressProxy(t *testing.T) {
+	var proxied []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxyServer.Close()
+	useOutboundPolicy(t, newTestOutboundPolicy(t))
+
+	cfg := &config.Config{ProxyTimeout: 5}
+	proxyConfig := &models.ProxyConfig{ID: 903, EgressProxyURL: &proxyServer.URL}
+	defer EvictUpstreamTransports(proxyConfig.ID, 0)
+	options, err := ResolveUpstreamOptions(cfg, proxyConfig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target  string
+		allowed bool
+	}{
+		{"http://93.184.216.34/resource", true},
+		{"http://localhost/admin", false},
+		{"http://127.0.0.1/admin", false},
+	}
+	for _, tt := range tests {
+		resp, err := SendUpstream(context.Background(), &TargetRequest{Method: http.MethodGet, URL: tt.target, Upstream: options})
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tt.allowed {
+			t.Errorf("SendUpstream(%s) error = %v, want allowed %v", tt.target, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrDestinationForbidden) {
+			t.Errorf("SendUpstream(%s) error should wrap ErrDestinationForbidden: %v", tt.target, err)
+		}
+	}
+	if len(proxied) != 1 || proxied[0] != "http://93.184.216.34/resource" {
+		t.Errorf("proxy received %v, want only the public target", proxied)
+	}
+}
+
+func portOf(address string) string {
+	_, port, _ := net.SplitHostPort(address)
+	return port
+}
+
+func TestEnvironmentProxyAddresses(t *testing.T) {
+	for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HTTP_PROXY", "10.0.0.5:3128")
+	t.Setenv("HTTPS_PROXY", "https://proxy.corp.example")
+
+	got := environmentProxyAddresses()
+	want := []string{"10.0.0.5:3128", "proxy.corp.example:443"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("environmentProxyAddresses() = %v, want %v", got, want)
+	}
+}
@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 
 	"api-key-rotator/backend/internal/infrastructure/cache"
 	"api-key-rotator/backend/internal/config"
@@ -14,13 +18,34 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrNoActiveKeys 配置的密钥池中没有可用的密钥
+var ErrNoActiveKeys = errors.New("no active API keys for this service")
+
 // TargetRequest 封装准备好的、即将被转发的请求信息
 type TargetRequest struct {
 	Method  string
 	URL     string
-	Headers map[string]string
-	Params  map[string]string
+	Headers http.Header
+	Params  url.Values
 	Body    []byte
+
+	// BodyStream 可选，设置后以流的方式转发请求体，Body 被忽略
+	BodyStream io.Reader
+	// ContentLength BodyStream 的长度，未知时为 -1
+	ContentLength int64
+	// Trailer 可选，随请求体一起转发的 trailer
+	Trailer http.Header
+
+	Signer RequestSigner // 可选，发送前对最终请求签名
+
+	// RefreshAuth 可选，上游返回 401 时刷新凭证并更新请求，SendUpstream 随后重试一次
+	RefreshAuth func() error
+
+	// Upstream 连接参数（超时等）
+	Upstream UpstreamOptions
+
+	// RewriteResponseHeaders 可选，在响应头写回客户端前执行改写规则
+	RewriteResponseHeaders func(http.Header)
 }
 
 // BaseProxyHandler 代理处理器的抽象基类
@@ -49,6 +74,15 @@ func NewBaseProxyHandler(cfg *config.Config, db *gorm.DB, cacheClient cache.Cach
 
 // RotateAPIKey 从与给定配置关联的密钥池中轮询一个API Key
 func (h *BaseProxyHandler) RotateAPIKey(serviceConfig *models.ProxyConfig) (string, error) {
+	selectedKey, err := h.RotateAPIKeyRecord(serviceConfig)
+	if err != nil {
+		return "", err
+	}
+	return selectedKey.KeyValue, nil
+}
+
+// RotateAPIKeyRecord 从密钥池中轮询一个API Key，返回完整的密钥记录（包含类型和结构化凭证）
+func (h *BaseProxyHandler) RotateAPIKeyRecord(serviceConfig *models.ProxyConfig) (*models.APIKey, error) {
 	// 获取活跃的密钥
 	var activeKeys []models.APIKey
 	for _, key := range serviceConfig.APIKeys {
@@ -59,7 +93,7 @@ func (h *BaseProxyHandler) RotateAPIKey(serviceConfig *models.ProxyConfig) (stri
 
 	if len(activeKeys) == 0 {
 		logger.Errorf("%s: Service '%s' has no active API keys.", h.logPrefix, serviceConfig.Name)
-		return "", fmt.Errorf("no active API keys for this service")
+		return nil, ErrNoActiveKeys
 	}
 
 	// 使用缓存原子性递增来实现轮询
@@ -68,15 +102,15 @@ func (h *BaseProxyHandler) RotateAPIKey(serviceConfig *models.ProxyConfig) (stri
 	keyIndex, err := h.cacheClient.Incr(ctx, keyIndexKey)
 	if err != nil {
 		logger.Errorf("%s: Failed to increment key index in cache: %v", h.logPrefix, err)
-		return "", fmt.Errorf("failed to rotate API key")
+		return nil, fmt.Errorf("failed to rotate API key")
 	}
 
 	// 计算实际索引
 	actualIndex := int(keyIndex-1) % len(activeKeys)
-	selectedKey := activeKeys[actualIndex].KeyValue
+	selectedKey := activeKeys[actualIndex]
 
-	logger.Infof("%s: Selected API key (masked): %s", h.logPrefix, utils.MaskAPIKeyDefault(selectedKey))
-	return selectedKey, nil
+	logger.Infof("%s: Selected API key (masked): %s", h.logPrefix, utils.MaskAPIKeyDefault(selectedKey.KeyValue))
+	return &selectedKey, nil
 }
 
 // ValidateSlug 验证slug格式
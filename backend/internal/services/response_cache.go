@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-key-rotator/backend/internal/infrastructure/cache"
+	"api-key-rotator/backend/internal/logger"
+	"api-key-rotator/backend/internal/models"
+)
+
+// DefaultResponseCacheMaxEntryKB 未配置大小上限时单个缓存响应体的上限（KB）
+const DefaultResponseCacheMaxEntryKB = 1024
+
+// cacheableStatusCodes 可以缓存的上游状态码
+var cacheableStatusCodes = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// CachedResponse 缓存中保存的上游响应（响应头已过滤，未执行响应头改写）
+type CachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// ResponseCache 单个请求对应的响应缓存条目
+type ResponseCache struct {
+	cacheClient cache.CacheInterface
+	settings    *models.ResponseCacheSettings
+	key         string
+	noStore     bool // 客户端要求不存储响应
+	noCache     bool // 客户端要求不使用已缓存的响应
+}
+
+// ResponseCacheGenerationKey 返回指定配置的缓存代数键，清除缓存时递增代数使旧条目失效
+func ResponseCacheGenerationKey(configID int32) string {
+	return fmt.Sprintf("proxy_config:%d:response_cache_generation", configID)
+}
+
+// NewResponseCache 为可缓存的请求构造缓存条目，配置未启用缓存或请求不可缓存时返回 nil
+func NewResponseCache(ctx context.Context, cacheClient cache.CacheInterface, proxyConfig *models.ProxyConfig, r *http.Request, subPath string) *ResponseCache {
+	settings := proxyConfig.ResponseCache
+	if settings == nil || !settings.Enabled {
+		return nil
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return nil
+	}
+	if IsWebSocketUpgrade(r) {
+		return nil
+	}
+
+	generation, _ := cacheClient.Get(ctx, ResponseCacheGenerationKey(proxyConfig.ID))
+
+	directives := parseCacheControl(r.Header.Values("Cache-Control"))
+	_, noStore := directives["no-store"]
+	_, noCache := directives["no-cache"]
+	if maxAge, ok := directives["max-age"]; ok && maxAge == "0" {
+		noCache = true
+	}
+
+	return &ResponseCache{
+		cacheClient: cacheClient,
+		settings:    settings,
+		key:         fmt.Sprintf("proxy_config:%d:response_cache:%s:%s", proxyConfig.ID, generation, responseCacheKeyHash(settings, r, subPath)),
+		noStore:     noStore,
+		noCache:     noCache,
+	}
+}
+
+// responseCacheKeyHash 由方法、路径、选定的查询参数和请求头计算缓存键
+func responseCacheKeyHash(settings *models.ResponseCacheSettings, r *http.Request, subPath string) string {
+	var builder strings.Builder
+	builder.WriteString(r.Method)
+	builder.WriteString("\n/")
+	builder.WriteString(strings.TrimPrefix(subPath, "/"))
+	builder.WriteString("\n")
+
+	query := r.URL.Query()
+	names := settings.QueryParams
+	if len(names) == 0 {
+		for name := range query {
+			names = append(names, name)
+		}
+	}
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	for _, name := range names {
+		values, ok := query[name]
+		if !ok {
+			continue
+		}
+		for _, value := range values {
+			builder.WriteString(name + "=" + value + "&")
+		}
+	}
+	builder.WriteString("\n")
+
+	// 请求头原样转发给上游，压缩方式不同的响应不能混用，因此 Accept-Encoding 始终参与缓存键
+	headers := append([]string{"Accept-Encoding"}, settings.VaryHeaders...)
+	for _, name := range headers {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		builder.WriteString(name + ":" + strings.Join(r.Header.Values(name), ",") + "\n")
+	}
+
+	sum := sha256.Sum256([]byte(builder.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup 读取缓存的响应，未命中或客户端要求重新验证时返回 false
+func (rc *ResponseCache) Lookup(ctx context.Context) (*CachedResponse, bool) {
+	if rc.noCache {
+		return nil, false
+	}
+	value, err := rc.cacheClient.Get(ctx, rc.key)
+	if err != nil {
+		return nil, false
+	}
+	var cached CachedResponse
+	if err := json.Unmarshal([]byte(value), &cached); err != nil {
+		logger.Warningf("Discarding unreadable cached response %s: %v", rc.key, err)
+		return nil, false
+	}
+	return &cached, true
+}
+
+// MaxEntryBytes 返回单个缓存响应体的大小上限（字节）
+func (rc *ResponseCache) MaxEntryBytes() int64 {
+	maxEntryKB := rc.settings.MaxEntrySize
+	if maxEntryKB <= 0 {
+		maxEntryKB = DefaultResponseCacheMaxEntryKB
+	}
+	return int64(maxEntryKB) * 1024
+}
+
+// TTLFor 根据上游响应和配置计算缓存时间，返回 0 表示响应不可缓存
+// 上游的 no-store、private、no-cache、Set-Cookie 和未参与缓存键的 Vary 头都会阻止缓存；
+// 配置了 TTL 时覆盖上游的 max-age，否则使用 s-maxage 或 max-age
+func (rc *ResponseCache) TTLFor(resp *http.Response) time.Duration {
+	if rc.noStore || !cacheableStatusCodes[resp.StatusCode] {
+		return 0
+	}
+	if resp.ContentLength > rc.MaxEntryBytes() {
+		return 0
+	}
+	if len(resp.Header.Values("Set-Cookie")) > 0 || !rc.coversVary(resp.Header.Values("Vary")) {
+		return 0
+	}
+
+	directives := parseCacheControl(resp.Header.Values("Cache-Control"))
+	for _, directive := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := directives[directive]; ok {
+			return 0
+		}
+	}
+
+	if rc.settings.TTL > 0 {
+		return time.Duration(rc.settings.TTL) * time.Second
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// coversVary 判断上游 Vary 中的请求头是否都已参与缓存键
+func (rc *ResponseCache) coversVary(varyValues []string) bool {
+	keyed := map[string]bool{"Accept-Encoding": true}
+	for _, name := range rc.settings.VaryHeaders {
+		keyed[textproto.CanonicalMIMEHeaderKey(name)] = true
+	}
+	for _, value := range varyValues {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" || !keyed[textproto.CanonicalMIMEHeaderKey(name)] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Store 保存上游响应，失败只记录日志
+func (rc *ResponseCache) Store(ctx context.Context, statusCode int, header http.Header, body []byte, ttl time.Duration) {
+	value, err := json.Marshal(CachedResponse{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       body,
+		StoredAt:   time.Now(),
+	})
+	if err != nil {
+		logger.Errorf("Failed to encode response for cache: %v", err)
+		return
+	}
+	if err := rc.cacheClient.Set(ctx, rc.key, string(value), ttl); err != nil {
+		logger.Errorf("Failed to store cached response: %v", err)
+	}
+}
+
+// PurgeResponseCache 清除指定配置的全部缓存响应
+// 缓存接口不支持按前缀删除，因此递增缓存代数使旧条目不再被命中，旧条目随 TTL 过期
+func PurgeResponseCache(ctx context.Context, cacheClient cache.CacheInterface, configID int32) error {
+	_, err := cacheClient.Incr(ctx, ResponseCacheGenerationKey(configID))
+	return err
+}
+
+// ValidateResponseCacheSettings 校验响应缓存设置，供保存配置时使用
+func ValidateResponseCacheSettings(proxyConfig *models.ProxyConfig) error {
+	settings := proxyConfig.ResponseCache
+	if settings == nil {
+		return nil
+	}
+	if settings.TTL < 0 {
+		return fmt.Errorf("response_cache.ttl must not be negative")
+	}
+	if settings.MaxEntrySize < 0 {
+		return fmt.Errorf("response_cache.max_entry_size must not be negative")
+	}
+	for _, name := range settings.QueryParams {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("response_cache.query_params must not contain empty names")
+		}
+	}
+	for _, name := range settings.VaryHeaders {
+		if !isHeaderToken(name) {
+			return fmt.Errorf("invalid header name '%s' in response_cache.vary_headers", name)
+		}
+	}
+	return nil
+}
+
+// parseCacheControl 解析 Cache-Control 指令，指令名统一为小写
+func parseCacheControl(values []string) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(part, "=")
+			directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return directives
+}
+
+// isHeaderToken 判断字符串是否为合法的请求头名称
+func isHeaderToken(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 127 || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
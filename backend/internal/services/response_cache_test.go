@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api-key-rotator/backend/internal/infrastructure/cache/memory"
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestResponseCacheKeyHash(t *testing.T) {
+	settings := &models.ResponseCacheSettings{Enabled: true, QueryParams: []string{"page"}, VaryHeaders: []string{"accept-language"}}
+	key := func(target string, header http.Header) string {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		return responseCacheKeyHash(settings, r, "items")
+	}
+	base := key("/items?page=1&ts=1", nil)
+
+	tests := []struct {
+		target   string
+		header   http.Header
+		wantSame bool
+	}{
+		{"/items?ts=2&page=1", nil, true}, // 未列出的查询参数不参与缓存键
+		{"/items?page=2", nil, false},
+		{"/items?page=1", http.Header{"Accept-Language": {"de"}}, false},
+		{"/items?page=1", http.Header{"Accept-Encoding": {"gzip"}}, false},
+		{"/items?page=1", http.Header{"User-Agent": {"curl"}}, true},
+	}
+	for _, tt := range tests {
+		if same := key(tt.target, tt.header) == base; same != tt.wantSame {
+			t.Errorf("key(%s, %v) same = %v, want %v", tt.target, tt.header, same, tt.wantSame)
+		}
+	}
+}
+
+func TestResponseCacheTTLFor(t *testing.T) {
+	tests := []struct {
+		settings models.ResponseCacheSettings
+		status   int
+		header   http.Header
+		want     time.Duration
+	}{
+		{models.ResponseCacheSettings{}, 200, http.Header{"Cache-Control": {"public, max-age=60"}}, time.Minute},
+		{models.ResponseCacheSettings{}, 200, http.Header{"Cache-Control": {"max-age=60, s-maxage=120"}}, 2 * time.Minute},
+		{models.ResponseCacheSettings{TTL: 5}, 200, http.Header{"Cache-Control": {"max-age=60"}}, 5 * time.Second},
+		{models.ResponseCacheSettings{}, 200, http.Header{}, 0},
+		{models.ResponseCacheSettings{TTL: 5}, 500, http.Header{}, 0},
+		{models.ResponseCacheSettings{}, 200, http.Header{"Cache-Control": {"private, max-age=60"}}, 0},
+		{models.ResponseCacheSettings{}, 200, http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=1"}}, 0},
+		{models.ResponseCacheSettings{}, 200, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Authorization"}}, 0},
+		{models.ResponseCacheSettings{VaryHeaders: []string{"accept-language"}}, 200, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Encoding, Accept-Language"}}, time.Minute},
+	}
+	for _, tt := range tests {
+		settings := tt.settings
+		settings.Enabled = true
+		rc := &ResponseCache{settings: &settings}
+		if got := rc.TTLFor(&http.Response{StatusCode: tt.status, Header: tt.header}); got != tt.want {
+			t.Errorf("TTLFor(%d, %v) = %v, want %v", tt.status, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCacheStoreAndPurge(t *testing.T) {
+	ctx := context.Background()
+	cacheClient := memory.NewMemoryCache()
+	proxyConfig := &models.ProxyConfig{ID: 3, ResponseCache: &models.ResponseCacheSettings{Enabled: true}}
+	newCache := func(method string, header http.Header) *ResponseCache {
+		r := httptest.NewRequest(method, "/items", nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		return NewResponseCache(ctx, cacheClient, proxyConfig, r, "items")
+	}
+
+	if newCache(http.MethodPost, nil) != nil {
+		t.Error("NewResponseCache() should not cache POST requests")
+	}
+	rc := newCache(http.MethodGet, nil)
+	if _, ok := rc.Lookup(ctx); ok {
+		t.Fatal("Lookup() hit an empty cache")
+	}
+	rc.Store(ctx, http.StatusOK, http.Header{"Content-Type": {"application/json"}}, []byte(`{"a":1}`), time.Minute)
+
+	cached, ok := newCache(http.MethodGet, nil).Lookup(ctx)
+	if !ok || cached.StatusCode != http.StatusOK || string(cached.Body) != `{"a":1}` {
+		t.Fatalf("Lookup() = %+v, %v", cached, ok)
+	}
+	// 客户端要求 no-cache 时跳过缓存
+	if _, ok := newCache(http.MethodGet, http.Header{"Cache-Control": {"no-cache"}}).Lookup(ctx); ok {
+		t.Error("Lookup() should bypass the cache for no-cache requests")
+	}
+
+	if err := PurgeResponseCache(ctx, cacheClient, proxyConfig.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := newCache(http.MethodGet, nil).Lookup(ctx); ok {
+		t.Error("Lookup() hit a purged entry")
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 改写规则作用范围
+const (
+	RewriteScopeRequestHeader  = "request_header"
+	RewriteScopeResponseHeader = "response_header"
+	RewriteScopeQuery          = "query"
+)
+
+// 改写规则动作
+const (
+	RewriteActionSet    = "set"
+	RewriteActionAppend = "append"
+	RewriteActionRemove = "remove"
+	RewriteActionRename = "rename"
+)
+
+// RequestIDContextKey gin 上下文中保存请求ID的键
+const RequestIDContextKey = "request_id"
+
+// RewriteContext 渲染改写模板时可用的变量
+type RewriteContext struct {
+	ClientIP  string
+	RequestID string
+	KeyLabel  string
+}
+
+// NewRewriteContext 根据当前请求和选中的上游密钥构造模板变量
+func NewRewriteContext(c *gin.Context, apiKey *models.APIKey) RewriteContext {
+	ctx := RewriteContext{
+		ClientIP:  c.ClientIP(),
+		RequestID: c.GetString(RequestIDContextKey),
+	}
+	if apiKey != nil {
+		ctx.KeyLabel = KeyLabel(apiKey)
+	}
+	return ctx
+}
+
+// KeyLabel 返回密钥的展示标签，未设置标签时使用脱敏后的密钥
+func KeyLabel(apiKey *models.APIKey) string {
+	if apiKey.Label != "" {
+		return apiKey.Label
+	}
+	return utils.MaskAPIKeyDefault(apiKey.KeyValue)
+}
+
+// Render 替换值模板中的变量
+func (r RewriteContext) Render(value string) string {
+	if !strings.Contains(value, "{") {
+		return value
+	}
+	replacer := strings.NewReplacer(
+		"{client_ip}", r.ClientIP,
+		"{request_id}", r.RequestID,
+		"{key_label}", r.KeyLabel,
+	)
+	return replacer.Replace(value)
+}
+
+// ApplyRequestRewrites 对目标请求执行请求头和查询参数的改写规则
+func ApplyRequestRewrites(target *TargetRequest, rules []models.RewriteRule, ctx RewriteContext) {
+	for _, rule := range rules {
+		switch rule.Scope {
+		case RewriteScopeRequestHeader:
+			if target.Headers == nil {
+				target.Headers = make(http.Header)
+			}
+			rewriteHeader(target.Headers, rule, ctx)
+		case RewriteScopeQuery:
+			if target.Params == nil {
+				target.Params = make(url.Values)
+			}
+			rewriteQuery(target.Params, rule, ctx)
+		}
+	}
+}
+
+// ResponseHeaderRewriter 返回对响应头执行改写规则的函数，没有响应头规则时返回 nil
+func ResponseHeaderRewriter(rules []models.RewriteRule, ctx RewriteContext) func(http.Header) {
+	var responseRules []models.RewriteRule
+	for _, rule := range rules {
+		if rule.Scope == RewriteScopeResponseHeader {
+			responseRules = append(responseRules, rule)
+		}
+	}
+	if len(responseRules) == 0 {
+		return nil
+	}
+
+	return func(header http.Header) {
+		for _, rule := range responseRules {
+			rewriteHeader(header, rule, ctx)
+		}
+	}
+}
+
+// ValidateRewriteRules 校验配置中的改写规则，供保存配置时使用
+func ValidateRewriteRules(proxyConfig *models.ProxyConfig) error {
+	for i, rule := range proxyConfig.RewriteRules {
+		switch rule.Scope {
+		case RewriteScopeRequestHeader, RewriteScopeResponseHeader, RewriteScopeQuery:
+		default:
+			return fmt.Errorf("rewrite rule #%d: unsupported scope '%s'", i+1, rule.Scope)
+		}
+		if rule.Name == "" {
+			return fmt.Errorf("rewrite rule #%d: name is required", i+1)
+		}
+		switch rule.Action {
+		case RewriteActionSet, RewriteActionAppend, RewriteActionRemove:
+		case RewriteActionRename:
+			if rule.NewName == "" {
+				return fmt.Errorf("rewrite rule #%d: new_name is required for rename", i+1)
+			}
+		default:
+			return fmt.Errorf("rewrite rule #%d: unsupported action '%s'", i+1, rule.Action)
+		}
+	}
+	return nil
+}
+
+// NormalizeRewriteRules 统一规则中作用范围和动作的大小写
+func NormalizeRewriteRules(rules []models.RewriteRule) []models.RewriteRule {
+	for i := range rules {
+		rules[i].Scope = strings.ToLower(strings.TrimSpace(rules[i].Scope))
+		rules[i].Action = strings.ToLower(strings.TrimSpace(rules[i].Action))
+		rules[i].Name = strings.TrimSpace(rules[i].Name)
+		rules[i].NewName = strings.TrimSpace(rules[i].NewName)
+	}
+	return rules
+}
+
+// rewriteHeader 在头部上执行一条改写规则，append 会追加一个新值而不是覆盖
+func rewriteHeader(header http.Header, rule models.RewriteRule, ctx RewriteContext) {
+	switch rule.Action {
+	case RewriteActionSet:
+		header.Set(rule.Name, ctx.Render(rule.Value))
+	case RewriteActionAppend:
+		header.Add(rule.Name, ctx.Render(rule.Value))
+	case RewriteActionRemove:
+		header.Del(rule.Name)
+	case RewriteActionRename:
+		if values := header.Values(rule.Name); len(values) > 0 {
+			values = append([]string(nil), values...)
+			header.Del(rule.Name)
+			for _, v := range values {
+				header.Add(rule.NewName, v)
+			}
+		}
+	}
+}
+
+// rewriteQuery 在查询参数上执行一条改写规则，append 会追加一个同名参数
+func rewriteQuery(query url.Values, rule models.RewriteRule, ctx RewriteContext) {
+	switch rule.Action {
+	case RewriteActionSet:
+		query.Set(rule.Name, ctx.Render(rule.Value))
+	case RewriteActionAppend:
+		query.Add(rule.Name, ctx.Render(rule.Value))
+	case RewriteActionRemove:
+		query.Del(rule.Name)
+	case RewriteActionRename:
+		if values, ok := query[rule.Name]; ok {
+			query.Del(rule.Name)
+			query[rule.NewName] = append(query[rule.NewName], values...)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestApplyRequestRewrites(t *testing.T) {
+	target := &TargetRequest{
+		Headers: http.Header{"Accept": {"a", "b"}, "Cookie": {"c"}, "X-Old": {"1", "2"}},
+		Params:  url.Values{"q": {"1", "2"}},
+	}
+	rules := []models.RewriteRule{
+		{Scope: RewriteScopeRequestHeader, Action: RewriteActionSet, Name: "X-Forwarded-For", Value: "{client_ip}"},
+		{Scope: RewriteScopeRequestHeader, Action: RewriteActionAppend, Name: "Accept", Value: "{key_label}"},
+		{Scope: RewriteScopeRequestHeader, Action: RewriteActionRemove, Name: "cookie"},
+		{Scope: RewriteScopeRequestHeader, Action: RewriteActionRename, Name: "X-Old", NewName: "X-New"},
+		{Scope: RewriteScopeQuery, Action: RewriteActionSet, Name: "trace", Value: "{request_id}"},
+		{Scope: RewriteScopeQuery, Action: RewriteActionRename, Name: "q", NewName: "query"},
+		{Scope: RewriteScopeResponseHeader, Action: RewriteActionRemove, Name: "Accept"}, // 响应头规则不作用于请求
+	}
+	ApplyRequestRewrites(target, rules, RewriteContext{ClientIP: "203.0.113.5", RequestID: "req-1", KeyLabel: "primary"})
+
+	wantHeaders := http.Header{"Accept": {"a", "b", "primary"}, "X-Forwarded-For": {"203.0.113.5"}, "X-New": {"1", "2"}}
+	if !reflect.DeepEqual(target.Headers, wantHeaders) {
+		t.Errorf("headers = %v, want %v", target.Headers, wantHeaders)
+	}
+	wantParams := url.Values{"query": {"1", "2"}, "trace": {"req-1"}}
+	if !reflect.DeepEqual(target.Params, wantParams) {
+		t.Errorf("params = %v, want %v", target.Params, wantParams)
+	}
+}
+
+func TestResponseHeaderRewriter(t *testing.T) {
+	rules := []models.RewriteRule{
+		{Scope: RewriteScopeRequestHeader, Action: RewriteActionSet, Name: "X-Request", Value: "1"},
+		{Scope: RewriteScopeResponseHeader, Action: RewriteActionRemove, Name: "Server"},
+		{Scope: RewriteScopeResponseHeader, Action: RewriteActionSet, Name: "X-Key", Value: "{key_label}"},
+	}
+	header := http.Header{"Server": {"upstream"}, "Content-Type": {"text/plain"}}
+	ResponseHeaderRewriter(rules, RewriteContext{KeyLabel: "primary"})(header)
+
+	want := http.Header{"Content-Type": {"text/plain"}, "X-Key": {"primary"}}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("headers = %v, want %v", header, want)
+	}
+	if ResponseHeaderRewriter(rules[:1], RewriteContext{}) != nil {
+		t.Error("ResponseHeaderRewriter() without response rules should return nil")
+	}
+}
+
+func TestValidateRewriteRules(t *testing.T) {
+	tests := []struct {
+		rule    models.RewriteRule
+		wantErr bool
+	}{
+		{models.RewriteRule{Scope: " Query ", Action: "SET", Name: "a"}, false},
+		{models.RewriteRule{Scope: "cookie", Action: "set", Name: "a"}, true},
+		{models.RewriteRule{Scope: "query", Action: "set"}, true},
+		{models.RewriteRule{Scope: "query", Action: "replace", Name: "a"}, true},
+		{models.RewriteRule{Scope: "query", Action: "rename", Name: "a"}, true},
+	}
+	for _, tt := range tests {
+		config := &models.ProxyConfig{RewriteRules: NormalizeRewriteRules([]models.RewriteRule{tt.rule})}
+		if err := ValidateRewriteRules(config); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateRewriteRules(%+v) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+		}
+	}
+}
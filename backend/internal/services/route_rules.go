@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+)
+
+// 路由规则动作
+const (
+	RouteActionAllow = "allow"
+	RouteActionDeny  = "deny"
+)
+
+// RoutePathPlaceholder 目标路径模板中代表原始子路径的占位符
+const RoutePathPlaceholder = "{path}"
+
+var (
+	// ErrRouteDenied 请求命中了拒绝规则
+	ErrRouteDenied = errors.New("this route is denied by the service configuration")
+	// ErrMethodNotAllowed 没有规则或默认方法允许该请求
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	// ErrInvalidPath 请求路径包含 ".." 段、反斜杠或编码的路径分隔符
+	ErrInvalidPath = errors.New("invalid request path")
+)
+
+// RouteMatch 路由匹配结果
+type RouteMatch struct {
+	Rule    *models.RouteRule // 命中的规则，按默认方法放行时为 nil
+	SubPath string            // 拼接到目标URL的路径，已按段转义
+}
+
+// CheckEscapedPath 拒绝含编码路径分隔符 (%2F、%5C) 的请求路径:
+// 解码后这类字符与真正的分隔符无法区分，上游看到的路径段会与匹配规则时不同
+func CheckEscapedPath(escapedPath string) error {
+	lower := strings.ToLower(escapedPath)
+	if strings.Contains(lower, "%2f") || strings.Contains(lower, "%5c") {
+		return fmt.Errorf("%w: encoded path separators are not allowed", ErrInvalidPath)
+	}
+	return nil
+}
+
+// CleanRoutePath 规范化服务标识符之后的已解码子路径: 拒绝 ".." 段和反斜杠，
+// 合并重复的 "/" 并去掉 "." 段，保留末尾的 "/"。返回值不以 "/" 开头
+func CleanRoutePath(subPath string) (string, error) {
+	if strings.Contains(subPath, "\\") {
+		return "", fmt.Errorf("%w: backslashes are not allowed", ErrInvalidPath)
+	}
+	for _, segment := range strings.Split(subPath, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("%w: '..' segments are not allowed", ErrInvalidPath)
+		}
+	}
+
+	cleaned := strings.TrimPrefix(path.Clean("/"+subPath), "/")
+	if cleaned != "" && strings.HasSuffix(subPath, "/") {
+		cleaned += "/"
+	}
+	return cleaned, nil
+}
+
+// escapeRoutePath 逐段转义已解码的路径，拼接到目标URL后上游解析出的路径与匹配时相同
+func escapeRoutePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// MatchRoute 按顺序匹配配置的路由规则，第一条命中的规则生效
+// 没有规则命中时回退到配置的单一 Method。子路径先经 CleanRoutePath 规范化，
+// 规则匹配的路径与转发到上游的路径一致
+func MatchRoute(proxyConfig *models.ProxyConfig, method, subPath string) (*RouteMatch, error) {
+	method = strings.ToUpper(method)
+	subPath, err := CleanRoutePath(subPath)
+	if err != nil {
+		return nil, err
+	}
+	requestPath := "/" + subPath
+	forwardPath := escapeRoutePath(subPath)
+
+	for i := range proxyConfig.RouteRules {
+		rule := &proxyConfig.RouteRules[i]
+		if !routeMethodMatches(rule.Methods, method) || !utils.MatchPathGlob(rule.Path, requestPath) {
+			continue
+		}
+		if strings.ToLower(rule.Action) == RouteActionDeny {
+			return nil, fmt.Errorf("%w: %s %s", ErrRouteDenied, method, requestPath)
+		}
+
+		match := &RouteMatch{Rule: rule, SubPath: forwardPath}
+		if rule.TargetPath != "" {
+			match.SubPath = strings.ReplaceAll(rule.TargetPath, RoutePathPlaceholder, forwardPath)
+		}
+		return match, nil
+	}
+
+	if proxyConfig.Method != nil && strings.ToUpper(*proxyConfig.Method) == method {
+		return &RouteMatch{SubPath: forwardPath}, nil
+	}
+
+	if len(proxyConfig.RouteRules) > 0 {
+		return nil, fmt.Errorf("%w: no route rule matches %s %s", ErrMethodNotAllowed, method, requestPath)
+	}
+	allowed := ""
+	if proxyConfig.Method != nil {
+		allowed = strings.ToUpper(*proxyConfig.Method)
+	}
+	return nil, fmt.Errorf("%w: this path only accepts %s, but received %s", ErrMethodNotAllowed, allowed, method)
+}
+
+// ValidateRouteRules 校验配置中的路由规则，供保存配置时使用
+func ValidateRouteRules(proxyConfig *models.ProxyConfig) error {
+	for i, rule := range proxyConfig.RouteRules {
+		if rule.Path == "" {
+			return fmt.Errorf("route rule #%d: path is required", i+1)
+		}
+		if err := utils.ValidatePathGlob(rule.Path); err != nil {
+			return fmt.Errorf("route rule #%d: invalid path pattern '%s': %w", i+1, rule.Path, err)
+		}
+		switch strings.ToLower(rule.Action) {
+		case "", RouteActionAllow, RouteActionDeny:
+		default:
+			return fmt.Errorf("route rule #%d: unsupported action '%s'", i+1, rule.Action)
+		}
+		for _, method := range rule.Methods {
+			if method == "" || strings.ContainsAny(method, " \t/") {
+				return fmt.Errorf("route rule #%d: invalid method '%s'", i+1, method)
+			}
+		}
+	}
+	return nil
+}
+
+// NormalizeRouteRules 统一规则中方法和动作的大小写
+func NormalizeRouteRules(rules []models.RouteRule) []models.RouteRule {
+	for i := range rules {
+		for j, method := range rules[i].Methods {
+			rules[i].Methods[j] = strings.ToUpper(strings.TrimSpace(method))
+		}
+		rules[i].Action = strings.ToLower(strings.TrimSpace(rules[i].Action))
+		if rules[i].Action == "" {
+			rules[i].Action = RouteActionAllow
+		}
+	}
+	return rules
+}
+
+func routeMethodMatches(methods []string, method string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if m == "*" || strings.ToUpper(m) == method {
+			return true
+		}
+	}
+	return false
+}
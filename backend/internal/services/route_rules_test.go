@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestMatchRoute(t *testing.T) {
+	get := "GET"
+	proxyConfig := &models.ProxyConfig{
+		Method: &get,
+		RouteRules: []models.RouteRule{
+			{Path: "/admin/**", Action: RouteActionDeny},
+			{Methods: []string{"POST"}, Path: "/public/*", Action: RouteActionAllow},
+			{Path: "/v2/*", Action: RouteActionAllow, TargetPath: "/api/{path}"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		subPath     string
+		wantSubPath string
+		wantErr     error
+	}{
+		{"deny rule", "GET", "admin/users", "", ErrRouteDenied},
+		{"deny rule after cleaning", "GET", "./admin//users/.", "", ErrRouteDenied},
+		{"dot-dot segment", "GET", "public/../admin/users", "", ErrInvalidPath},
+		{"trailing dot-dot", "GET", "public/..", "", ErrInvalidPath},
+		{"backslash", "GET", "public\\..\\admin", "", ErrInvalidPath},
+		{"allow rule", "post", "public/item", "public/item", nil},
+		{"allow rule keeps trailing slash", "POST", "public/item/", "public/item/", nil},
+		{"target path template", "GET", "v2/models", "/api/v2/models", nil},
+		{"escapes matched segments", "POST", "public/a b%2e", "public/a%20b%252e", nil},
+		{"falls back to default method", "GET", "other", "other", nil},
+		{"no rule and wrong method", "DELETE", "other", "", ErrMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := MatchRoute(proxyConfig, tt.method, tt.subPath)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MatchRoute() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MatchRoute() unexpected error: %v", err)
+			}
+			if match.SubPath != tt.wantSubPath {
+				t.Errorf("MatchRoute() SubPath = %q, want %q", match.SubPath, tt.wantSubPath)
+			}
+		})
+	}
+}
+
+func TestCheckEscapedPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"/proxy/svc/public/item", false},
+		{"/proxy/svc/a%20b", false},
+		{"/proxy/svc/public%2Fadmin", true},
+		{"/proxy/svc/public%2fadmin", true},
+		{"/proxy/svc/public%5Cadmin", true},
+	}
+	for _, tt := range tests {
+		err := CheckEscapedPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckEscapedPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("CheckEscapedPath(%q) error = %v, want ErrInvalidPath", tt.path, err)
+		}
+	}
+}
+
+func TestValidateRouteRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []models.RouteRule
+		wantErr bool
+	}{
+		{"valid", []models.RouteRule{{Path: "/users/*", Methods: []string{"GET"}, Action: "deny"}}, false},
+		{"missing path", []models.RouteRule{{Action: "allow"}}, true},
+		{"bad pattern", []models.RouteRule{{Path: "/v[1/*"}}, true},
+		{"bad action", []models.RouteRule{{Path: "/x", Action: "redirect"}}, true},
+		{"bad method", []models.RouteRule{{Path: "/x", Methods: []string{"GET /"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRouteRules(&models.ProxyConfig{RouteRules: tt.rules})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRouteRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+// RequestSigner 在请求即将发出前对其签名
+// 签名必须在所有请求头、查询参数和请求体确定之后进行
+type RequestSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	sigV4DateFormat = "20060102"
+)
+
+// SigV4Signer AWS Signature Version 4 签名器
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+
+	now func() time.Time
+}
+
+// NewSigV4Signer 根据密钥的结构化凭证创建SigV4签名器
+func NewSigV4Signer(creds *models.KeyCredentials) (*SigV4Signer, error) {
+	if creds == nil {
+		return nil, fmt.Errorf("AWS SigV4 key has no credentials")
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS SigV4 key requires access_key_id and secret_access_key")
+	}
+	if creds.Region == "" || creds.Service == "" {
+		return nil, fmt.Errorf("AWS SigV4 key requires region and service")
+	}
+
+	return &SigV4Signer{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Region:          creds.Region,
+		Service:         creds.Service,
+		now:             time.Now,
+	}, nil
+}
+
+// Sign 对最终的URL、请求头和请求体哈希进行签名，并写入 Authorization 头
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	t := s.now().UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	date := t.Format(sigV4DateFormat)
+
+	payloadHash := sha256Hex(body)
+
+	// 清除客户端可能带来的旧签名信息
+	req.Header.Del("Authorization")
+	req.Header.Del("X-Amz-Security-Token")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	// 让实际发送的路径与签名使用的编码一致
+	encodedPath := awsEncodePath(req.URL.Path)
+	req.URL.RawPath = encodedPath
+
+	canonicalURI := encodedPath
+	if s.Service != "s3" {
+		// 除S3外，其他服务要求对路径再编码一次
+		canonicalURI = awsEncodePath(encodedPath)
+	}
+
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQueryString(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.Region)
+	signingKey = hmacSHA256(signingKey, s.Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+
+	return nil
+}
+
+// canonicalHeaders 生成参与签名的请求头列表及其规范化内容
+// 签名 host、content-type、content-md5 以及所有 x-amz-* 请求头
+func (s *SigV4Signer) canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string]string{"host": strings.TrimSpace(host)}
+	for key, vals := range req.Header {
+		lower := strings.ToLower(key)
+		if lower == "content-type" || lower == "content-md5" || strings.HasPrefix(lower, "x-amz-") {
+			trimmed := make([]string, 0, len(vals))
+			for _, v := range vals {
+				trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+			}
+			values[lower] = strings.Join(trimmed, ",")
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString(name)
+		builder.WriteString(":")
+		builder.WriteString(values[name])
+		builder.WriteString("\n")
+	}
+
+	return strings.Join(names, ";"), builder.String()
+}
+
+// canonicalQueryString 按参数名和值排序并编码查询参数
+func canonicalQueryString(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+	for key, vals := range query {
+		encodedKey := awsURIEncode(key, true)
+		for _, v := range vals {
+			pairs = append(pairs, encodedKey+"="+awsURIEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEncodePath 按AWS规则逐段编码路径，保留 '/'
+func awsEncodePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return awsURIEncode(path, false)
+}
+
+// awsURIEncode 按AWS规则进行URI编码：只保留 A-Z a-z 0-9 - _ . ~ 不编码
+func awsURIEncode(value string, encodeSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'),
+			c == '-', c == '_', c == '.', c == '~':
+			builder.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			builder.WriteByte(c)
+		default:
+			builder.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return builder.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+)
+
+// 支持的最低TLS版本
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// BuildTLSConfig 根据配置的TLS设置构造 tls.Config，settings 中的客户端私钥必须已解密
+func BuildTLSConfig(settings *models.TLSSettings) (*tls.Config, error) {
+	if settings == nil {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         settings.ServerName,
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
+
+	if settings.MinVersion != "" {
+		version, ok := tlsVersions[settings.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS min_version '%s'", settings.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if strings.TrimSpace(settings.CABundle) != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(settings.CABundle)) {
+			return nil, fmt.Errorf("TLS ca_bundle contains no valid PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if settings.ClientCert != "" || settings.ClientKey != "" {
+		if settings.ClientCert == "" || settings.ClientKey == "" {
+			return nil, fmt.Errorf("TLS client_cert and client_key must be provided together")
+		}
+		certificate, err := tls.X509KeyPair([]byte(settings.ClientCert), []byte(settings.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS client certificate or key: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// PrepareTLSSettings 校验并加密将要保存的TLS设置
+// 更新时如果未提供新的客户端私钥但仍保留客户端证书，则沿用已保存的私钥
+func PrepareTLSSettings(cfg *config.Config, settings, existing *models.TLSSettings) (*models.TLSSettings, error) {
+	if settings == nil {
+		return nil, nil
+	}
+
+	prepared := *settings
+	if prepared.ClientKey == "" && prepared.ClientCert != "" && existing != nil {
+		prepared.ClientKey = existing.ClientKey
+	}
+
+	// 用明文私钥校验整套设置
+	plain := prepared
+	if utils.IsEncrypted(plain.ClientKey) {
+		key, err := utils.DecryptSecret(plain.ClientKey, cfg.GetEncryptionKey())
+		if err != nil {
+			return nil, err
+		}
+		plain.ClientKey = key
+	}
+	if _, err := BuildTLSConfig(&plain); err != nil {
+		return nil, err
+	}
+
+	if prepared.ClientKey != "" && !utils.IsEncrypted(prepared.ClientKey) {
+		encrypted, err := utils.EncryptSecret(prepared.ClientKey, cfg.GetEncryptionKey())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt TLS client key: %w", err)
+		}
+		prepared.ClientKey = encrypted
+	}
+
+	return &prepared, nil
+}
+
+// decryptTLSSettings 返回客户端私钥已解密的TLS设置副本
+func decryptTLSSettings(cfg *config.Config, settings *models.TLSSettings) (*models.TLSSettings, error) {
+	if settings == nil {
+		return nil, nil
+	}
+
+	decrypted := *settings
+	if utils.IsEncrypted(decrypted.ClientKey) {
+		key, err := utils.DecryptSecret(decrypted.ClientKey, cfg.GetEncryptionKey())
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt TLS client key: %w", err)
+		}
+		decrypted.ClientKey = key
+	}
+	return &decrypted, nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+	"api-key-rotator/backend/internal/utils"
+)
+
+// newTestKeyPair 生成PEM格式的自签名证书和私钥
+func newTestKeyPair(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	certPEM, keyPEM := newTestKeyPair(t)
+	_, otherKeyPEM := newTestKeyPair(t)
+
+	tlsConfig, err := BuildTLSConfig(&models.TLSSettings{MinVersion: "1.3", ServerName: "api.internal", ClientCert: certPEM, ClientKey: keyPEM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS13 || tlsConfig.ServerName != "api.internal" || len(tlsConfig.Certificates) != 1 {
+		t.Errorf("BuildTLSConfig() = MinVersion %x, ServerName %q, %d certificates", tlsConfig.MinVersion, tlsConfig.ServerName, len(tlsConfig.Certificates))
+	}
+
+	for _, settings := range []*models.TLSSettings{
+		{MinVersion: "2.0"},
+		{CABundle: "not pem"},
+		{ClientCert: certPEM},
+		{ClientCert: certPEM, ClientKey: otherKeyPEM},
+	} {
+		if _, err := BuildTLSConfig(settings); err == nil {
+			t.Errorf("BuildTLSConfig(%+v) should fail", settings)
+		}
+	}
+}
+
+func TestBuildTLSConfigTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := []struct {
+		settings *models.TLSSettings
+		wantErr  bool
+	}{
+		{&models.TLSSettings{}, true}, // 只有系统根证书
+		{&models.TLSSettings{CABundle: caPEM}, false},
+		{&models.TLSSettings{InsecureSkipVerify: true}, false},
+	}
+	for _, tt := range tests {
+		tlsConfig, err := BuildTLSConfig(tt.settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("request with %+v error = %v, wantErr %v", tt.settings, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPrepareTLSSettings(t *testing.T) {
+	cfg := &config.Config{EncryptionKey: "test-encryption-key"}
+	certPEM, keyPEM := newTestKeyPair(t)
+
+	prepared, err := PrepareTLSSettings(cfg, &models.TLSSettings{ClientCert: certPEM, ClientKey: keyPEM}, nil)
+	if err != nil {
+		t.Fatalf("PrepareTLSSettings() error = %v", err)
+	}
+	if !utils.IsEncrypted(prepared.ClientKey) {
+		t.Fatal("client key was stored in plaintext")
+	}
+
+	// 更新时未提供私钥则沿用已保存的私钥，且不会重复加密
+	updated, err := PrepareTLSSettings(cfg, &models.TLSSettings{ClientCert: certPEM, MinVersion: "1.2"}, prepared)
+	if err != nil {
+		t.Fatalf("PrepareTLSSettings() update error = %v", err)
+	}
+	if updated.ClientKey != prepared.ClientKey {
+		t.Error("existing client key should be kept")
+	}
+
+	decrypted, err := decryptTLSSettings(cfg, updated)
+	if err != nil || decrypted.ClientKey != keyPEM {
+		t.Errorf("decryptTLSSettings() = %v, %v; want the original key", decrypted, err)
+	}
+
+	if _, err := PrepareTLSSettings(cfg, &models.TLSSettings{ClientCert: certPEM}, nil); err == nil {
+		t.Error("PrepareTLSSettings() should reject a certificate without key")
+	}
+	if _, err := decryptTLSSettings(&config.Config{EncryptionKey: "other"}, updated); err == nil {
+		t.Error("decryptTLSSettings() should fail with another encryption key")
+	}
+}
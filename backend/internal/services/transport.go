@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/logger"
+	"api-key-rotator/backend/internal/models"
+)
+
+// ErrUpstreamTimeout 上游在配置的时间内没有响应
+var ErrUpstreamTimeout = errors.New("upstream request timed out")
+
+// UpstreamOptions 转发上游请求时使用的连接参数
+type UpstreamOptions struct {
+	ConnectTimeout        time.Duration       // 建立TCP连接的超时
+	TLSHandshakeTimeout   time.Duration       // TLS握手超时
+	ResponseHeaderTimeout time.Duration       // 发送请求后等待响应头（首字节）的超时
+	TotalTimeout          time.Duration       // 整个请求（包括读取响应体）的超时，0 表示不限制
+	StreamIdleTimeout     time.Duration       // 读取响应体时两次收到数据之间的最长间隔，0 表示不限制
+	EgressProxyURL        string              // 出口代理，为空时使用环境变量中的代理设置
+	TLS                   *models.TLSSettings // 上游TLS设置，客户端私钥已解密
+
+	configID int32 // 连接池所属的配置，配置变更时据此回收
+	keyID    int32 // 密钥覆盖了出口代理时连接池归属该密钥，否则为 0
+}
+
+// transportKey 连接池缓存的键: 所属的配置和密钥，加上连接参数的摘要，
+// 参数本身（包括解密后的TLS私钥）不保存在键中
+type transportKey struct {
+	configID    int32
+	keyID       int32
+	fingerprint [sha256.Size]byte
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[transportKey]*http.Transport)
+)
+
+// 支持的出口代理协议
+var egressProxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// ResolveUpstreamOptions 合并配置上的超时设置，连接、TLS握手、响应头和读取空闲超时未设置时使用全局 PROXY_TIMEOUT
+// 总超时默认不限制，流式响应只要持续有数据就不会被中断。
+// 密钥上的出口代理优先于配置上的出口代理
+func ResolveUpstreamOptions(cfg *config.Config, proxyConfig *models.ProxyConfig, apiKey *models.APIKey) (UpstreamOptions, error) {
+	defaultTimeout := time.Duration(cfg.ProxyTimeout) * time.Second
+
+	egressProxyURL := ""
+	if proxyConfig.EgressProxyURL != nil {
+		egressProxyURL = strings.TrimSpace(*proxyConfig.EgressProxyURL)
+	}
+	if apiKey != nil && apiKey.EgressProxyURL != nil && strings.TrimSpace(*apiKey.EgressProxyURL) != "" {
+		egressProxyURL = strings.TrimSpace(*apiKey.EgressProxyURL)
+	}
+
+	tlsSettings, err := decryptTLSSettings(cfg, proxyConfig.TLS)
+	if err != nil {
+		return UpstreamOptions{}, err
+	}
+
+	var keyID int32
+	if apiKey != nil && apiKey.EgressProxyURL != nil && strings.TrimSpace(*apiKey.EgressProxyURL) != "" {
+		keyID = apiKey.ID
+	}
+
+	return UpstreamOptions{
+		configID:              proxyConfig.ID,
+		keyID:                 keyID,
+		EgressProxyURL:        egressProxyURL,
+		TLS:                   tlsSettings,
+		ConnectTimeout:        secondsOrDefault(proxyConfig.ConnectTimeout, defaultTimeout),
+		TLSHandshakeTimeout:   secondsOrDefault(proxyConfig.TLSHandshakeTimeout, defaultTimeout),
+		ResponseHeaderTimeout: secondsOrDefault(proxyConfig.ResponseHeaderTimeout, defaultTimeout),
+		TotalTimeout:          secondsOrDefault(proxyConfig.TotalTimeout, 0),
+		StreamIdleTimeout:     secondsOrDefault(proxyConfig.StreamIdleTimeout, defaultTimeout),
+	}, nil
+}
+
+// ValidateUpstreamTimeouts 校验配置中的超时设置，供保存配置时使用
+func ValidateUpstreamTimeouts(proxyConfig *models.ProxyConfig) error {
+	timeouts := map[string]*int{
+		"connect_timeout":         proxyConfig.ConnectTimeout,
+		"tls_handshake_timeout":   proxyConfig.TLSHandshakeTimeout,
+		"response_header_timeout": proxyConfig.ResponseHeaderTimeout,
+		"total_timeout":           proxyConfig.TotalTimeout,
+		"stream_idle_timeout":     proxyConfig.StreamIdleTimeout,
+	}
+	for name, value := range timeouts {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// ValidateEgressProxyURL 校验出口代理地址，供保存配置和密钥时使用
+func ValidateEgressProxyURL(rawURL *string) error {
+	if rawURL == nil || strings.TrimSpace(*rawURL) == "" {
+		return nil
+	}
+	_, err := parseEgressProxyURL(strings.TrimSpace(*rawURL))
+	return err
+}
+
+// Transport 返回与这些参数对应的共享连接池，相同参数（包括出口代理）的请求复用同一个 http.Transport
+func (o UpstreamOptions) Transport() (*http.Transport, error) {
+	key := transportKey{configID: o.configID, keyID: o.keyID, fingerprint: o.fingerprint()}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	if transport, ok := transports[key]; ok {
+		return transport, nil
+	}
+
+	// 直连时在拨号时校验目标IP；经出口代理或环境变量代理时，先解析并校验目标主机再交给代理
+	dialer := &net.Dialer{
+		Timeout:   o.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	var proxyURL *url.URL
+	if o.EgressProxyURL != "" {
+		parsed, err := parseEgressProxyURL(o.EgressProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		proxyURL = parsed
+	}
+	proxy, dialContext := CurrentOutboundPolicy().proxyRouting(proxyURL, dialer)
+
+	tlsConfig, err := BuildTLSConfig(o.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          512,
+		MaxIdleConnsPerHost:   64,
+		IdleConnTimeout:       90 * time.Second,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   o.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		// 保留客户端自己的 Accept-Encoding，不由代理透明解压
+		DisableCompression: true,
+	}
+	transports[key] = transport
+	return transport, nil
+}
+
+// fingerprint 返回影响连接池行为的参数的摘要
+func (o UpstreamOptions) fingerprint() [sha256.Size]byte {
+	data, _ := json.Marshal(struct {
+		ConnectTimeout        time.Duration
+		TLSHandshakeTimeout   time.Duration
+		ResponseHeaderTimeout time.Duration
+		EgressProxyURL        string
+		TLS                   *models.TLSSettings
+	}{o.ConnectTimeout, o.TLSHandshakeTimeout, o.ResponseHeaderTimeout, o.EgressProxyURL, o.TLS})
+	return sha256.Sum256(data)
+}
+
+// EvictUpstreamTransports 关闭并移除配置的连接池，keyID 不为 0 时只移除归属该密钥的连接池
+// 配置或密钥变更、删除后调用；正在进行的请求不受影响，结束后连接随连接池一起释放
+func EvictUpstreamTransports(configID, keyID int32) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	for key, transport := range transports {
+		if key.configID != configID || (keyID != 0 && key.keyID != keyID) {
+			continue
+		}
+		transport.CloseIdleConnections()
+		delete(transports, key)
+	}
+}
+
+// Client 返回使用共享连接池的上游客户端
+func (o UpstreamOptions) Client() (*http.Client, error) {
+	transport, err := o.Transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: transport,
+		// 重定向目标同样需要符合出站策略
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return CurrentOutboundPolicy().CheckURL(req.URL)
+		},
+	}, nil
+}
+
+// SendUpstream 发送目标请求
+// 请求上下文继承自客户端请求，客户端断开时上游请求随之取消；总超时和读取空闲超时在此基础上生效。
+// 调用方读取完响应体后必须关闭响应体
+func SendUpstream(ctx context.Context, target *TargetRequest) (*http.Response, error) {
+	parent := ctx
+	options := target.Upstream
+	client, err := options.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	if options.TotalTimeout > 0 {
+		ctx, cancel = contextWithTimeout(ctx, cancel, options.TotalTimeout)
+	}
+
+	req, err := target.BuildHTTPRequest(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := CurrentOutboundPolicy().CheckURL(req.URL); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		if IsUpstreamTimeout(err) {
+			return nil, fmt.Errorf("%w: %v", ErrUpstreamTimeout, err)
+		}
+		return nil, err
+	}
+
+	// 凭证被上游拒绝时刷新后重试一次；以流的方式转发的请求体无法重放
+	if resp.StatusCode == http.StatusUnauthorized && target.RefreshAuth != nil && target.BodyStream == nil {
+		refresh := target.RefreshAuth
+		target.RefreshAuth = nil
+		if err := refresh(); err != nil {
+			logger.Warningf("Failed to refresh upstream credentials after 401: %v", err)
+		} else {
+			resp.Body.Close()
+			cancel()
+			logger.Infof("Upstream returned 401, retrying with refreshed credentials")
+			return SendUpstream(parent, target)
+		}
+	}
+
+	resp.Body = newIdleTimeoutBody(resp.Body, options.StreamIdleTimeout, cancel)
+	return resp, nil
+}
+
+// IsUpstreamTimeout 判断错误是否由上游超时引起（不包括客户端主动断开）
+func IsUpstreamTimeout(err error) bool {
+	if errors.Is(err, ErrUpstreamTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// contextWithTimeout 在已有的可取消上下文上增加超时，返回的取消函数会同时释放两者
+func contextWithTimeout(ctx context.Context, cancel context.CancelFunc, timeout time.Duration) (context.Context, context.CancelFunc) {
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, timeout)
+	return timeoutCtx, func() {
+		timeoutCancel()
+		cancel()
+	}
+}
+
+// idleTimeoutBody 在响应体长时间没有数据时取消请求，关闭时释放请求上下文
+type idleTimeoutBody struct {
+	body     io.ReadCloser
+	cancel   context.CancelFunc
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) io.ReadCloser {
+	b := &idleTimeoutBody{body: body, cancel: cancel, timeout: timeout}
+	if timeout > 0 {
+		b.timer = time.AfterFunc(timeout, func() {
+			b.timedOut.Store(true)
+			cancel()
+		})
+	}
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if b.timer != nil && n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && err != io.EOF && b.timedOut.Load() {
+		return n, fmt.Errorf("%w: no data received for %s", ErrUpstreamTimeout, b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
+
+// parseEgressProxyURL 解析出口代理地址，只接受 http、https、socks5 和 socks5h
+func parseEgressProxyURL(rawURL string) (*url.URL, error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid egress proxy URL: %w", err)
+	}
+	if !egressProxySchemes[strings.ToLower(proxyURL.Scheme)] {
+		return nil, fmt.Errorf("unsupported egress proxy scheme '%s', expected http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("egress proxy URL must include a host")
+	}
+	return proxyURL, nil
+}
+
+func secondsOrDefault(seconds *int, defaultValue time.Duration) time.Duration {
+	if seconds == nil {
+		return defaultValue
+	}
+	return time.Duration(*seconds) * time.Second
+}
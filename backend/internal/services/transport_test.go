@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestResolveUpstreamOptionsTimeouts(t *testing.T) {
+	cfg := &config.Config{ProxyTimeout: 30}
+	seconds := func(n int) *int { return &n }
+
+	tests := []struct {
+		name        string
+		proxyConfig models.ProxyConfig
+		want        UpstreamOptions
+	}{
+		{
+			name: "defaults from PROXY_TIMEOUT",
+			want: UpstreamOptions{
+				ConnectTimeout:        30 * time.Second,
+				TLSHandshakeTimeout:   30 * time.Second,
+				ResponseHeaderTimeout: 30 * time.Second,
+				StreamIdleTimeout:     30 * time.Second,
+			},
+		},
+		{
+			name: "explicit values override defaults",
+			proxyConfig: models.ProxyConfig{
+				ConnectTimeout:        seconds(5),
+				ResponseHeaderTimeout: seconds(10),
+				TotalTimeout:          seconds(60),
+				StreamIdleTimeout:     seconds(120),
+			},
+			want: UpstreamOptions{
+				ConnectTimeout:        5 * time.Second,
+				TLSHandshakeTimeout:   30 * time.Second,
+				ResponseHeaderTimeout: 10 * time.Second,
+				TotalTimeout:          60 * time.Second,
+				StreamIdleTimeout:     120 * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveUpstreamOptions(cfg, &tt.proxyConfig, nil)
+			if err != nil {
+				t.Fatalf("ResolveUpstreamOptions() error = %v", err)
+			}
+			if got.ConnectTimeout != tt.want.ConnectTimeout ||
+				got.TLSHandshakeTimeout != tt.want.TLSHandshakeTimeout ||
+				got.ResponseHeaderTimeout != tt.want.ResponseHeaderTimeout ||
+				got.TotalTimeout != tt.want.TotalTimeout ||
+				got.StreamIdleTimeout != tt.want.StreamIdleTimeout {
+				t.Errorf("ResolveUpstreamOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendUpstreamStreamsPastProxyTimeout(t *testing.T) {
+	// 响应持续 1.5 秒，超过 PROXY_TIMEOUT，但每次数据间隔都在空闲超时之内
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(300 * time.Millisecond)
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{ProxyTimeout: 1}
+	proxyConfig := &models.ProxyConfig{ID: 903}
+	defer EvictUpstreamTransports(proxyConfig.ID, 0)
+	options, err := ResolveUpstreamOptions(cfg, proxyConfig, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &TargetRequest{Method: http.MethodGet, URL: upstream.URL, Headers: http.Header{}, Params: url.Values{}, Upstream: options}
+
+	resp, err := SendUpstream(context.Background(), target)
+	if err != nil {
+		t.Fatalf("SendUpstream() error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading stream failed after %d bytes: %v", len(body), err)
+	}
+	if !strings.Contains(string(body), "data: 4") {
+		t.Errorf("stream truncated: %q", body)
+	}
+}
+
+func TestValidateUpstreamTimeouts(t *testing.T) {
+	negative := -1
+	zero := 0
+	if err := ValidateUpstreamTimeouts(&models.ProxyConfig{TotalTimeout: &zero}); err != nil {
+		t.Errorf("zero timeout should be valid: %v", err)
+	}
+	if err := ValidateUpstreamTimeouts(&models.ProxyConfig{ConnectTimeout: &negative}); err == nil {
+		t.Error("negative timeout should be rejected")
+	}
+}
+
+func TestUpstreamTransportCache(t *testing.T) {
+	cfg := &config.Config{ProxyTimeout: 30}
+	egress := "http://127.0.0.1:3128"
+	configA := &models.ProxyConfig{ID: 901}
+	configB := &models.ProxyConfig{ID: 902}
+	sharedKey := &models.APIKey{ID: 11, ProxyConfigID: 901}
+	egressKey := &models.APIKey{ID: 12, ProxyConfigID: 901, EgressProxyURL: &egress}
+	defer EvictUpstreamTransports(configA.ID, 0)
+	defer EvictUpstreamTransports(configB.ID, 0)
+
+	transportFor := func(proxyConfig *models.ProxyConfig, apiKey *models.APIKey) *http.Transport {
+		t.Helper()
+		options, err := ResolveUpstreamOptions(cfg, proxyConfig, apiKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transport, err := options.Transport()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return transport
+	}
+
+	base := transportFor(configA, nil)
+	if transportFor(configA, sharedKey) != base {
+		t.Error("keys without their own egress proxy should share the config's transport")
+	}
+	if transportFor(configA, egressKey) == base {
+		t.Error("a key with its own egress proxy should get its own transport")
+	}
+	if transportFor(configB, nil) == base {
+		t.Error("different configs should not share a transport")
+	}
+
+	keyTransport := transportFor(configA, egressKey)
+	EvictUpstreamTransports(configA.ID, egressKey.ID)
+	if transportFor(configA, nil) != base {
+		t.Error("evicting a key should keep the config's transport")
+	}
+	if transportFor(configA, egressKey) == keyTransport {
+		t.Error("evicted key transport was reused")
+	}
+
+	EvictUpstreamTransports(configA.ID, 0)
+	if transportFor(configA, nil) == base {
+		t.Error("evicted config transport was reused")
+	}
+	if got := countTransports(configB.ID); got != 1 {
+		t.Errorf("evicting config A removed config B's transport, %d left", got)
+	}
+}
+
+func countTransports(configID int32) int {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	n := 0
+	for key := range transports {
+		if key.configID == configID {
+			n++
+		}
+	}
+	return n
+}
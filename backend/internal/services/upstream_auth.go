@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"api-key-rotator/backend/internal/models"
+)
+
+// 上游密钥类型
+const (
+	KeyTypeAPIKey                  = "api_key"                   // 普通密钥，按注入规则写入请求
+	KeyTypeAWSSigV4                = "aws_sigv4"                 // AWS访问密钥对，使用SigV4对请求签名
+	KeyTypeOAuth2ClientCredentials = "oauth2_client_credentials" // OAuth2客户端凭证，换取访问令牌后注入
+)
+
+// OAuth2 客户端认证方式
+const (
+	OAuth2ClientAuthBasic = "basic" // 通过 HTTP Basic 发送 client_id/client_secret
+	OAuth2ClientAuthBody  = "body"  // 通过表单字段发送 client_id/client_secret
+)
+
+// ApplyUpstreamAuth 在密钥轮询之后，根据密钥类型为目标请求配置上游认证
+func (h *BaseProxyHandler) ApplyUpstreamAuth(target *TargetRequest, proxyConfig *models.ProxyConfig, apiKey *models.APIKey, injection CredentialInjection) error {
+	switch apiKey.KeyType {
+	case "", KeyTypeAPIKey:
+		return injection.Apply(target, apiKey.KeyValue)
+	case KeyTypeOAuth2ClientCredentials:
+		token, err := h.GetOAuth2AccessToken(proxyConfig, apiKey)
+		if err != nil {
+			return err
+		}
+		// 未显式配置注入位置时，以 Bearer 令牌的形式注入
+		if proxyConfig.APIKeyLocation == nil || *proxyConfig.APIKeyLocation == "" {
+			injection = CredentialInjection{
+				Location: KeyLocationHeader,
+				Name:     "Authorization",
+				Template: "Bearer " + KeyPlaceholder,
+			}
+		}
+		// 缓存的令牌可能已被上游吊销: 收到 401 时丢弃缓存、换取新令牌后重试
+		target.RefreshAuth = func() error {
+			h.InvalidateOAuth2AccessToken(apiKey)
+			fresh, err := h.GetOAuth2AccessToken(proxyConfig, apiKey)
+			if err != nil {
+				return err
+			}
+			return injection.Reapply(target, token, fresh)
+		}
+		return injection.Apply(target, token)
+	case KeyTypeAWSSigV4:
+		creds, err := decryptKeyCredentials(h.cfg, apiKey.Credentials)
+		if err != nil {
+			return err
+		}
+		signer, err := NewSigV4Signer(creds)
+		if err != nil {
+			return err
+		}
+		target.Signer = signer
+		return nil
+	default:
+		return fmt.Errorf("unsupported API key type '%s'", apiKey.KeyType)
+	}
+}
+
+// ValidateAPIKey 校验密钥的类型与结构化凭证，并在需要时补全 KeyValue
+func ValidateAPIKey(apiKey *models.APIKey, proxyConfig *models.ProxyConfig) error {
+	if err := ValidateEgressProxyURL(apiKey.EgressProxyURL); err != nil {
+		return err
+	}
+
+	switch apiKey.KeyType {
+	case "":
+		apiKey.KeyType = KeyTypeAPIKey
+		fallthrough
+	case KeyTypeAPIKey:
+		if apiKey.KeyValue == "" {
+			return fmt.Errorf("key_value is required")
+		}
+	case KeyTypeAWSSigV4:
+		if strings.ToUpper(proxyConfig.ConfigType) != "GENERIC" {
+			return fmt.Errorf("key type '%s' is only supported by generic configs", apiKey.KeyType)
+		}
+		if _, err := NewSigV4Signer(apiKey.Credentials); err != nil {
+			return err
+		}
+		if apiKey.KeyValue == "" {
+			apiKey.KeyValue = apiKey.Credentials.AccessKeyID
+		}
+	case KeyTypeOAuth2ClientCredentials:
+		creds := apiKey.Credentials
+		if creds == nil || creds.ClientID == "" || creds.ClientSecret == "" {
+			return fmt.Errorf("OAuth2 key requires client_id and client_secret")
+		}
+		if creds.ClientAuth != "" && creds.ClientAuth != OAuth2ClientAuthBasic && creds.ClientAuth != OAuth2ClientAuthBody {
+			return fmt.Errorf("unsupported client_auth '%s'", creds.ClientAuth)
+		}
+		tokenURL := resolveOAuth2TokenURL(proxyConfig, creds)
+		if tokenURL == "" {
+			return fmt.Errorf("OAuth2 key requires a token URL on the key or its config")
+		}
+		if err := ValidateDestination("token_url", tokenURL); err != nil {
+			return err
+		}
+		if apiKey.KeyValue == "" {
+			apiKey.KeyValue = creds.ClientID
+		}
+	default:
+		return fmt.Errorf("unsupported key_type '%s'", apiKey.KeyType)
+	}
+	return nil
+}
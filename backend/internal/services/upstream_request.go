@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrRequestBodyTooLarge 请求体超过配置的大小上限
+var ErrRequestBodyTooLarge = errors.New("request body too large")
+
+// BuildHTTPRequest 将目标请求组装为最终发出的 HTTP 请求，并在需要时签名
+// 查询参数会替换目标URL中同名参数的全部值，请求头保留每个头部的全部值
+func (t *TargetRequest) BuildHTTPRequest(ctx context.Context) (*http.Request, error) {
+	targetURL, err := url.Parse(t.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+
+	if len(t.Params) > 0 {
+		query := targetURL.Query()
+		for key, values := range t.Params {
+			query[key] = values
+		}
+		targetURL.RawQuery = query.Encode()
+	}
+
+	var body io.Reader
+	contentLength := int64(len(t.Body))
+	if t.BodyStream != nil {
+		body = t.BodyStream
+		contentLength = t.ContentLength
+	} else if len(t.Body) > 0 {
+		body = bytes.NewReader(t.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, t.Method, targetURL.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.ContentLength = contentLength
+		if contentLength == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
+	for key, values := range t.Headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if len(t.Trailer) > 0 {
+		req.Trailer = t.Trailer
+	}
+
+	// 对最终请求签名（如 AWS SigV4）
+	if t.Signer != nil {
+		if err := t.Signer.Sign(req, t.Body); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+// NeedsBufferedBody 判断上游认证是否需要读取完整的请求体（写入JSON字段、对请求体签名，
+// 或 OAuth2 令牌被拒绝后需要重放请求）
+func NeedsBufferedBody(apiKey *models.APIKey, injection CredentialInjection) bool {
+	return injection.Location == KeyLocationBody || apiKey.KeyType == KeyTypeAWSSigV4 ||
+		apiKey.KeyType == KeyTypeOAuth2ClientCredentials
+}
+
+// MaxBodyBytes 返回配置的请求体大小上限（字节），配置级别的设置优先于全局设置，0 表示不限制
+func MaxBodyBytes(cfg *config.Config, proxyConfig *models.ProxyConfig) int64 {
+	sizeMB := cfg.ProxyMaxBodySizeMB
+	if proxyConfig.MaxBodySizeMB != nil {
+		sizeMB = *proxyConfig.MaxBodySizeMB
+	}
+	if sizeMB <= 0 {
+		return 0
+	}
+	return int64(sizeMB) << 20
+}
+
+// LimitRequestBody 限制客户端请求体的大小，已声明的长度超限时直接返回错误，
+// 否则在读取超过上限时返回 *http.MaxBytesError
+func LimitRequestBody(c *gin.Context, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	if c.Request.ContentLength > limit {
+		return fmt.Errorf("%w: limit is %d bytes", ErrRequestBodyTooLarge, limit)
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	return nil
+}
+
+// IsRequestBodyTooLarge 判断错误是否由请求体超限引起
+func IsRequestBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.Is(err, ErrRequestBodyTooLarge) || errors.As(err, &maxBytesErr)
+}
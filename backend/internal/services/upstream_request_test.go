@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"api-key-rotator/backend/internal/config"
+	"api-key-rotator/backend/internal/models"
+)
+
+func TestBuildHTTPRequest(t *testing.T) {
+	target := &TargetRequest{
+		Method:  http.MethodGet,
+		URL:     "https://example.com/v1?a=1&a=2&b=3",
+		Headers: http.Header{"Accept": {"a", "b"}},
+		Params:  url.Values{"a": {"x", "y"}},
+		Body:    []byte(`{"a":1}`),
+	}
+	req, err := target.BuildHTTPRequest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 参数替换同名的全部值，多值头部原样保留
+	if want := (url.Values{"a": {"x", "y"}, "b": {"3"}}); !reflect.DeepEqual(req.URL.Query(), want) {
+		t.Errorf("query = %v, want %v", req.URL.Query(), want)
+	}
+	if got := req.Header.Values("Accept"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Accept = %v, want both values", got)
+	}
+	if req.ContentLength != 7 {
+		t.Errorf("ContentLength = %d, want 7", req.ContentLength)
+	}
+
+	// 流式请求体优先于缓冲的请求体，长度未知时为 -1
+	target.BodyStream = strings.NewReader("streamed")
+	target.ContentLength = -1
+	req, err = target.BuildHTTPRequest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != "streamed" || req.ContentLength != -1 {
+		t.Errorf("body = %q, ContentLength = %d, want streamed body of unknown length", body, req.ContentLength)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	size := func(mb int) *int { return &mb }
+	tests := []struct {
+		global int
+		config *int
+		want   int64
+	}{
+		{10, nil, 10 << 20},
+		{10, size(1), 1 << 20},
+		{10, size(0), 0},
+		{0, nil, 0},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{ProxyMaxBodySizeMB: tt.global}
+		if got := MaxBodyBytes(cfg, &models.ProxyConfig{MaxBodySizeMB: tt.config}); got != tt.want {
+			t.Errorf("MaxBodyBytes(%d, %v) = %d, want %d", tt.global, tt.config, got, tt.want)
+		}
+	}
+}
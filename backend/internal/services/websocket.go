@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-key-rotator/backend/internal/infrastructure/cache"
+	"api-key-rotator/backend/internal/logger"
+	"api-key-rotator/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// websocketHandshakeHeaders 由 websocket 客户端自行生成、不能从客户端透传的握手头
+var websocketHandshakeHeaders = []string{
+	"Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions",
+}
+
+// websocketUpgrader 客户端侧的升级器，访问控制已由代理密钥完成，因此不校验 Origin
+var websocketUpgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// WebSocketCounterKey 返回指定配置的 WebSocket 统计缓存键，field 为 active、total、bytes_in、bytes_out、messages_in 或 messages_out
+func WebSocketCounterKey(configID int32, field string) string {
+	return fmt.Sprintf("proxy_config:%d:websocket:%s", configID, field)
+}
+
+// IsWebSocketUpgrade 判断客户端请求是否为 WebSocket 升级请求
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
+
+// ProxyWebSocket 与上游建立 WebSocket 连接后升级客户端连接，并双向转发消息直到任一方关闭
+// 上游拒绝握手时将其响应原样返回给客户端；握手成功前的错误返回给调用方处理
+func ProxyWebSocket(c *gin.Context, cacheClient cache.CacheInterface, proxyConfig *models.ProxyConfig, target *TargetRequest) error {
+	// 复用普通请求的组装逻辑得到最终的URL和握手头（包括注入的密钥和签名）
+	target.Method = http.MethodGet
+	target.Body, target.BodyStream = nil, nil
+	for _, name := range websocketHandshakeHeaders {
+		target.Headers.Del(name)
+	}
+	req, err := target.BuildHTTPRequest(c.Request.Context())
+	if err != nil {
+		return err
+	}
+
+	if err := CurrentOutboundPolicy().CheckURL(req.URL); err != nil {
+		return err
+	}
+
+	upstreamURL := *req.URL
+	switch upstreamURL.Scheme {
+	case "http":
+		upstreamURL.Scheme = "ws"
+	case "https":
+		upstreamURL.Scheme = "wss"
+	}
+
+	dialer, err := newWebSocketDialer(target.Upstream)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Opening WebSocket to: %s", upstreamURL.Redacted())
+
+	upstreamConn, resp, err := dialer.DialContext(c.Request.Context(), upstreamURL.String(), req.Header)
+	if err != nil {
+		if resp != nil {
+			// 上游拒绝握手，透传其状态码和响应体
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			logger.Warningf("Upstream rejected WebSocket handshake with status %d", resp.StatusCode)
+			c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+			return nil
+		}
+		if IsUpstreamTimeout(err) {
+			return fmt.Errorf("%w: %v", ErrUpstreamTimeout, err)
+		}
+		return fmt.Errorf("failed to connect upstream WebSocket: %w", err)
+	}
+	defer upstreamConn.Close()
+
+	responseHeader := http.Header{}
+	if subprotocol := upstreamConn.Subprotocol(); subprotocol != "" {
+		responseHeader.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	if requestID := c.GetString(RequestIDContextKey); requestID != "" {
+		responseHeader.Set("X-Request-ID", requestID)
+	}
+	c.Writer.Header().Del("X-Request-ID")
+
+	clientConn, err := websocketUpgrader.Upgrade(c.Writer, c.Request, responseHeader)
+	if err != nil {
+		// 升级失败时升级器已向客户端返回错误
+		logger.Errorf("Failed to upgrade client WebSocket connection: %v", err)
+		return nil
+	}
+	defer clientConn.Close()
+
+	pumpWebSocket(cacheClient, proxyConfig, c.GetString(RequestIDContextKey), clientConn, upstreamConn, target.Upstream.StreamIdleTimeout)
+	return nil
+}
+
+// newWebSocketDialer 按连接参数（超时、出口代理、TLS）构造上游 WebSocket 拨号器
+func newWebSocketDialer(options UpstreamOptions) (*websocket.Dialer, error) {
+	tlsConfig, err := BuildTLSConfig(options.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	netDialer := &net.Dialer{Timeout: options.ConnectTimeout, KeepAlive: 30 * time.Second}
+	dialer := &websocket.Dialer{
+		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout: options.TLSHandshakeTimeout + options.ResponseHeaderTimeout,
+		ReadBufferSize:   32 * 1024,
+		WriteBufferSize:  32 * 1024,
+	}
+	dialer.Proxy, dialer.NetDialContext = CurrentOutboundPolicy().proxyRouting(nil, netDialer)
+
+	if options.EgressProxyURL != "" {
+		proxyURL, err := parseEgressProxyURL(options.EgressProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(proxyURL.Scheme) {
+		case "http", "socks5":
+		case "socks5h":
+			proxyURL.Scheme = "socks5"
+		default:
+			return nil, fmt.Errorf("egress proxy scheme '%s' is not supported for WebSocket connections", proxyURL.Scheme)
+		}
+		dialer.Proxy, dialer.NetDialContext = CurrentOutboundPolicy().proxyRouting(proxyURL, netDialer)
+	}
+
+	return dialer, nil
+}
+
+// pumpWebSocket 双向转发消息，任一方向结束时关闭两端连接，并记录连接统计
+func pumpWebSocket(cacheClient cache.CacheInterface, proxyConfig *models.ProxyConfig, connectionID string,
+	clientConn, upstreamConn *websocket.Conn, idleTimeout time.Duration) {
+	ctx := context.Background()
+	startedAt := time.Now()
+
+	recordWebSocketCounter(ctx, cacheClient, WebSocketCounterKey(proxyConfig.ID, "total"), 1)
+	recordWebSocketCounter(ctx, cacheClient, WebSocketCounterKey(proxyConfig.ID, "active"), 1)
+
+	var bytesIn, bytesOut, messagesIn, messagesOut int64
+	var once sync.Once
+	done := make(chan struct{})
+	closeBoth := func() {
+		once.Do(func() { close(done) })
+	}
+
+	go func() {
+		defer closeBoth()
+		copyWebSocketMessages(upstreamConn, clientConn, idleTimeout, &bytesIn, &messagesIn)
+	}()
+	go func() {
+		defer closeBoth()
+		copyWebSocketMessages(clientConn, upstreamConn, idleTimeout, &bytesOut, &messagesOut)
+	}()
+
+	<-done
+	// 关闭底层连接使另一个方向的读取立即返回
+	clientConn.Close()
+	upstreamConn.Close()
+
+	recordWebSocketCounter(ctx, cacheClient, WebSocketCounterKey(proxyConfig.ID, "active"), -1)
+	recordWebSocketCounter(ctx, cacheClient, WebSocketCounterKey(proxyConfig.ID, "bytes_in"), atomic.LoadInt64(&bytesIn))
+	recordWebSocketCounter(ctx, cacheClient, WebSocketCounterKey(proxyConfig.ID, "bytes_out"), atomic.LoadInt64(&bytesOut))
+	recordWebSocketCounter(ctx, cacheClient, WebSocketCounterKey(proxyConfig.ID, "messages_in"), atomic.LoadInt64(&messagesIn))
+	recordWebSocketCounter(ctx, cacheClient, WebSocketCounterKey(proxyConfig.ID, "messages_out"), atomic.LoadInt64(&messagesOut))
+
+	logger.Infof("WebSocket %s for service '%s' closed after %s: %d messages/%d bytes in, %d messages/%d bytes out",
+		connectionID, proxyConfig.Slug, time.Since(startedAt).Round(time.Millisecond),
+		atomic.LoadInt64(&messagesIn), atomic.LoadInt64(&bytesIn),
+		atomic.LoadInt64(&messagesOut), atomic.LoadInt64(&bytesOut))
+}
+
+// copyWebSocketMessages 将 src 收到的消息写到 dst，直到出错、空闲超时或收到关闭帧
+func copyWebSocketMessages(dst, src *websocket.Conn, idleTimeout time.Duration, byteCount, messageCount *int64) {
+	extendDeadline := func() {
+		if idleTimeout > 0 {
+			src.SetReadDeadline(time.Now().Add(idleTimeout))
+		}
+	}
+	extendDeadline()
+
+	// 收到 ping/pong 也视为连接活跃；ping 转发给另一端，由另一端回复 pong
+	src.SetPingHandler(func(data string) error {
+		extendDeadline()
+		return dst.WriteControl(websocket.PingMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+	src.SetPongHandler(func(data string) error {
+		extendDeadline()
+		return dst.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			forwardWebSocketClose(dst, err)
+			return
+		}
+		extendDeadline()
+
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			return
+		}
+		atomic.AddInt64(byteCount, int64(len(data)))
+		atomic.AddInt64(messageCount, 1)
+	}
+}
+
+// forwardWebSocketClose 将一端的关闭原因转发给另一端
+func forwardWebSocketClose(dst *websocket.Conn, readErr error) {
+	code, text := websocket.CloseNormalClosure, ""
+
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(readErr, &closeErr):
+		code, text = closeErr.Code, closeErr.Text
+		if code == websocket.CloseNoStatusReceived {
+			code = websocket.CloseNormalClosure
+		}
+	case errors.As(readErr, &netErr) && netErr.Timeout():
+		code, text = websocket.CloseGoingAway, "idle timeout"
+	default:
+		code = websocket.CloseGoingAway
+	}
+
+	message := websocket.FormatCloseMessage(code, text)
+	dst.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+}
+
+// recordWebSocketCounter 累加 WebSocket 统计计数，计数失败只记录日志
+func recordWebSocketCounter(ctx context.Context, cacheClient cache.CacheInterface, key string, delta int64) {
+	if delta == 0 {
+		return
+	}
+	if _, err := cacheClient.IncrBy(ctx, key, delta); err != nil {
+		logger.Errorf("Failed to record WebSocket counter %s: %v", key, err)
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"api-key-rotator/backend/internal/infrastructure/cache/memory"
+	"api-key-rotator/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestProxyWebSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy"} {
+		t.Setenv(name, "")
+	}
+
+	// 上游: 校验注入的密钥后回显消息，/reject 拒绝握手
+	upgrader := websocket.Upgrader{Subprotocols: []string{"chat"}}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" || r.Header.Get("Authorization") != "Bearer sk-1" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte("echo:"), data...))
+		}
+	}))
+	defer upstream.Close()
+
+	cacheClient := memory.NewMemoryCache()
+	proxyConfig := &models.ProxyConfig{ID: 9, Slug: "ws"}
+	engine := gin.New()
+	engine.GET("/*path", func(c *gin.Context) {
+		target := &TargetRequest{
+			URL:      upstream.URL + c.Param("path"),
+			Headers:  http.Header{"Authorization": {"Bearer sk-1"}, "Sec-Websocket-Protocol": {"chat"}},
+			Params:   url.Values{},
+			Upstream: UpstreamOptions{ConnectTimeout: time.Second, ResponseHeaderTimeout: time.Second},
+		}
+		if err := ProxyWebSocket(c, cacheClient, proxyConfig, target); err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+		}
+	})
+	proxy := httptest.NewServer(engine)
+	defer proxy.Close()
+	proxyURL := "ws" + strings.TrimPrefix(proxy.URL, "http")
+
+	// 上游拒绝握手时状态码原样返回
+	if _, resp, err := websocket.DefaultDialer.Dial(proxyURL+"/reject", nil); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Dial(/reject) = %v, %v, want status 403", resp, err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(proxyURL+"/echo", nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if conn.Subprotocol() != "chat" {
+		t.Errorf("subprotocol = %q, want chat", conn.Subprotocol())
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "echo:hi" {
+		t.Fatalf("ReadMessage() = %q, %v, want echo:hi", data, err)
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	// 连接关闭后统计写入缓存
+	ctx := context.Background()
+	want := map[string]string{"total": "1", "active": "0", "messages_in": "1", "messages_out": "1", "bytes_in": "2", "bytes_out": "7"}
+	deadline := time.Now().Add(2 * time.Second)
+	for field, value := range want {
+		got, _ := cacheClient.Get(ctx, WebSocketCounterKey(proxyConfig.ID, field))
+		for got != value && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			got, _ = cacheClient.Get(ctx, WebSocketCounterKey(proxyConfig.ID, field))
+		}
+		if got != value {
+			t.Errorf("%s = %q, want %s", field, got, value)
+		}
+	}
+}
+
+func TestNewWebSocketDialerEgressProxy(t *testing.T) {
+	tests := []struct {
+		proxyURL string
+		wantErr  bool
+	}{
+		{"", false},
+		{"http://proxy.example:3128", false},
+		{"socks5h://proxy.example:1080", false},
+		{"https://proxy.example:443", true},
+	}
+	for _, tt := range tests {
+		dialer, err := newWebSocketDialer(UpstreamOptions{EgressProxyURL: tt.proxyURL})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newWebSocketDialer(%q) error = %v, wantErr %v", tt.proxyURL, err, tt.wantErr)
+			continue
+		}
+		if err == nil && tt.proxyURL != "" && dialer.Proxy == nil {
+			t.Errorf("newWebSocketDialer(%q) did not route through the proxy", tt.proxyURL)
+		}
+	}
+}
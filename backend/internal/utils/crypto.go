@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// encryptedPrefix 加密内容的前缀，用于区分明文和密文
+const encryptedPrefix = "enc:v1:"
+
+// IsEncrypted 判断内容是否已由 EncryptSecret 加密
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// EncryptSecret 使用 AES-256-GCM 加密敏感内容，密钥由 secret 经 SHA-256 派生
+func EncryptSecret(plaintext, secret string) (string, error) {
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret 解密由 EncryptSecret 加密的内容
+func DecryptSecret(ciphertext, secret string) (string, error) {
+	if !IsEncrypted(ciphertext) {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted value")
+	}
+
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value, the encryption key may have changed: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(secret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestEncryptSecret(t *testing.T) {
+	encrypted, err := EncryptSecret("private key", "secret")
+	if err != nil || !IsEncrypted(encrypted) {
+		t.Fatalf("EncryptSecret() = %q, %v, want an encrypted value", encrypted, err)
+	}
+	if again, _ := EncryptSecret("private key", "secret"); again == encrypted {
+		t.Error("EncryptSecret() should use a random nonce")
+	}
+	if got, err := DecryptSecret(encrypted, "secret"); err != nil || got != "private key" {
+		t.Errorf("DecryptSecret() = %q, %v, want the original value", got, err)
+	}
+
+	for _, ciphertext := range []string{"private key", encryptedPrefix + "!!!", encryptedPrefix + "AAAA"} {
+		if _, err := DecryptSecret(ciphertext, "secret"); err == nil {
+			t.Errorf("DecryptSecret(%q) should fail", ciphertext)
+		}
+	}
+	if _, err := DecryptSecret(encrypted, "other"); err == nil {
+		t.Error("DecryptSecret() with another secret should fail")
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPFilter 基于CIDR的IP访问控制列表
+type IPFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPFilter 根据白名单和黑名单创建IP过滤器
+// 列表项既可以是CIDR（如 10.0.0.0/8），也可以是单个IP（如 192.168.1.10）
+func NewIPFilter(allowlist, denylist []string) (*IPFilter, error) {
+	allow, err := ParseCIDRList(allowlist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowlist: %w", err)
+	}
+	deny, err := ParseCIDRList(denylist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid denylist: %w", err)
+	}
+	return &IPFilter{allow: allow, deny: deny}, nil
+}
+
+// ParseCIDRList 解析CIDR或单个IP组成的列表
+func ParseCIDRList(entries []string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ipNet, err := ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ipNet)
+	}
+	return result, nil
+}
+
+// ParseCIDR 解析单个CIDR，单个IP会被视为 /32 或 /128
+func ParseCIDR(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR '%s'", entry)
+		}
+		return ipNet, nil
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address '%s'", entry)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// IsEmpty 判断过滤器是否没有任何规则
+func (f *IPFilter) IsEmpty() bool {
+	return len(f.allow) == 0 && len(f.deny) == 0
+}
+
+// Allows 判断IP是否允许访问
+// 黑名单优先；白名单为空表示不限制，否则必须命中白名单
+func (f *IPFilter) Allows(ipStr string) bool {
+	ip := net.ParseIP(strings.TrimSpace(ipStr))
+	if ip == nil {
+		// 无法解析的IP只有在没有任何规则时才放行
+		return f.IsEmpty()
+	}
+
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	return containsIP(f.allow, ip)
+}
+
+// containsIP 判断IP是否落在任一网段内
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+func TestIPFilterAllows(t *testing.T) {
+	tests := []struct {
+		allowlist []string
+		denylist  []string
+		ip        string
+		want      bool
+	}{
+		{nil, nil, "unknown", true}, // 没有规则时不解析IP
+		{[]string{"10.0.0.0/8"}, nil, "10.1.2.3", true},
+		{[]string{"10.0.0.0/8"}, nil, "192.168.1.1", false},
+		{[]string{" 192.168.1.10 "}, nil, "192.168.1.10", true},
+		{[]string{"10.0.0.0/8"}, []string{"10.0.0.5"}, "10.0.0.5", false}, // 黑名单优先
+		{[]string{"2001:db8::/32"}, nil, "2001:db8::1", true},
+		{[]string{"10.0.0.0/8"}, nil, "::ffff:10.0.0.1", true},
+		{nil, []string{"10.0.0.0/8"}, "unknown", false},
+	}
+	for _, tt := range tests {
+		filter, err := NewIPFilter(tt.allowlist, tt.denylist)
+		if err != nil {
+			t.Fatalf("NewIPFilter(%v, %v) error = %v", tt.allowlist, tt.denylist, err)
+		}
+		if got := filter.Allows(tt.ip); got != tt.want {
+			t.Errorf("Allows(%q) with allow %v deny %v = %v, want %v", tt.ip, tt.allowlist, tt.denylist, got, tt.want)
+		}
+	}
+
+	for _, entry := range []string{"10.0.0.0/33", "not-an-ip", "300.1.1.1"} {
+		if _, err := NewIPFilter([]string{entry}, nil); err == nil {
+			t.Errorf("NewIPFilter(%q) should fail", entry)
+		}
+	}
+}
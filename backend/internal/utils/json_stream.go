@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// JSONArrayReader 从JSON数组形式的流中逐个读取元素
+// Gemini 的 streamGenerateContent 未指定 alt=sse 时以 "[{...},\r\n{...}]" 的形式返回流式响应
+type JSONArrayReader struct {
+	decoder *json.Decoder
+	started bool
+	done    bool
+}
+
+// NewJSONArrayReader 创建JSON数组流读取器
+func NewJSONArrayReader(r io.Reader) *JSONArrayReader {
+	return &JSONArrayReader{decoder: json.NewDecoder(r)}
+}
+
+// Next 读取数组的下一个元素，数组结束时返回 io.EOF
+// 每个元素读取完整后立即返回，不需要等待整个数组
+func (r *JSONArrayReader) Next() (json.RawMessage, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if !r.started {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, fmt.Errorf("expected JSON array stream, got %v", token)
+		}
+		r.started = true
+	}
+
+	if !r.decoder.More() {
+		// 读取结束的 ']'
+		if _, err := r.decoder.Token(); err != nil {
+			return nil, err
+		}
+		r.done = true
+		return nil, io.EOF
+	}
+
+	var element json.RawMessage
+	if err := r.decoder.Decode(&element); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return element, nil
+}
+
+// JSONArrayWriter 以JSON数组的形式逐个写出元素，元素之间以 ",\r\n" 分隔
+type JSONArrayWriter struct {
+	count  int
+	closed bool
+}
+
+// WriteElement 写出一个元素，第一个元素前写出 '['
+func (a *JSONArrayWriter) WriteElement(w io.Writer, element []byte) error {
+	prefix := ",\r\n"
+	if a.count == 0 {
+		prefix = "["
+	}
+	a.count++
+	_, err := w.Write(append([]byte(prefix), element...))
+	return err
+}
+
+// Close 写出结束的 ']'，没有写出过元素时写出空数组
+func (a *JSONArrayWriter) Close(w io.Writer) error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	end := "]"
+	if a.count == 0 {
+		end = "[]"
+	}
+	_, err := w.Write([]byte(end))
+	return err
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONArrayReader(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr error // 读完 want 之后的错误
+	}{
+		{"[{\"a\":1}\r\n,\r\n{\"b\":[2,3]}]", []string{`{"a":1}`, `{"b":[2,3]}`}, io.EOF},
+		{" [ ] ", nil, io.EOF},
+		{`[{"a":1},{"a":`, []string{`{"a":1}`}, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		reader := NewJSONArrayReader(strings.NewReader(tt.input))
+		var got []string
+		element, err := reader.Next()
+		for ; err == nil; element, err = reader.Next() {
+			got = append(got, string(element))
+		}
+		if !reflect.DeepEqual(got, tt.want) || err != tt.wantErr {
+			t.Errorf("read %q = %q, %v, want %q, %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if _, err := NewJSONArrayReader(strings.NewReader(`data: {"a":1}`)).Next(); err == nil || err == io.EOF {
+		t.Errorf("Next() on an SSE stream error = %v, want a format error", err)
+	}
+}
+
+func TestJSONArrayWriter(t *testing.T) {
+	tests := []struct {
+		elements []string
+		want     string
+	}{
+		{nil, "[]"},
+		{[]string{`{"a":1}`}, `[{"a":1}]`},
+		{[]string{`{"a":1}`, `{"a":2}`}, "[{\"a\":1},\r\n{\"a\":2}]"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writer := &JSONArrayWriter{}
+		for _, element := range tt.elements {
+			writer.WriteElement(&buf, []byte(element))
+		}
+		writer.Close(&buf)
+		writer.Close(&buf) // 重复关闭不再写出
+		if buf.String() != tt.want {
+			t.Errorf("JSONArrayWriter(%v) = %q, want %q", tt.elements, buf.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchPathGlob 按段匹配路径通配符
+// 每段支持 path.Match 语法 (*、?、[...])，单独的 "**" 段可匹配任意多段 (包括零段)
+func MatchPathGlob(pattern, p string) bool {
+	return matchSegments(splitPathSegments(pattern), splitPathSegments(p))
+}
+
+// ValidatePathGlob 校验路径通配符的语法
+func ValidatePathGlob(pattern string) error {
+	for _, segment := range splitPathSegments(pattern) {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// 尝试让 "**" 吞掉 0..n 段
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+func splitPathSegments(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "testing"
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/users/*", "/users/42", true},
+		{"/users/*", "/users/42/posts", false},
+		{"/users/*", "/users", false},
+		{"/files/**", "/files", true},
+		{"/files/**", "/files/a/b/c.txt", true},
+		{"/**/admin", "/a/b/admin", true},
+		{"/**/admin", "/admin", true},
+		{"/v?/models", "/v1/models", true},
+		{"/v[12]/models", "/v3/models", false},
+		{"/admin/**", "/public/admin", false},
+		{"users/*", "/users/1/", true},
+	}
+	for _, tt := range tests {
+		if got := MatchPathGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPathGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePathGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"/users/*", false},
+		{"/files/**", false},
+		{"/v[12]/*", false},
+		{"/v[12/*", true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePathGlob(tt.pattern); (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePathGlob(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
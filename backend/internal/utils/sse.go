@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+// maxSSEEventSize 单个SSE事件的最大字节数，防止异常上游耗尽内存
+const maxSSEEventSize = 16 << 20
+
+// ErrSSEEventTooLarge 单个SSE事件超过 maxSSEEventSize
+var ErrSSEEventTooLarge = errors.New("SSE event exceeds maximum size")
+
+// SSEEvent 一个完整的SSE事件
+// 注释行（包括 ": ping" 这类心跳）单独作为事件返回，此时只有 Comment 有值
+type SSEEvent struct {
+	Event   string // event: 字段，为空表示默认的 message 事件
+	Data    string // 多个 data: 行以换行符连接
+	ID      string // id: 字段
+	Retry   string // retry: 字段，客户端的重连间隔（毫秒）
+	HasData bool   // 是否出现过 data: 行，用于区分空数据和没有数据
+	HasID   bool   // 是否出现过 id: 行，空的 id 表示重置最后事件ID
+
+	IsComment bool
+	Comment   string // 冒号之后的注释内容（保留原有的前导空格）
+}
+
+// SSEReader 按 text/event-stream 规范从流中逐个读取完整事件
+// 支持 \n、\r\n、\r 三种换行，多行 data 以及 event/id/retry 字段
+type SSEReader struct {
+	reader *bufio.Reader
+	// pending 同一次读取中 \r 换行拆出的剩余行
+	pending []string
+}
+
+// NewSSEReader 创建SSE事件读取器
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Next 读取下一个事件或注释，流结束时返回 io.EOF
+// 流在事件中途结束时，已读到的字段仍作为最后一个事件返回
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	event := &SSEEvent{}
+	started := false
+	size := 0
+	var data strings.Builder
+
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			if err == io.EOF && started {
+				event.Data = data.String()
+				return event, nil
+			}
+			return nil, err
+		}
+
+		if line == "" {
+			if !started {
+				continue
+			}
+			event.Data = data.String()
+			return event, nil
+		}
+
+		if strings.HasPrefix(line, ":") {
+			if started {
+				// 事件中间的注释直接忽略，避免打断事件
+				continue
+			}
+			return &SSEEvent{IsComment: true, Comment: line[1:]}, nil
+		}
+
+		size += len(line)
+		if size > maxSSEEventSize {
+			return nil, ErrSSEEventTooLarge
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+		switch field {
+		case "data":
+			if event.HasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			event.HasData = true
+		case "event":
+			event.Event = value
+		case "id":
+			// 包含空字符的 id 按规范忽略
+			if !strings.ContainsRune(value, 0) {
+				event.ID = value
+				event.HasID = true
+			}
+		case "retry":
+			event.Retry = value
+		default:
+			// 未知字段按规范忽略
+			continue
+		}
+		started = true
+	}
+}
+
+// readLine 读取一行，去掉行尾的换行符
+func (r *SSEReader) readLine() (string, error) {
+	if len(r.pending) > 0 {
+		line := r.pending[0]
+		r.pending = r.pending[1:]
+		return line, nil
+	}
+
+	var buf []byte
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if len(buf) > maxSSEEventSize {
+			return "", ErrSSEEventTooLarge
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if len(buf) == 0 {
+				return "", err
+			}
+			// 最后一行没有换行符
+			break
+		}
+		break
+	}
+
+	buf = bytes.TrimSuffix(buf, []byte("\n"))
+	buf = bytes.TrimSuffix(buf, []byte("\r"))
+	// 单独的 \r 也是换行
+	lines := strings.Split(string(buf), "\r")
+	r.pending = append(r.pending, lines[1:]...)
+	return lines[0], nil
+}
+
+// WriteSSEEvent 按规范写出一个事件，多行数据拆分为多个 data: 行
+func WriteSSEEvent(w io.Writer, event *SSEEvent) error {
+	var buf bytes.Buffer
+	if event.IsComment {
+		buf.WriteString(":" + event.Comment + "\n\n")
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	if event.Event != "" {
+		buf.WriteString("event: " + event.Event + "\n")
+	}
+	if event.HasID {
+		buf.WriteString("id: " + event.ID + "\n")
+	}
+	if event.Retry != "" {
+		buf.WriteString("retry: " + event.Retry + "\n")
+	}
+	if event.HasData {
+		for _, line := range strings.Split(event.Data, "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
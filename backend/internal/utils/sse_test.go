@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSSEReader(t *testing.T) {
+	input := ": ping\n\n" +
+		"event: message_start\nid: 7\nretry: 3000\ndata: {\"a\":\ndata: 1}\n\n" +
+		"data: a\r\n: comment\r\ndata:  b\r\n\r\n" +
+		"foo: bar\rdata: c\r\r" +
+		"id\ndata: [DONE]"
+	want := []SSEEvent{
+		{IsComment: true, Comment: " ping"},
+		{Event: "message_start", ID: "7", HasID: true, Retry: "3000", Data: "{\"a\":\n1}", HasData: true},
+		{Data: "a\n b", HasData: true}, // 事件中间的注释被忽略，只去掉一个前导空格
+		{Data: "c", HasData: true},
+		{HasID: true, Data: "[DONE]", HasData: true}, // 流在事件中途结束
+	}
+
+	reader := NewSSEReader(strings.NewReader(input))
+	var got []SSEEvent
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, *event)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteSSEEvent(t *testing.T) {
+	tests := []struct {
+		event SSEEvent
+		want  string
+	}{
+		{SSEEvent{Data: "a\n\nb", HasData: true}, "data: a\ndata: \ndata: b\n\n"},
+		{SSEEvent{Event: "delta", ID: "1", HasID: true, Retry: "500", Data: "x", HasData: true}, "event: delta\nid: 1\nretry: 500\ndata: x\n\n"},
+		{SSEEvent{IsComment: true, Comment: " ping"}, ": ping\n\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteSSEEvent(&buf, &tt.event); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("WriteSSEEvent(%+v) = %q, want %q", tt.event, buf.String(), tt.want)
+		}
+		// 写出的事件能被原样读回
+		if event, err := NewSSEReader(&buf).Next(); err != nil || !reflect.DeepEqual(*event, tt.event) {
+			t.Errorf("round trip of %+v = %+v, %v", tt.event, event, err)
+		}
+	}
+}
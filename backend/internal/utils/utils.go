@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net/http"
 	"strings"
 )
 
@@ -39,50 +40,46 @@ func MaskAPIKeyDefault(keyValue string) string {
 	return MaskAPIKey(keyValue, 6, 3)
 }
 
-// FilterRequestHeaders 过滤请求头，移除不应该转发的头部
-func FilterRequestHeaders(headers map[string][]string, headersToRemove []string) map[string]string {
+// FilterRequestHeaders 过滤请求头，移除不应该转发的头部，保留每个头部的全部值
+func FilterRequestHeaders(headers http.Header, headersToRemove []string) http.Header {
 	// 这些是不能从客户端透传给上游服务器的Header
 	hopByHopHeaders := []string{
-		"host", "content-length", "transfer-encoding", "connection",
-		"keep-alive", "te", "upgrade", "user-agent",
+		"host", "content-length", "transfer-encoding", "connection", "proxy-connection",
+		"keep-alive", "te", "trailer", "upgrade", "user-agent",
 	}
 
-	// 合并通用的逐跳头和特定需要移除的头
-	allToRemove := make(map[string]bool)
-	for _, header := range hopByHopHeaders {
-		allToRemove[strings.ToLower(header)] = true
-	}
-	for _, header := range headersToRemove {
-		allToRemove[strings.ToLower(header)] = true
-	}
-
-	finalHeaders := make(map[string]string)
-	for key, values := range headers {
-		if !allToRemove[strings.ToLower(key)] && len(values) > 0 {
-			finalHeaders[key] = values[0] // 取第一个值
-		}
-	}
-
-	return finalHeaders
+	return filterHeaders(headers, append(hopByHopHeaders, headersToRemove...))
 }
 
-// FilterResponseHeaders 过滤响应头，移除不应该返回给客户端的头部
-func FilterResponseHeaders(headers map[string][]string) map[string]string {
+// FilterResponseHeaders 过滤响应头，移除不应该返回给客户端的头部，保留每个头部的全部值（如多个 Set-Cookie）
+func FilterResponseHeaders(headers http.Header) http.Header {
 	// 这些是不能从上游服务器透传给最终客户端的Header
 	hopByHopHeaders := []string{
-		"connection", "keep-alive", "proxy-authenticate", "proxy-authorization",
-		"te", "trailers", "transfer-encoding", "upgrade", "content-length",
+		"connection", "proxy-connection", "keep-alive", "proxy-authenticate", "proxy-authorization",
+		"te", "trailer", "trailers", "transfer-encoding", "upgrade", "content-length",
 	}
 
-	toRemove := make(map[string]bool)
-	for _, header := range hopByHopHeaders {
-		toRemove[strings.ToLower(header)] = true
+	return filterHeaders(headers, hopByHopHeaders)
+}
+
+// filterHeaders 复制头部并移除指定的头部以及 Connection 中列出的头部
+func filterHeaders(headers http.Header, headersToRemove []string) http.Header {
+	allToRemove := make(map[string]bool)
+	for _, header := range headersToRemove {
+		allToRemove[strings.ToLower(header)] = true
+	}
+	for _, value := range headers.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				allToRemove[strings.ToLower(name)] = true
+			}
+		}
 	}
 
-	filtered := make(map[string]string)
+	filtered := make(http.Header, len(headers))
 	for key, values := range headers {
-		if !toRemove[strings.ToLower(key)] && len(values) > 0 {
-			filtered[key] = values[0] // 取第一个值
+		if !allToRemove[strings.ToLower(key)] && len(values) > 0 {
+			filtered[key] = append([]string(nil), values...)
 		}
 	}
 
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFilterHeaders(t *testing.T) {
+	request := http.Header{
+		"Accept":          {"application/json", "text/plain"},
+		"Connection":      {"keep-alive, X-Hop"},
+		"X-Hop":           {"1"},
+		"Content-Length":  {"10"},
+		"X-Proxy-Key":     {"secret"},
+		"X-Forwarded-For": {"10.0.0.1", "10.0.0.2"},
+	}
+	want := http.Header{
+		"Accept":          {"application/json", "text/plain"},
+		"X-Forwarded-For": {"10.0.0.1", "10.0.0.2"},
+	}
+	if got := FilterRequestHeaders(request, []string{"x-proxy-key"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterRequestHeaders() = %v, want %v", got, want)
+	}
+
+	response := http.Header{
+		"Set-Cookie":        {"a=1", "b=2"},
+		"Transfer-Encoding": {"chunked"},
+		"Content-Type":      {"text/plain"},
+	}
+	want = http.Header{"Set-Cookie": {"a=1", "b=2"}, "Content-Type": {"text/plain"}}
+	if got := FilterResponseHeaders(response); !reflect.DeepEqual(got, want) {
+		t.Errorf("FilterResponseHeaders() = %v, want %v", got, want)
+	}
+}